@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"credibot-api/models"
+	"crypto/sha256"
+	"strings"
+)
+
+// Identity is the caller an API key authenticates, with the role it acts in
+type Identity struct {
+	Caller string
+	Role   string
+}
+
+// Keys resolves API keys to the identities they authenticate
+type Keys struct {
+	identities map[[sha256.Size]byte]Identity
+	callers    map[string]bool
+}
+
+// NewKeys creates the key set of the configured API keys
+func NewKeys(keys []models.APIKey) *Keys {
+	k := &Keys{
+		identities: make(map[[sha256.Size]byte]Identity, len(keys)),
+		callers:    make(map[string]bool, len(keys)),
+	}
+	for _, key := range keys {
+		// Keys are looked up by hash, so the lookup time does not depend on how much of a key matches
+		k.identities[sha256.Sum256([]byte(key.Key))] = Identity{Caller: key.Caller, Role: key.Role}
+		k.callers[key.Caller] = true
+	}
+	return k
+}
+
+// Enabled reports whether any API key is configured
+func (k *Keys) Enabled() bool {
+	return k != nil && len(k.identities) > 0
+}
+
+// Lookup returns the identity an API key authenticates
+func (k *Keys) Lookup(key string) (Identity, bool) {
+	if k == nil || key == "" {
+		return Identity{}, false
+	}
+	identity, ok := k.identities[sha256.Sum256([]byte(key))]
+	return identity, ok
+}
+
+// Claimed reports whether a caller belongs to an API key, so only that key may act as it
+func (k *Keys) Claimed(caller string) bool {
+	return k != nil && k.callers[caller]
+}
+
+// BearerToken returns the token of an "Authorization: Bearer <token>" header value
+func BearerToken(header string) string {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+type identityKey struct{}
+
+// WithIdentity marks the work done with the context as done for an authenticated caller
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the authenticated caller of a context, if any
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
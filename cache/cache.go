@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"credibot-api/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Backend names accepted in CACHE_BACKEND
+const (
+	BackendMemory = "memory"
+	BackendNone   = "none"
+)
+
+// Cache stores serialized responses by key until their TTL expires. Keys start with a
+// namespace followed by a colon, so entries of one endpoint can be purged together.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	// Purge removes the entries of a namespace, or every entry when namespace is empty
+	Purge(namespace string) int
+	Stats() Stats
+}
+
+// Stats reports the effectiveness of a cache
+type Stats struct {
+	Backend string `json:"backend"`
+	Entries int    `json:"entries"`
+	Hits    int64  `json:"hits"`
+	Misses  int64  `json:"misses"`
+}
+
+// New creates the cache selected by the configuration
+func New(cfg models.CacheConfig) (Cache, error) {
+	switch cfg.Backend {
+	case BackendMemory, "":
+		return NewMemory(cfg.MaxEntries), nil
+	case BackendNone:
+		return none{}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s", cfg.Backend)
+	}
+}
+
+// Key builds a cache key in a namespace from the hash of its parts
+func Key(namespace string, parts ...[]byte) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	return namespace + ":" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// NormalizeQuestion folds case, whitespace and trailing punctuation, so that
+// "O que é score de crédito?" and "o que é  score de crédito" share an entry
+func NormalizeQuestion(question string) string {
+	question = strings.Join(strings.Fields(strings.ToLower(question)), " ")
+	return strings.TrimRight(question, "?!. ")
+}
+
+// none disables caching
+type none struct{}
+
+func (none) Get(string) ([]byte, bool)         { return nil, false }
+func (none) Set(string, []byte, time.Duration) {}
+func (none) Purge(string) int                  { return 0 }
+func (none) Stats() Stats                      { return Stats{Backend: BackendNone} }
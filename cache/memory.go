@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaxEntries bounds the memory cache when no size is configured
+const defaultMaxEntries = 1000
+
+// Memory is an in-memory LRU cache; the least recently used entry is evicted when it is full
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+	hits       int64
+	misses     int64
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory creates an LRU cache holding up to maxEntries entries
+func NewMemory(maxEntries int) *Memory {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	return &Memory{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns an unexpired entry and marks it as recently used
+func (m *Memory) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.entries[key]
+	if ok && time.Now().After(element.Value.(*memoryEntry).expires) {
+		m.remove(element)
+		ok = false
+	}
+	if !ok {
+		m.misses++
+		return nil, false
+	}
+
+	m.hits++
+	m.order.MoveToFront(element)
+	return element.Value.(*memoryEntry).value, true
+}
+
+// Set stores an entry, evicting the least recently used ones when the cache is full
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if element, ok := m.entries[key]; ok {
+		element.Value = entry
+		m.order.MoveToFront(element)
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(entry)
+	for m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+	}
+}
+
+// Purge removes the entries of a namespace, or every entry when namespace is empty
+func (m *Memory) Purge(namespace string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := 0
+	for key, element := range m.entries {
+		if namespace == "" || strings.HasPrefix(key, namespace+":") {
+			m.remove(element)
+			purged++
+		}
+	}
+	return purged
+}
+
+// Stats reports the size and hit rate of the cache
+func (m *Memory) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Stats{Backend: BackendMemory, Entries: m.order.Len(), Hits: m.hits, Misses: m.misses}
+}
+
+func (m *Memory) remove(element *list.Element) {
+	m.order.Remove(element)
+	delete(m.entries, element.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEntriesExpireAfterTheirTTL(t *testing.T) {
+	m := NewMemory(10)
+	m.Set("chat:a", []byte("a"), 20*time.Millisecond)
+	m.Set("chat:b", []byte("b"), 0)
+
+	if value, ok := m.Get("chat:a"); !ok || string(value) != "a" {
+		t.Errorf("Get = %q, %v; want the fresh entry", value, ok)
+	}
+	if _, ok := m.Get("chat:b"); ok {
+		t.Error("an entry without a TTL was stored")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := m.Get("chat:a"); ok {
+		t.Error("an expired entry was returned")
+	}
+	if stats := m.Stats(); stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 0 {
+		t.Errorf("stats = %+v, want 1 hit, 2 misses and no entries", stats)
+	}
+}
+
+func TestTheLeastRecentlyUsedEntryIsEvicted(t *testing.T) {
+	m := NewMemory(2)
+	m.Set("chat:a", []byte("a"), time.Minute)
+	m.Set("chat:b", []byte("b"), time.Minute)
+	m.Get("chat:a")
+	m.Set("chat:c", []byte("c"), time.Minute)
+
+	if _, ok := m.Get("chat:b"); ok {
+		t.Error("b was kept, though a was used more recently")
+	}
+	for _, key := range []string{"chat:a", "chat:c"} {
+		if _, ok := m.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
+
+func TestPurgeRemovesANamespace(t *testing.T) {
+	m := NewMemory(10)
+	m.Set("chat:a", []byte("a"), time.Minute)
+	m.Set("chat:b", []byte("b"), time.Minute)
+	m.Set("smart_chat:a", []byte("a"), time.Minute)
+	// A namespace sharing the prefix of another
+	m.Set("chatter:a", []byte("a"), time.Minute)
+
+	if purged := m.Purge("chat"); purged != 2 {
+		t.Errorf("purged %d entries of chat, want 2", purged)
+	}
+	if _, ok := m.Get("smart_chat:a"); !ok {
+		t.Error("purging chat removed a smart_chat entry")
+	}
+	if _, ok := m.Get("chatter:a"); !ok {
+		t.Error("purging chat removed a chatter entry")
+	}
+	if purged := m.Purge(""); purged != 2 {
+		t.Errorf("purged %d entries in all, want 2", purged)
+	}
+}
+
+func TestTriviallyDifferentQuestionsShareAKey(t *testing.T) {
+	key := func(question string) string { return Key("chat", []byte(NormalizeQuestion(question))) }
+
+	if key("O que é score de crédito?") != key("  o que É score   de crédito") {
+		t.Error("questions differing in case, spacing and punctuation have different keys")
+	}
+	if key("O que é score de crédito?") == key("O que é score de débito?") {
+		t.Error("different questions share a key")
+	}
+	// Parts are delimited, so they cannot be shifted between each other
+	if Key("chat", []byte("ab"), []byte("c")) == Key("chat", []byte("a"), []byte("bc")) {
+		t.Error("shifted parts share a key")
+	}
+}
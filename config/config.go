@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,8 +14,37 @@ import (
 // Config contains all application configurations
 type Config struct {
 	Port     string
-	Supabase models.SupabaseConfig
-	OpenAI   models.OpenAIConfig
+	GRPCPort string
+	// GRPCReflection exposes the gRPC service definition to any client
+	GRPCReflection bool
+	// RequestTimeout bounds each HTTP request, from the handler through every upstream call
+	RequestTimeout time.Duration
+	Supabase       models.SupabaseConfig
+	OpenAI         models.OpenAIConfig
+	LLM            models.LLMConfig
+	SmartChat      models.SmartChatConfig
+	Guard          models.GuardConfig
+	Redaction      models.RedactionConfig
+	Chat           models.ChatConfig
+	Generation     models.GenerationConfig
+	Batch          models.BatchConfig
+	WebSocket      models.WebSocketConfig
+	Conversations  models.ConversationsConfig
+	Jobs           models.JobsConfig
+	Webhooks       models.WebhookConfig
+	Prompts        models.PromptsConfig
+	Experiments    models.ExperimentsConfig
+	Documents      models.DocumentsConfig
+	Cache          models.CacheConfig
+	Usage          models.UsageConfig
+	// HTTP clients shared by every request to each upstream
+	LLMHTTP      models.HTTPClientConfig
+	SupabaseHTTP models.HTTPClientConfig
+	WebhookHTTP  models.HTTPClientConfig
+	// AdminAPIKey protects the /api/v1/admin routes
+	AdminAPIKey string
+	// APIKeys authenticate callers and their roles
+	APIKeys []models.APIKey
 }
 
 var AppConfig *Config
@@ -25,18 +56,106 @@ func LoadConfig() {
 		log.Println(".env file not found, using system environment variables")
 	}
 
+	openAI := models.OpenAIConfig{
+		APIKey:      getEnv("OPENAI_API_KEY", ""),
+		Model:       getEnv("OPENAI_MODEL", "gpt-3.5-turbo"),
+		MaxTokens:   getEnvAsInt("OPENAI_MAX_TOKENS", 150),
+		Temperature: getEnvAsFloat("OPENAI_TEMPERATURE", 0.7),
+	}
+
 	AppConfig = &Config{
-		Port: getEnv("PORT", "3000"),
+		Port:           getEnv("PORT", "3000"),
+		GRPCPort:       getEnv("GRPC_PORT", "50051"),
+		GRPCReflection: getEnvAsBool("GRPC_REFLECTION", false),
+		RequestTimeout: time.Duration(getEnvAsInt("REQUEST_TIMEOUT_SECONDS", 120)) * time.Second,
 		Supabase: models.SupabaseConfig{
 			URL:    getEnv("SUPABASE_URL", ""),
 			APIKey: getEnv("SUPABASE_API_KEY", ""),
 		},
-		OpenAI: models.OpenAIConfig{
-			APIKey:      getEnv("OPENAI_API_KEY", ""),
-			Model:       getEnv("OPENAI_MODEL", "gpt-3.5-turbo"),
-			MaxTokens:   getEnvAsInt("OPENAI_MAX_TOKENS", 150),
-			Temperature: getEnvAsFloat("OPENAI_TEMPERATURE", 0.7),
+		OpenAI: openAI,
+		LLM:    getLLMConfig(),
+		SmartChat: models.SmartChatConfig{
+			Mode:           getEnv("SMART_CHAT_MODE", "sql"),
+			QueryTimeout:   time.Duration(getEnvAsInt("SMART_CHAT_QUERY_TIMEOUT_SECONDS", 15)) * time.Second,
+			Classification: getStageConfig("CLASSIFICATION", openAI.Model, 250, 0.1, 30),
+			SQLGeneration:  getStageConfig("SQL", openAI.Model, 300, 0.1, 30),
+			DataNarration:  getStageConfig("NARRATION", openAI.Model, 400, openAI.Temperature, 60),
+			GeneralAnswer:  getStageConfig("GENERAL", openAI.Model, 300, openAI.Temperature, 60),
+			Guard:          getStageConfig("GUARD", openAI.Model, 50, 0, 15),
+		},
+		Guard: models.GuardConfig{
+			Enabled:    getEnvAsBool("GUARD_ENABLED", true),
+			RulesFile:  getEnv("GUARD_RULES_FILE", ""),
+			ModelCheck: getEnvAsBool("GUARD_MODEL_CHECK", false),
+		},
+		Chat: models.ChatConfig{
+			SystemPrompt:       getEnv("CHAT_SYSTEM_PROMPT", ""),
+			SystemPromptPolicy: getEnv("CHAT_SYSTEM_PROMPT_POLICY", "prepend"),
+			MaxMessages:        getEnvAsInt("CHAT_MAX_MESSAGES", 50),
+			MaxMessageChars:    getEnvAsInt("CHAT_MAX_MESSAGE_CHARS", 16000),
+		},
+		Generation: models.GenerationConfig{
+			AllowedModels:  getEnvAsList("CHAT_ALLOWED_MODELS"),
+			MaxTokens:      getEnvAsInt("CHAT_MAX_TOKENS_LIMIT", 4096),
+			TemperatureMin: getEnvAsFloat("CHAT_TEMPERATURE_MIN", 0),
+			TemperatureMax: getEnvAsFloat("CHAT_TEMPERATURE_MAX", 2),
+			TopPMin:        getEnvAsFloat("CHAT_TOP_P_MIN", 0),
+			TopPMax:        getEnvAsFloat("CHAT_TOP_P_MAX", 1),
+		},
+		Batch: models.BatchConfig{
+			Workers:     getEnvAsInt("BATCH_WORKERS", 4),
+			ItemTimeout: time.Duration(getEnvAsInt("BATCH_ITEM_TIMEOUT_SECONDS", 60)) * time.Second,
+			MaxItems:    getEnvAsInt("BATCH_MAX_ITEMS", 50),
+		},
+		WebSocket: models.WebSocketConfig{
+			MaxInFlight: getEnvAsInt("WS_MAX_IN_FLIGHT", 4),
+		},
+		Conversations: models.ConversationsConfig{
+			MaxConversations: getEnvAsInt("CONVERSATIONS_MAX", 1000),
+			TTL:              time.Duration(getEnvAsInt("CONVERSATIONS_TTL_MINUTES", 1440)) * time.Minute,
+		},
+		Jobs: models.JobsConfig{
+			Workers:     getEnvAsInt("JOBS_WORKERS", 2),
+			QueueSize:   getEnvAsInt("JOBS_QUEUE_SIZE", 100),
+			Timeout:     time.Duration(getEnvAsInt("JOBS_TIMEOUT_SECONDS", 600)) * time.Second,
+			StoreDir:    getEnv("JOBS_STORE_DIR", ""),
+			MaxFinished: getEnvAsInt("JOBS_MAX_FINISHED", 1000),
+			Retention:   time.Duration(getEnvAsInt("JOBS_RETENTION_MINUTES", 1440)) * time.Minute,
+		},
+		Webhooks: models.WebhookConfig{
+			Secret:         getEnv("WEBHOOK_SECRET", ""),
+			MaxAttempts:    getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 5),
+			InitialBackoff: time.Duration(getEnvAsInt("WEBHOOK_INITIAL_BACKOFF_SECONDS", 2)) * time.Second,
+			AllowedHosts:   getEnvAsList("WEBHOOK_ALLOWED_HOSTS"),
 		},
+		Prompts: getPromptsConfig(),
+		Experiments: models.ExperimentsConfig{
+			File:     getEnv("EXPERIMENTS_FILE", ""),
+			StoreDir: getEnv("EXPERIMENTS_STORE_DIR", ""),
+		},
+		Redaction: getRedactionConfig(),
+		Documents: models.DocumentsConfig{
+			Dir:          getEnv("DOCUMENTS_DIR", ""),
+			IndexDir:     getEnv("DOCUMENTS_INDEX_DIR", ""),
+			ChunkWords:   getEnvAsInt("DOCUMENTS_CHUNK_WORDS", 200),
+			ChunkOverlap: getEnvAsInt("DOCUMENTS_CHUNK_OVERLAP", 40),
+			TopK:         getEnvAsInt("DOCUMENTS_TOP_K", 4),
+			MinScore:     float64(getEnvAsFloat("DOCUMENTS_MIN_SCORE", 2)),
+		},
+		Cache: models.CacheConfig{
+			Backend:      getEnv("CACHE_BACKEND", "memory"),
+			MaxEntries:   getEnvAsInt("CACHE_MAX_ENTRIES", 1000),
+			ChatTTL:      time.Duration(getEnvAsInt("CACHE_CHAT_TTL_SECONDS", 3600)) * time.Second,
+			SmartChatTTL: time.Duration(getEnvAsInt("CACHE_SMART_CHAT_TTL_SECONDS", 600)) * time.Second,
+		},
+		Usage: getUsageConfig(),
+		// Chat completions have no side effects and may be repeated; Supabase writes may not.
+		// Webhook deliveries are retried by the dispatcher itself.
+		LLMHTTP:      getHTTPClientConfig("LLM", 120, 3, true),
+		SupabaseHTTP: getHTTPClientConfig("SUPABASE", 15, 3, false),
+		WebhookHTTP:  getHTTPClientConfig("WEBHOOK", 10, 1, false),
+		AdminAPIKey:  getEnv("ADMIN_API_KEY", ""),
+		APIKeys:      getAPIKeys(),
 	}
 
 	validateConfig()
@@ -60,6 +179,16 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsBool gets an environment variable as bool with default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 // getEnvAsFloat gets an environment variable as float32 with default value
 func getEnvAsFloat(key string, defaultValue float32) float32 {
 	if value := os.Getenv(key); value != "" {
@@ -70,6 +199,194 @@ func getEnvAsFloat(key string, defaultValue float32) float32 {
 	return defaultValue
 }
 
+// getEnvAsList gets an environment variable as a comma-separated list, skipping empty entries
+func getEnvAsList(key string) []string {
+	var list []string
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// llmProviders are the provider names accepted in LLM_PROVIDER and LLM_FALLBACKS
+var llmProviders = []string{"openai", "azure", "local", "fake"}
+
+// getLLMConfig reads the primary LLM provider and its fallback chain
+func getLLMConfig() models.LLMConfig {
+	primary := models.LLMConfig{
+		Provider:        getEnv("LLM_PROVIDER", "openai"),
+		APIKey:          getEnv("LLM_API_KEY", getEnv("OPENAI_API_KEY", "")),
+		BaseURL:         getEnv("LLM_BASE_URL", ""),
+		APIVersion:      getEnv("LLM_API_VERSION", ""),
+		AzureDeployment: getEnv("AZURE_OPENAI_DEPLOYMENT", ""),
+		FakeScript:      getEnv("LLM_FAKE_SCRIPT", ""),
+		ContextWindow:   getEnvAsInt("LLM_CONTEXT_WINDOW", 0),
+	}
+
+	// LLM_FALLBACKS is an ordered list of "[provider:]model" entries, e.g. "gpt-4o-mini,local:llama3.1:8b"
+	for _, entry := range strings.Split(getEnv("LLM_FALLBACKS", ""), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fallback := models.LLMFallback{LLMConfig: primary, Model: entry}
+		fallback.Fallbacks = nil
+		if name, model, found := strings.Cut(entry, ":"); found && isLLMProvider(name) {
+			fallback.Model = model
+			if name != primary.Provider {
+				fallback.LLMConfig = getFallbackProviderConfig(name)
+			}
+		}
+		primary.Fallbacks = append(primary.Fallbacks, fallback)
+	}
+
+	return primary
+}
+
+// getPromptsConfig reads the prompt template settings. PROMPT_VERSIONS pins prompts
+// as "name=version" entries, e.g. "analysis=v1,narration=v2".
+func getPromptsConfig() models.PromptsConfig {
+	cfg := models.PromptsConfig{Dir: getEnv("PROMPTS_DIR", ""), Versions: make(map[string]string)}
+
+	for _, entry := range strings.Split(getEnv("PROMPT_VERSIONS", ""), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, version, found := strings.Cut(entry, "=")
+		if !found || name == "" || version == "" {
+			log.Printf("Ignoring invalid PROMPT_VERSIONS entry %q", entry)
+			continue
+		}
+		cfg.Versions[strings.TrimSpace(name)] = strings.TrimSpace(version)
+	}
+
+	return cfg
+}
+
+// getRedactionConfig reads the personal data masking settings
+func getRedactionConfig() models.RedactionConfig {
+	cfg := models.RedactionConfig{
+		Enabled:         getEnvAsBool("PII_REDACTION_ENABLED", true),
+		FullAccessRoles: getEnvAsList("PII_FULL_ACCESS_ROLES"),
+	}
+	if len(cfg.FullAccessRoles) == 0 {
+		cfg.FullAccessRoles = []string{"auditor"}
+	}
+	return cfg
+}
+
+// defaultAPIKeyRole is the role of API keys configured without one
+const defaultAPIKeyRole = "viewer"
+
+// getAPIKeys reads the API keys. API_KEYS lists "key=caller[:role]" entries,
+// e.g. "k-123=auditoria:auditor,k-456=painel".
+func getAPIKeys() []models.APIKey {
+	var keys []models.APIKey
+	for _, entry := range getEnvAsList("API_KEYS") {
+		key, identity, _ := strings.Cut(entry, "=")
+		caller, role, _ := strings.Cut(identity, ":")
+		key, caller, role = strings.TrimSpace(key), strings.TrimSpace(caller), strings.TrimSpace(role)
+		if key == "" || caller == "" {
+			// The entry holds a secret, so it is not logged
+			log.Printf("Ignoring invalid API_KEYS entry for caller %q", caller)
+			continue
+		}
+		if role == "" {
+			role = defaultAPIKeyRole
+		}
+		keys = append(keys, models.APIKey{Key: key, Caller: caller, Role: role})
+	}
+	return keys
+}
+
+// getUsageConfig reads the usage accounting settings. USAGE_PRICES lists
+// "model=input:output" entries in USD per million tokens, e.g. "gpt-4o=2.5:10".
+func getUsageConfig() models.UsageConfig {
+	cfg := models.UsageConfig{StoreDir: getEnv("USAGE_STORE_DIR", "")}
+
+	for _, entry := range strings.Split(getEnv("USAGE_PRICES", ""), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		model, prices, _ := strings.Cut(entry, "=")
+		input, output, _ := strings.Cut(prices, ":")
+		inputPrice, inErr := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		outputPrice, outErr := strconv.ParseFloat(strings.TrimSpace(output), 64)
+		if model == "" || inErr != nil || outErr != nil {
+			log.Printf("Ignoring invalid USAGE_PRICES entry %q", entry)
+			continue
+		}
+		cfg.Prices = append(cfg.Prices, models.ModelPrice{Model: strings.TrimSpace(model), Input: inputPrice, Output: outputPrice})
+	}
+
+	return cfg
+}
+
+// getFallbackProviderConfig reads the settings of a fallback provider other than the primary one
+// from LLM_<PROVIDER>_* variables
+func getFallbackProviderConfig(name string) models.LLMConfig {
+	prefix := "LLM_" + strings.ToUpper(name)
+	apiKey := getEnv(prefix+"_API_KEY", "")
+	if name == "openai" {
+		apiKey = getEnv(prefix+"_API_KEY", getEnv("OPENAI_API_KEY", ""))
+	}
+	return models.LLMConfig{
+		Provider:        name,
+		APIKey:          apiKey,
+		BaseURL:         getEnv(prefix+"_BASE_URL", ""),
+		APIVersion:      getEnv(prefix+"_API_VERSION", ""),
+		AzureDeployment: getEnv(prefix+"_DEPLOYMENT", ""),
+		FakeScript:      getEnv(prefix+"_SCRIPT", ""),
+	}
+}
+
+// isLLMProvider reports whether name is a known LLM provider
+func isLLMProvider(name string) bool {
+	for _, provider := range llmProviders {
+		if provider == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getStageConfig reads the model settings of a smart chat stage; the model defaults to OPENAI_MODEL
+func getStageConfig(stage, defaultModel string, defaultMaxTokens int, defaultTemperature float32, defaultTimeoutSeconds int) models.StageConfig {
+	prefix := "SMART_CHAT_" + stage
+	return models.StageConfig{
+		Model:       getEnv(prefix+"_MODEL", defaultModel),
+		MaxTokens:   getEnvAsInt(prefix+"_MAX_TOKENS", defaultMaxTokens),
+		Temperature: getEnvAsFloat(prefix+"_TEMPERATURE", defaultTemperature),
+		Timeout:     time.Duration(getEnvAsInt(prefix+"_TIMEOUT_SECONDS", defaultTimeoutSeconds)) * time.Second,
+	}
+}
+
+// getHTTPClientConfig reads the HTTP client settings of an upstream; the proxy and CA file are shared
+func getHTTPClientConfig(prefix string, defaultTimeoutSeconds, defaultRetryAttempts int, retryNonIdempotent bool) models.HTTPClientConfig {
+	return models.HTTPClientConfig{
+		Timeout:             time.Duration(getEnvAsInt(prefix+"_TIMEOUT_SECONDS", defaultTimeoutSeconds)) * time.Second,
+		DialTimeout:         time.Duration(getEnvAsInt(prefix+"_DIAL_TIMEOUT_SECONDS", 5)) * time.Second,
+		IdleConnTimeout:     time.Duration(getEnvAsInt(prefix+"_IDLE_CONN_TIMEOUT_SECONDS", 90)) * time.Second,
+		MaxIdleConnsPerHost: getEnvAsInt(prefix+"_MAX_IDLE_CONNS_PER_HOST", 32),
+		MaxConnsPerHost:     getEnvAsInt(prefix+"_MAX_CONNS_PER_HOST", 0),
+		ProxyURL:            getEnv("HTTP_PROXY_URL", ""),
+		CAFile:              getEnv("HTTP_CA_FILE", ""),
+		Retry: models.RetryConfig{
+			MaxAttempts:    getEnvAsInt(prefix+"_RETRY_MAX_ATTEMPTS", defaultRetryAttempts),
+			InitialBackoff: time.Duration(getEnvAsInt(prefix+"_RETRY_INITIAL_BACKOFF_MS", 200)) * time.Millisecond,
+			MaxBackoff:     time.Duration(getEnvAsInt(prefix+"_RETRY_MAX_BACKOFF_MS", 5000)) * time.Millisecond,
+			Deadline:       time.Duration(getEnvAsInt(prefix+"_RETRY_DEADLINE_SECONDS", 2*defaultTimeoutSeconds)) * time.Second,
+			NonIdempotent:  getEnvAsBool(prefix+"_RETRY_NON_IDEMPOTENT", retryNonIdempotent),
+		},
+	}
+}
+
 // validateConfig validates required configurations
 func validateConfig() {
 	warnings := []string{}
@@ -80,8 +397,21 @@ func validateConfig() {
 	if AppConfig.Supabase.APIKey == "" {
 		warnings = append(warnings, "SUPABASE_API_KEY not configured")
 	}
-	if AppConfig.OpenAI.APIKey == "" {
-		warnings = append(warnings, "OPENAI_API_KEY not configured")
+	switch AppConfig.LLM.Provider {
+	case "openai", "azure":
+		if AppConfig.LLM.APIKey == "" {
+			warnings = append(warnings, "OPENAI_API_KEY not configured")
+		}
+	case "local":
+		if AppConfig.LLM.BaseURL == "" {
+			warnings = append(warnings, "LLM_BASE_URL not configured")
+		}
+	}
+	if mode := AppConfig.SmartChat.Mode; mode != "sql" && mode != "tools" {
+		warnings = append(warnings, "SMART_CHAT_MODE must be sql or tools, got "+mode)
+	}
+	if policy := AppConfig.Chat.SystemPromptPolicy; policy != "prepend" && policy != "replace" && policy != "forbid" {
+		warnings = append(warnings, "CHAT_SYSTEM_PROMPT_POLICY must be prepend, replace or forbid, got "+policy)
 	}
 
 	if len(warnings) > 0 {
@@ -92,4 +422,4 @@ func validateConfig() {
 	} else {
 		log.Println("All configurations loaded successfully")
 	}
-}
\ No newline at end of file
+}
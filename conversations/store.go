@@ -0,0 +1,156 @@
+package conversations
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Message represents a single turn of a conversation
+type Message struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Conversation represents the transcript of a conversation
+type Conversation struct {
+	ID string `json:"id"`
+	// Owner is the authenticated caller that recorded the conversation; empty for anonymous callers
+	Owner     string    `json:"owner,omitempty"`
+	Messages  []Message `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// key identifies a conversation within the conversations of its owner, so owners never
+// see or extend each other's transcripts even when they pick the same id
+type key struct {
+	owner string
+	id    string
+}
+
+// Store keeps conversation transcripts in memory. Conversations idle for longer than the TTL
+// expire, and the least recently updated ones are evicted beyond the maximum count.
+type Store struct {
+	mu               sync.RWMutex
+	conversations    map[key]*Conversation
+	maxConversations int
+	ttl              time.Duration
+}
+
+// Default is the store shared by every transport
+var Default = NewStore(0, 0)
+
+// NewStore creates an empty conversation store. Zero maxConversations or ttl means no bound.
+func NewStore(maxConversations int, ttl time.Duration) *Store {
+	return &Store{
+		conversations:    make(map[key]*Conversation),
+		maxConversations: maxConversations,
+		ttl:              ttl,
+	}
+}
+
+// Append adds messages to a conversation of an owner, creating it when needed
+func (s *Store) Append(owner, id string, messages ...Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	k := key{owner, id}
+	conversation, ok := s.conversations[k]
+	if !ok || s.expired(conversation, now) {
+		delete(s.conversations, k)
+		s.makeRoom(now)
+		conversation = &Conversation{ID: id, Owner: owner, CreatedAt: now}
+		s.conversations[k] = conversation
+	}
+
+	for _, message := range messages {
+		if message.CreatedAt.IsZero() {
+			message.CreatedAt = now
+		}
+		conversation.Messages = append(conversation.Messages, message)
+	}
+	conversation.UpdatedAt = now
+}
+
+// Get returns a copy of a conversation of an owner
+func (s *Store) Get(owner, id string) (Conversation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conversation, ok := s.conversations[key{owner, id}]
+	if !ok || s.expired(conversation, time.Now()) {
+		return Conversation{}, false
+	}
+	return copyConversation(conversation), true
+}
+
+// List returns the conversations of an owner, most recently updated first
+func (s *Store) List(owner string) []Conversation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	list := make([]Conversation, 0)
+	for k, conversation := range s.conversations {
+		if k.owner == owner && !s.expired(conversation, now) {
+			list = append(list, copyConversation(conversation))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UpdatedAt.After(list[j].UpdatedAt)
+	})
+	return list
+}
+
+// Delete removes a conversation of an owner, reporting whether it existed
+func (s *Store) Delete(owner, id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{owner, id}
+	conversation, ok := s.conversations[k]
+	if !ok {
+		return false
+	}
+	delete(s.conversations, k)
+	return !s.expired(conversation, time.Now())
+}
+
+// expired reports whether a conversation has been idle for longer than the TTL
+func (s *Store) expired(conversation *Conversation, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(conversation.UpdatedAt) > s.ttl
+}
+
+// makeRoom drops expired conversations, then the least recently updated ones until a new
+// conversation fits within the maximum count. The lock must be held.
+func (s *Store) makeRoom(now time.Time) {
+	for k, conversation := range s.conversations {
+		if s.expired(conversation, now) {
+			delete(s.conversations, k)
+		}
+	}
+	if s.maxConversations <= 0 || len(s.conversations) < s.maxConversations {
+		return
+	}
+
+	keys := make([]key, 0, len(s.conversations))
+	for k := range s.conversations {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return s.conversations[keys[i]].UpdatedAt.Before(s.conversations[keys[j]].UpdatedAt)
+	})
+	for _, k := range keys[:len(keys)-s.maxConversations+1] {
+		delete(s.conversations, k)
+	}
+}
+
+// copyConversation copies a conversation so callers cannot mutate the store
+func copyConversation(c *Conversation) Conversation {
+	copied := *c
+	copied.Messages = append([]Message(nil), c.Messages...)
+	return copied
+}
@@ -0,0 +1,346 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: credibotpb/credibot.proto
+
+package credibotpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Credibot_Chat_FullMethodName               = "/credibot.v1.Credibot/Chat"
+	Credibot_SmartChat_FullMethodName          = "/credibot.v1.Credibot/SmartChat"
+	Credibot_GetData_FullMethodName            = "/credibot.v1.Credibot/GetData"
+	Credibot_ListConversations_FullMethodName  = "/credibot.v1.Credibot/ListConversations"
+	Credibot_GetConversation_FullMethodName    = "/credibot.v1.Credibot/GetConversation"
+	Credibot_DeleteConversation_FullMethodName = "/credibot.v1.Credibot/DeleteConversation"
+)
+
+// CredibotClient is the client API for Credibot service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Credibot exposes the same chat, smart chat and data features as the REST API
+type CredibotClient interface {
+	// Chat sends a single message to the model
+	Chat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	// SmartChat answers a question consulting the credit database when needed,
+	// streaming partial answers followed by the complete response
+	SmartChat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (Credibot_SmartChatClient, error)
+	// GetData reads rows from a table (read-only)
+	GetData(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*GetDataResponse, error)
+	// ListConversations lists the recorded conversations
+	ListConversations(ctx context.Context, in *ListConversationsRequest, opts ...grpc.CallOption) (*ListConversationsResponse, error)
+	// GetConversation returns the transcript of a conversation
+	GetConversation(ctx context.Context, in *GetConversationRequest, opts ...grpc.CallOption) (*Conversation, error)
+	// DeleteConversation removes a conversation transcript
+	DeleteConversation(ctx context.Context, in *DeleteConversationRequest, opts ...grpc.CallOption) (*DeleteConversationResponse, error)
+}
+
+type credibotClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCredibotClient(cc grpc.ClientConnInterface) CredibotClient {
+	return &credibotClient{cc}
+}
+
+func (c *credibotClient) Chat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, Credibot_Chat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *credibotClient) SmartChat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (Credibot_SmartChatClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Credibot_ServiceDesc.Streams[0], Credibot_SmartChat_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &credibotSmartChatClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Credibot_SmartChatClient interface {
+	Recv() (*SmartChatEvent, error)
+	grpc.ClientStream
+}
+
+type credibotSmartChatClient struct {
+	grpc.ClientStream
+}
+
+func (x *credibotSmartChatClient) Recv() (*SmartChatEvent, error) {
+	m := new(SmartChatEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *credibotClient) GetData(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*GetDataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetDataResponse)
+	err := c.cc.Invoke(ctx, Credibot_GetData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *credibotClient) ListConversations(ctx context.Context, in *ListConversationsRequest, opts ...grpc.CallOption) (*ListConversationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListConversationsResponse)
+	err := c.cc.Invoke(ctx, Credibot_ListConversations_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *credibotClient) GetConversation(ctx context.Context, in *GetConversationRequest, opts ...grpc.CallOption) (*Conversation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Conversation)
+	err := c.cc.Invoke(ctx, Credibot_GetConversation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *credibotClient) DeleteConversation(ctx context.Context, in *DeleteConversationRequest, opts ...grpc.CallOption) (*DeleteConversationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteConversationResponse)
+	err := c.cc.Invoke(ctx, Credibot_DeleteConversation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CredibotServer is the server API for Credibot service.
+// All implementations must embed UnimplementedCredibotServer
+// for forward compatibility
+//
+// Credibot exposes the same chat, smart chat and data features as the REST API
+type CredibotServer interface {
+	// Chat sends a single message to the model
+	Chat(context.Context, *ChatRequest) (*ChatResponse, error)
+	// SmartChat answers a question consulting the credit database when needed,
+	// streaming partial answers followed by the complete response
+	SmartChat(*ChatRequest, Credibot_SmartChatServer) error
+	// GetData reads rows from a table (read-only)
+	GetData(context.Context, *GetDataRequest) (*GetDataResponse, error)
+	// ListConversations lists the recorded conversations
+	ListConversations(context.Context, *ListConversationsRequest) (*ListConversationsResponse, error)
+	// GetConversation returns the transcript of a conversation
+	GetConversation(context.Context, *GetConversationRequest) (*Conversation, error)
+	// DeleteConversation removes a conversation transcript
+	DeleteConversation(context.Context, *DeleteConversationRequest) (*DeleteConversationResponse, error)
+	mustEmbedUnimplementedCredibotServer()
+}
+
+// UnimplementedCredibotServer must be embedded to have forward compatible implementations.
+type UnimplementedCredibotServer struct {
+}
+
+func (UnimplementedCredibotServer) Chat(context.Context, *ChatRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Chat not implemented")
+}
+func (UnimplementedCredibotServer) SmartChat(*ChatRequest, Credibot_SmartChatServer) error {
+	return status.Errorf(codes.Unimplemented, "method SmartChat not implemented")
+}
+func (UnimplementedCredibotServer) GetData(context.Context, *GetDataRequest) (*GetDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetData not implemented")
+}
+func (UnimplementedCredibotServer) ListConversations(context.Context, *ListConversationsRequest) (*ListConversationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConversations not implemented")
+}
+func (UnimplementedCredibotServer) GetConversation(context.Context, *GetConversationRequest) (*Conversation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConversation not implemented")
+}
+func (UnimplementedCredibotServer) DeleteConversation(context.Context, *DeleteConversationRequest) (*DeleteConversationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteConversation not implemented")
+}
+func (UnimplementedCredibotServer) mustEmbedUnimplementedCredibotServer() {}
+
+// UnsafeCredibotServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CredibotServer will
+// result in compilation errors.
+type UnsafeCredibotServer interface {
+	mustEmbedUnimplementedCredibotServer()
+}
+
+func RegisterCredibotServer(s grpc.ServiceRegistrar, srv CredibotServer) {
+	s.RegisterService(&Credibot_ServiceDesc, srv)
+}
+
+func _Credibot_Chat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CredibotServer).Chat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Credibot_Chat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CredibotServer).Chat(ctx, req.(*ChatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Credibot_SmartChat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChatRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CredibotServer).SmartChat(m, &credibotSmartChatServer{ServerStream: stream})
+}
+
+type Credibot_SmartChatServer interface {
+	Send(*SmartChatEvent) error
+	grpc.ServerStream
+}
+
+type credibotSmartChatServer struct {
+	grpc.ServerStream
+}
+
+func (x *credibotSmartChatServer) Send(m *SmartChatEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Credibot_GetData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CredibotServer).GetData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Credibot_GetData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CredibotServer).GetData(ctx, req.(*GetDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Credibot_ListConversations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConversationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CredibotServer).ListConversations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Credibot_ListConversations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CredibotServer).ListConversations(ctx, req.(*ListConversationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Credibot_GetConversation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConversationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CredibotServer).GetConversation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Credibot_GetConversation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CredibotServer).GetConversation(ctx, req.(*GetConversationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Credibot_DeleteConversation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteConversationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CredibotServer).DeleteConversation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Credibot_DeleteConversation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CredibotServer).DeleteConversation(ctx, req.(*DeleteConversationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Credibot_ServiceDesc is the grpc.ServiceDesc for Credibot service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Credibot_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "credibot.v1.Credibot",
+	HandlerType: (*CredibotServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Chat",
+			Handler:    _Credibot_Chat_Handler,
+		},
+		{
+			MethodName: "GetData",
+			Handler:    _Credibot_GetData_Handler,
+		},
+		{
+			MethodName: "ListConversations",
+			Handler:    _Credibot_ListConversations_Handler,
+		},
+		{
+			MethodName: "GetConversation",
+			Handler:    _Credibot_GetConversation_Handler,
+		},
+		{
+			MethodName: "DeleteConversation",
+			Handler:    _Credibot_DeleteConversation_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SmartChat",
+			Handler:       _Credibot_SmartChat_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "credibotpb/credibot.proto",
+}
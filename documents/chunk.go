@@ -0,0 +1,156 @@
+package documents
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Chunk is a passage of a document, the unit the index retrieves
+type Chunk struct {
+	// Document is the path of the document relative to the documents directory
+	Document string `json:"document"`
+	// Title is the first top-level heading of the document, or its file name
+	Title string `json:"title"`
+	// Section is the heading path of the passage, e.g. "Limites > Pessoa Física"; empty
+	// for documents without headings
+	Section string `json:"section,omitempty"`
+	Text    string `json:"text"`
+}
+
+// headingPattern matches Markdown ATX headings
+var headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// section is a heading path and the text under it
+type section struct {
+	path []string
+	text strings.Builder
+}
+
+// readDocument reads a document's sections. Markdown headings split the document;
+// text and PDF documents are a single section.
+func readDocument(path string) (title string, sections []*section, err error) {
+	var text string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pdf":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, err
+		}
+		text, err = pdfText(data)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+		}
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, err
+		}
+		text = string(data)
+	}
+
+	markdown := isMarkdown(path)
+	current := &section{}
+	sections = []*section{current}
+	var headings []string
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	inFence := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if markdown && strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if match := headingPattern.FindStringSubmatch(line); markdown && !inFence && match != nil {
+			level, heading := len(match[1]), match[2]
+			if level == 1 && title == "" {
+				title = heading
+			}
+			if level > len(headings) {
+				level = len(headings) + 1
+			}
+			headings = append(headings[:level-1], heading)
+			current = &section{path: append([]string(nil), headings...)}
+			sections = append(sections, current)
+			continue
+		}
+		current.text.WriteString(line)
+		current.text.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, err
+	}
+
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return title, sections, nil
+}
+
+// chunkSection splits the text of a section into passages of at most size words, each one
+// repeating the last overlap words of the previous passage
+func chunkSection(text string, size, overlap int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	if overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	for start := 0; ; start += size - overlap {
+		end := start + size
+		if end > len(words) {
+			end = len(words)
+		}
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			return chunks
+		}
+	}
+}
+
+// chunkDocument reads a document and splits it into passages
+func chunkDocument(dir, name string, opts Options) ([]Chunk, error) {
+	title, sections, err := readDocument(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []Chunk
+	for _, s := range sections {
+		// The document title is the top heading of every section, so it is left out of the path
+		path := s.path
+		if len(path) > 0 && path[0] == title {
+			path = path[1:]
+		}
+		for _, text := range chunkSection(s.text.String(), opts.ChunkWords, opts.ChunkOverlap) {
+			chunks = append(chunks, Chunk{
+				Document: filepath.ToSlash(name),
+				Title:    title,
+				Section:  strings.Join(path, " > "),
+				Text:     text,
+			})
+		}
+	}
+	return chunks, nil
+}
+
+// supported reports whether a file is a document the library reads
+func supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".txt", ".pdf":
+		return true
+	}
+	return false
+}
+
+func isMarkdown(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
+}
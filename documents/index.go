@@ -0,0 +1,157 @@
+package documents
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: term frequency saturation and length normalization
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// indexedChunk is a chunk and the frequency of its terms
+type indexedChunk struct {
+	Chunk
+	Terms  map[string]int `json:"terms"`
+	Length int            `json:"length"`
+}
+
+// index ranks chunks against a query with BM25
+type index struct {
+	chunks    []indexedChunk
+	docFreq   map[string]int
+	avgLength float64
+}
+
+// newIndex computes the corpus statistics of the chunks
+func newIndex(chunks []indexedChunk) *index {
+	ix := &index{chunks: chunks, docFreq: make(map[string]int)}
+	total := 0
+	for _, chunk := range chunks {
+		total += chunk.Length
+		for term := range chunk.Terms {
+			ix.docFreq[term]++
+		}
+	}
+	if len(chunks) > 0 {
+		ix.avgLength = float64(total) / float64(len(chunks))
+	}
+	return ix
+}
+
+// indexChunk counts the terms of a chunk, including its title and section
+func indexChunk(chunk Chunk) indexedChunk {
+	terms := tokenize(chunk.Title + " " + chunk.Section + " " + chunk.Text)
+	freq := make(map[string]int, len(terms))
+	for _, term := range terms {
+		freq[term]++
+	}
+	return indexedChunk{Chunk: chunk, Terms: freq, Length: len(terms)}
+}
+
+// search returns the k best chunks scoring at least minScore, best first
+func (ix *index) search(query string, k int, minScore float64) []Passage {
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 || len(ix.chunks) == 0 {
+		return nil
+	}
+
+	n := float64(len(ix.chunks))
+	var passages []Passage
+	for _, chunk := range ix.chunks {
+		score := 0.0
+		for _, term := range terms {
+			tf := float64(chunk.Terms[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(ix.docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - bm25B + bm25B*float64(chunk.Length)/ix.avgLength
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+		if score > 0 && score >= minScore {
+			passages = append(passages, Passage{Chunk: chunk.Chunk, Score: math.Round(score*1000) / 1000})
+		}
+	}
+
+	sort.SliceStable(passages, func(i, j int) bool { return passages[i].Score > passages[j].Score })
+	if k > 0 && len(passages) > k {
+		passages = passages[:k]
+	}
+	return passages
+}
+
+// stopwords are frequent Portuguese words that carry no meaning for retrieval
+var stopwords = map[string]bool{
+	"a": true, "o": true, "as": true, "os": true, "um": true, "uma": true, "uns": true, "umas": true,
+	"de": true, "da": true, "do": true, "das": true, "dos": true, "em": true, "na": true, "no": true,
+	"nas": true, "nos": true, "por": true, "pela": true, "pelo": true, "para": true, "pra": true,
+	"com": true, "sem": true, "e": true, "ou": true, "que": true, "se": true, "ao": true, "aos": true,
+	"como": true, "qual": true, "quais": true, "quando": true, "onde": true, "ser": true, "sao": true,
+	"ha": true, "mais": true, "menos": true, "muito": true, "nao": true, "sim": true, "este": true,
+	"esta": true, "esse": true, "essa": true, "isso": true, "isto": true, "seu": true, "sua": true,
+	"seus": true, "suas": true, "ele": true, "ela": true, "eles": true, "elas": true, "voce": true,
+	"nosso": true, "nossa": true, "tem": true, "pode": true, "deve": true, "sobre": true, "entre": true,
+}
+
+// accents folds the accented letters of Portuguese, so "crédito" matches "credito"
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// tokenize lowercases text, folds accents and splits it into terms, leaving out stopwords
+func tokenize(text string) []string {
+	text = accents.Replace(strings.ToLower(text))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := words[:0]
+	for _, word := range words {
+		if stopwords[word] {
+			continue
+		}
+		terms = append(terms, stem(word))
+	}
+	return terms
+}
+
+// stem strips the plural endings of Portuguese words, so "taxas" matches "taxa"
+func stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "oes"), strings.HasSuffix(word, "aes"):
+		return word[:len(word)-3] + "ao"
+	case strings.HasSuffix(word, "ais"):
+		return word[:len(word)-2] + "l"
+	case strings.HasSuffix(word, "res"), strings.HasSuffix(word, "zes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := terms[:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
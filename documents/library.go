@@ -0,0 +1,212 @@
+package documents
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// indexFile is the name of the saved index in the index directory
+const indexFile = "documents-index.json"
+
+// Options control how documents are split into passages
+type Options struct {
+	// ChunkWords is the most words a passage holds
+	ChunkWords int `json:"chunk_words"`
+	// ChunkOverlap is how many words a passage repeats from the previous one
+	ChunkOverlap int `json:"chunk_overlap"`
+}
+
+// Passage is a retrieved chunk and its relevance score
+type Passage struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// Info describes an indexed document
+type Info struct {
+	Document string    `json:"document"`
+	Title    string    `json:"title"`
+	Chunks   int       `json:"chunks"`
+	Modified time.Time `json:"modified"`
+}
+
+// source identifies the version of a document file, to tell when the saved index is stale
+type source struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// savedIndex is the index as written to disk
+type savedIndex struct {
+	Options Options        `json:"options"`
+	Sources []source       `json:"sources"`
+	Chunks  []indexedChunk `json:"chunks"`
+}
+
+// Library indexes the Markdown, text and PDF documents of a directory and retrieves the
+// passages relevant to a question. With an index directory the index is saved there and
+// reused on startup while the documents are unchanged.
+type Library struct {
+	dir      string
+	indexDir string
+	opts     Options
+
+	mu      sync.RWMutex
+	index   *index
+	sources []source
+}
+
+// Open indexes the documents in dir. An empty dir gives an empty library that finds nothing.
+func Open(dir, indexDir string, opts Options) (*Library, error) {
+	l := &Library{dir: dir, indexDir: indexDir, opts: opts, index: newIndex(nil)}
+	if dir == "" {
+		return l, nil
+	}
+	if opts.ChunkWords <= 0 || opts.ChunkOverlap < 0 || opts.ChunkOverlap >= opts.ChunkWords {
+		return nil, fmt.Errorf("chunk size must be positive and larger than the overlap, got %d and %d", opts.ChunkWords, opts.ChunkOverlap)
+	}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload indexes the documents again, reusing the saved index when no document changed.
+// On error the index in use is kept.
+func (l *Library) Reload() error {
+	if l.dir == "" {
+		return nil
+	}
+
+	sources, err := l.scan()
+	if err != nil {
+		return err
+	}
+
+	chunks, ok := l.loadSaved(sources)
+	if !ok {
+		chunks = nil
+		for _, src := range sources {
+			docChunks, err := chunkDocument(l.dir, src.Name, l.opts)
+			if err != nil {
+				return err
+			}
+			for _, chunk := range docChunks {
+				chunks = append(chunks, indexChunk(chunk))
+			}
+		}
+		if err := l.save(savedIndex{Options: l.opts, Sources: sources, Chunks: chunks}); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	l.index = newIndex(chunks)
+	l.sources = sources
+	l.mu.Unlock()
+	return nil
+}
+
+// Search returns the k passages most relevant to a query scoring at least minScore, best first
+func (l *Library) Search(query string, k int, minScore float64) []Passage {
+	l.mu.RLock()
+	ix := l.index
+	l.mu.RUnlock()
+	return ix.search(query, k, minScore)
+}
+
+// List describes the indexed documents, sorted by path
+func (l *Library) List() []Info {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	byDocument := make(map[string]*Info, len(l.sources))
+	list := make([]Info, len(l.sources))
+	for i, src := range l.sources {
+		list[i] = Info{Document: filepath.ToSlash(src.Name), Modified: src.Modified}
+		byDocument[list[i].Document] = &list[i]
+	}
+	for _, chunk := range l.index.chunks {
+		if info, ok := byDocument[chunk.Document]; ok {
+			info.Title = chunk.Title
+			info.Chunks++
+		}
+	}
+	return list
+}
+
+// scan lists the supported documents under the documents directory
+func (l *Library) scan() ([]source, error) {
+	var sources []source
+	err := filepath.WalkDir(l.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !supported(path) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source{Name: name, Size: info.Size(), Modified: info.ModTime().UTC()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read documents directory: %w", err)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return sources, nil
+}
+
+// loadSaved reads the saved index when it was built from the same documents and options
+func (l *Library) loadSaved(sources []source) ([]indexedChunk, bool) {
+	if l.indexDir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(l.indexDir, indexFile))
+	if err != nil {
+		return nil, false
+	}
+	var saved savedIndex
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Options != l.opts || len(saved.Sources) != len(sources) {
+		return nil, false
+	}
+	for i, src := range saved.Sources {
+		if src.Name != sources[i].Name || src.Size != sources[i].Size || !src.Modified.Equal(sources[i].Modified) {
+			return nil, false
+		}
+	}
+	return saved.Chunks, true
+}
+
+// save writes the index to the index directory, replacing the previous one atomically
+func (l *Library) save(saved savedIndex) error {
+	if l.indexDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(l.indexDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create documents index directory: %w", err)
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(l.indexDir, indexFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
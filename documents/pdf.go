@@ -0,0 +1,207 @@
+package documents
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// errNoText is returned for PDFs without extractable text, e.g. scanned ones
+var errNoText = errors.New("no text found in PDF; scanned documents must be converted to text first")
+
+// pdfText extracts the text shown by the content streams of a PDF. It reads uncompressed and
+// FlateDecode streams and the literal strings of the Tj, TJ, ' and " operators, which covers
+// PDFs exported by word processors with standard fonts. Fonts with custom encodings may come
+// out garbled; such documents are better converted to text before ingestion.
+func pdfText(data []byte) (string, error) {
+	var text strings.Builder
+	for rest := data; ; {
+		start := bytes.Index(rest, []byte("stream"))
+		if start < 0 {
+			break
+		}
+		dict := streamDictionary(rest[:start])
+		body := rest[start+len("stream"):]
+		body = bytes.TrimPrefix(body, []byte("\r"))
+		body = bytes.TrimPrefix(body, []byte("\n"))
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		content := body[:end]
+		rest = body[end+len("endstream"):]
+
+		// Fonts, images and other binary streams hold no page text
+		if bytes.Contains(dict, []byte("/Image")) || bytes.Contains(dict, []byte("/Length1")) ||
+			bytes.Contains(dict, []byte("/FontFile")) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			inflated, err := inflate(content)
+			if err != nil {
+				continue
+			}
+			content = inflated
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+		text.WriteString(contentText(content))
+	}
+
+	if strings.TrimSpace(text.String()) == "" {
+		return "", errNoText
+	}
+	return text.String(), nil
+}
+
+// streamDictionary returns the dictionary preceding a stream keyword
+func streamDictionary(before []byte) []byte {
+	if i := bytes.LastIndex(before, []byte("obj")); i >= 0 {
+		return before[i:]
+	}
+	return before
+}
+
+func inflate(data []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// Truncated streams still yield the text read so far
+	inflated, err := io.ReadAll(reader)
+	if len(inflated) == 0 {
+		return nil, err
+	}
+	return inflated, nil
+}
+
+// contentText reads the text operators of a page content stream. Text positioning
+// operators start new lines and wide gaps inside TJ arrays become spaces.
+func contentText(content []byte) string {
+	var (
+		text    strings.Builder
+		strs    []string
+		inArray bool
+	)
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; {
+		case c == '(':
+			s, next := literalString(content, i)
+			strs = append(strs, s)
+			i = next
+		case c == '[':
+			inArray = true
+		case c == ']':
+			inArray = false
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case inArray && (c == '-' || c >= '0' && c <= '9'):
+			// A kerning adjustment; large negative ones separate words
+			j := i
+			for j < len(content) && (content[j] == '-' || content[j] == '.' || content[j] >= '0' && content[j] <= '9') {
+				j++
+			}
+			if adjust, err := strconv.ParseFloat(string(content[i:j]), 64); err == nil && adjust < -200 {
+				strs = append(strs, " ")
+			}
+			i = j - 1
+		case isOperatorStart(c) && (i == 0 || isDelimiter(content[i-1])):
+			j := i
+			for j < len(content) && !isDelimiter(content[j]) {
+				j++
+			}
+			switch string(content[i:j]) {
+			case "Tj", "TJ":
+				text.WriteString(strings.Join(strs, ""))
+			case "'", "\"":
+				text.WriteByte('\n')
+				text.WriteString(strings.Join(strs, ""))
+			case "Td", "TD", "T*", "Tm", "ET":
+				if text.Len() > 0 && !strings.HasSuffix(text.String(), "\n") {
+					text.WriteByte('\n')
+				}
+			}
+			strs = strs[:0]
+			i = j - 1
+		}
+	}
+	if text.Len() > 0 {
+		text.WriteString("\n\n")
+	}
+	return text.String()
+}
+
+// literalString decodes the PDF literal string starting at content[start], returning
+// it and the index of its closing parenthesis
+func literalString(content []byte, start int) (string, int) {
+	var s []byte
+	depth := 0
+	for i := start; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\\' && i+1 < len(content):
+			i++
+			switch e := content[i]; e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// A line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					j := i
+					for j < len(content) && j < i+3 && content[j] >= '0' && content[j] <= '7' {
+						j++
+					}
+					code, _ := strconv.ParseUint(string(content[i:j]), 8, 8)
+					s = append(s, byte(code))
+					i = j - 1
+				} else {
+					s = append(s, e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				s = append(s, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return latin1(s), i
+			}
+			s = append(s, c)
+		default:
+			s = append(s, c)
+		}
+	}
+	return latin1(s), len(content)
+}
+
+// latin1 decodes the bytes of a string in a standard font encoding, which matches
+// Latin-1 for the accented letters of Portuguese
+func latin1(s []byte) string {
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n\f\x00()<>[]{}/%", c) >= 0
+}
+
+func isOperatorStart(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '\'' || c == '"' || c == '*'
+}
@@ -0,0 +1,233 @@
+package experiments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+// Stages whose model a variant may override, as named in usage records
+var Stages = []string{"analysis", "sql_generation", "response"}
+
+// Experiment splits smart chat requests between variants in proportion to their weights
+type Experiment struct {
+	ID       string    `json:"id"`
+	Variants []Variant `json:"variants"`
+}
+
+// Variant is one arm of an experiment. Prompts maps prompt names to the version to render
+// and Models maps stages to the model to call; a variant without overrides is the control.
+type Variant struct {
+	ID      string            `json:"id"`
+	Weight  int               `json:"weight"`
+	Prompts map[string]string `json:"prompts,omitempty"`
+	Models  map[string]string `json:"models,omitempty"`
+}
+
+// Set holds the running experiments
+type Set struct {
+	experiments []Experiment
+}
+
+// Load reads the experiments from a JSON file holding a list of experiments.
+// Without a file no experiment runs.
+func Load(path string) (*Set, error) {
+	if path == "" {
+		return &Set{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read experiments: %w", err)
+	}
+	var list []Experiment
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to read experiments: %w", err)
+	}
+	return New(list)
+}
+
+// New validates experiments. Two experiments may not override the same prompt or stage,
+// since a request takes part in every experiment at once.
+func New(list []Experiment) (*Set, error) {
+	ids := make(map[string]bool)
+	prompts := make(map[string]string)
+	models := make(map[string]string)
+	for _, experiment := range list {
+		if experiment.ID == "" {
+			return nil, fmt.Errorf("experiment id is required")
+		}
+		if ids[experiment.ID] {
+			return nil, fmt.Errorf("duplicate experiment %s", experiment.ID)
+		}
+		ids[experiment.ID] = true
+		if len(experiment.Variants) < 2 {
+			return nil, fmt.Errorf("experiment %s needs at least two variants", experiment.ID)
+		}
+
+		total := 0
+		variants := make(map[string]bool)
+		for _, variant := range experiment.Variants {
+			if variant.ID == "" || variants[variant.ID] {
+				return nil, fmt.Errorf("experiment %s has a missing or duplicate variant id", experiment.ID)
+			}
+			variants[variant.ID] = true
+			if variant.Weight < 0 {
+				return nil, fmt.Errorf("variant %s/%s has a negative weight", experiment.ID, variant.ID)
+			}
+			total += variant.Weight
+
+			for name := range variant.Prompts {
+				if owner, ok := prompts[name]; ok && owner != experiment.ID {
+					return nil, fmt.Errorf("prompt %s is overridden by experiments %s and %s", name, owner, experiment.ID)
+				}
+				prompts[name] = experiment.ID
+			}
+			for stage := range variant.Models {
+				if !isStage(stage) {
+					return nil, fmt.Errorf("variant %s/%s overrides unknown stage %s", experiment.ID, variant.ID, stage)
+				}
+				if owner, ok := models[stage]; ok && owner != experiment.ID {
+					return nil, fmt.Errorf("stage %s is overridden by experiments %s and %s", stage, owner, experiment.ID)
+				}
+				models[stage] = experiment.ID
+			}
+		}
+		if total == 0 {
+			return nil, fmt.Errorf("experiment %s has no weight", experiment.ID)
+		}
+	}
+	return &Set{experiments: list}, nil
+}
+
+// List returns the running experiments
+func (s *Set) List() []Experiment {
+	return s.experiments
+}
+
+// CheckPrompts fails when a variant renders a prompt version that is not loaded, as told by exists
+func (s *Set) CheckPrompts(exists func(name, version string) bool) error {
+	for _, experiment := range s.experiments {
+		for _, variant := range experiment.Variants {
+			for name, version := range variant.Prompts {
+				if !exists(name, version) {
+					return fmt.Errorf("variant %s/%s uses missing prompt %s@%s", experiment.ID, variant.ID, name, version)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Assign picks a variant of every experiment. The same unit, usually a user, always gets
+// the same variants while the experiments are unchanged; without a unit the pick is random.
+func (s *Set) Assign(unit string) Assignment {
+	var assignment Assignment
+	for _, experiment := range s.experiments {
+		total := 0
+		for _, variant := range experiment.Variants {
+			total += variant.Weight
+		}
+
+		var point int
+		if unit == "" {
+			point = rand.Intn(total)
+		} else {
+			hash := fnv.New64a()
+			hash.Write([]byte(experiment.ID + "/" + unit))
+			point = int(hash.Sum64() % uint64(total))
+		}
+
+		for _, variant := range experiment.Variants {
+			if point < variant.Weight {
+				assignment = append(assignment, Assigned{Experiment: experiment.ID, Variant: variant})
+				break
+			}
+			point -= variant.Weight
+		}
+	}
+	return assignment
+}
+
+// Assigned is the variant a request got in one experiment
+type Assigned struct {
+	Experiment string
+	Variant    Variant
+}
+
+// ID identifies the variant as <experiment>/<variant>
+func (a Assigned) ID() string {
+	return a.Experiment + "/" + a.Variant.ID
+}
+
+// Assignment is the variant a request got in each experiment
+type Assignment []Assigned
+
+// IDs returns the variant ids, sorted
+func (a Assignment) IDs() []string {
+	ids := make([]string, 0, len(a))
+	for _, assigned := range a {
+		ids = append(ids, assigned.ID())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Prompts returns the prompt versions the variants render
+func (a Assignment) Prompts() map[string]string {
+	versions := make(map[string]string)
+	for _, assigned := range a {
+		for name, version := range assigned.Variant.Prompts {
+			versions[name] = version
+		}
+	}
+	return versions
+}
+
+// Model returns the model a variant calls in a stage, or "" for the configured one
+func (a Assignment) Model(stage string) string {
+	for _, assigned := range a {
+		if model, ok := assigned.Variant.Models[stage]; ok {
+			return model
+		}
+	}
+	return ""
+}
+
+type userKey struct{}
+type assignmentKey struct{}
+
+// WithUser records the user of a request, the unit of sticky assignment
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// User returns the user of a request, or "" when unknown
+func User(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// WithAssignment attaches the variants of a request to the context
+func WithAssignment(ctx context.Context, assignment Assignment) context.Context {
+	return context.WithValue(ctx, assignmentKey{}, assignment)
+}
+
+// FromContext returns the variants of a request, or nil when it takes part in no experiment
+func FromContext(ctx context.Context) Assignment {
+	assignment, _ := ctx.Value(assignmentKey{}).(Assignment)
+	return assignment
+}
+
+func isStage(name string) bool {
+	for _, stage := range Stages {
+		if stage == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package experiments
+
+import (
+	"strconv"
+	"testing"
+)
+
+// tone tries a formal response prompt on a tenth of the users
+var tone = Experiment{ID: "tom", Variants: []Variant{
+	{ID: "controle", Weight: 9},
+	{ID: "formal", Weight: 1, Prompts: map[string]string{"narration": "v2"}},
+}}
+
+// analysisModel sends the analysis of half the users to another model
+var analysisModel = Experiment{ID: "modelo", Variants: []Variant{
+	{ID: "controle", Weight: 1},
+	{ID: "mini", Weight: 1, Models: map[string]string{"analysis": "gpt-4.1-mini"}},
+	{ID: "pausado", Weight: 0, Models: map[string]string{"analysis": "gpt-4o"}},
+}}
+
+func TestUsersKeepTheirVariants(t *testing.T) {
+	set, err := New([]Experiment{tone, analysisModel})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		user := "user-" + strconv.Itoa(i)
+		first, again := set.Assign(user).IDs(), set.Assign(user).IDs()
+		if len(first) != 2 || first[0] != again[0] || first[1] != again[1] {
+			t.Fatalf("%s got %v, then %v", user, first, again)
+		}
+	}
+}
+
+func TestVariantsAreAssignedByWeight(t *testing.T) {
+	set, err := New([]Experiment{tone, analysisModel})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	const users = 10000
+	counts := make(map[string]int)
+	for i := 0; i < users; i++ {
+		for _, id := range set.Assign("user-" + strconv.Itoa(i)).IDs() {
+			counts[id]++
+		}
+	}
+	// Within a few points of the weights
+	within := func(id string, share float64) {
+		t.Helper()
+		if got := float64(counts[id]) / users; got < share-0.03 || got > share+0.03 {
+			t.Errorf("%s got %.3f of the users, want %.2f", id, got, share)
+		}
+	}
+	within("tom/formal", 0.1)
+	within("modelo/mini", 0.5)
+	if counts["modelo/pausado"] > 0 {
+		t.Errorf("a variant without weight got %d users", counts["modelo/pausado"])
+	}
+}
+
+func TestAssignmentAppliesTheVariants(t *testing.T) {
+	set, err := New([]Experiment{tone, analysisModel})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Find a user in both treatment variants
+	for i := 0; i < 1000; i++ {
+		assignment := set.Assign("user-" + strconv.Itoa(i))
+		ids := assignment.IDs()
+		if ids[0] != "modelo/mini" || ids[1] != "tom/formal" {
+			continue
+		}
+		if got := assignment.Prompts()["narration"]; got != "v2" {
+			t.Errorf("narration prompt %q, want v2", got)
+		}
+		if got := assignment.Model("analysis"); got != "gpt-4.1-mini" {
+			t.Errorf("analysis model %q, want gpt-4.1-mini", got)
+		}
+		if got := assignment.Model("response"); got != "" {
+			t.Errorf("response model %q, want the configured one", got)
+		}
+		return
+	}
+	t.Fatal("no user got both treatment variants")
+}
+
+func TestNewRejectsConflictingExperiments(t *testing.T) {
+	variants := func(overrides ...Variant) []Variant {
+		return append([]Variant{{ID: "controle", Weight: 1}}, overrides...)
+	}
+
+	for reason, list := range map[string][]Experiment{
+		"one variant":       {{ID: "a", Variants: []Variant{{ID: "controle", Weight: 1}}}},
+		"no weight":         {{ID: "a", Variants: []Variant{{ID: "x"}, {ID: "y"}}}},
+		"negative weight":   {{ID: "a", Variants: variants(Variant{ID: "x", Weight: -1})}},
+		"duplicate variant": {{ID: "a", Variants: variants(Variant{ID: "controle", Weight: 1})}},
+		"duplicate id":      {{ID: "a", Variants: variants(Variant{ID: "x"})}, {ID: "a", Variants: variants(Variant{ID: "x"})}},
+		"unknown stage":     {{ID: "a", Variants: variants(Variant{ID: "x", Models: map[string]string{"guard": "gpt-4o"}})}},
+		"same prompt twice": {tone, {ID: "b", Variants: variants(Variant{ID: "x", Prompts: map[string]string{"narration": "v3"}})}},
+		"same stage twice":  {analysisModel, {ID: "b", Variants: variants(Variant{ID: "x", Models: map[string]string{"analysis": "gpt-4o"}})}},
+		"missing id":        {{Variants: variants(Variant{ID: "x"})}},
+	} {
+		if _, err := New(list); err == nil {
+			t.Errorf("%s: New succeeded", reason)
+		}
+	}
+}
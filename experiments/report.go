@@ -0,0 +1,119 @@
+package experiments
+
+import (
+	"math"
+	"sort"
+)
+
+// Report compares the variants of an experiment
+type Report struct {
+	Experiment string          `json:"experiment"`
+	Variants   []VariantReport `json:"variants"`
+}
+
+// VariantReport aggregates the outcomes of the requests assigned to a variant
+type VariantReport struct {
+	Variant  string            `json:"variant"`
+	Weight   int               `json:"weight"`
+	Prompts  map[string]string `json:"prompts,omitempty"`
+	Models   map[string]string `json:"models,omitempty"`
+	Requests int               `json:"requests"`
+	// Errors counts failed requests, whatever the stage
+	Errors int `json:"errors"`
+	// SQLValidationFailures counts generated queries rejected by validation
+	SQLValidationFailures int `json:"sql_validation_failures"`
+	// ExecutionErrors counts queries that failed against the database
+	ExecutionErrors int     `json:"execution_errors"`
+	LatencyAvgMs    float64 `json:"latency_avg_ms"`
+	LatencyP50Ms    int64   `json:"latency_p50_ms"`
+	LatencyP95Ms    int64   `json:"latency_p95_ms"`
+	TotalTokens     int     `json:"total_tokens"`
+	TokensAvg       float64 `json:"tokens_avg"`
+	Cost            float64 `json:"cost"`
+	Feedback        int     `json:"feedback"`
+	Helpful         int     `json:"helpful"`
+	// HelpfulRate is the share of feedback rating the answer as helpful
+	HelpfulRate float64 `json:"helpful_rate"`
+}
+
+// Report computes the metrics of every variant of the running experiments. When a request
+// got feedback more than once, the latest counts.
+func (s *Set) Report(outcomes []Outcome, feedback []Feedback) []Report {
+	helpful := make(map[string]bool)
+	for _, f := range feedback {
+		helpful[f.RequestID] = f.Helpful
+	}
+
+	byVariant := make(map[string][]Outcome)
+	for _, outcome := range outcomes {
+		for _, id := range outcome.Variants {
+			byVariant[id] = append(byVariant[id], outcome)
+		}
+	}
+
+	reports := make([]Report, 0, len(s.experiments))
+	for _, experiment := range s.experiments {
+		report := Report{Experiment: experiment.ID}
+		for _, variant := range experiment.Variants {
+			id := Assigned{Experiment: experiment.ID, Variant: variant}.ID()
+			report.Variants = append(report.Variants, variantReport(variant, byVariant[id], helpful))
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func variantReport(variant Variant, outcomes []Outcome, helpful map[string]bool) VariantReport {
+	report := VariantReport{
+		Variant:  variant.ID,
+		Weight:   variant.Weight,
+		Prompts:  variant.Prompts,
+		Models:   variant.Models,
+		Requests: len(outcomes),
+	}
+	if len(outcomes) == 0 {
+		return report
+	}
+
+	latencies := make([]int64, 0, len(outcomes))
+	var totalLatency int64
+	for _, outcome := range outcomes {
+		if outcome.FailedStage != "" {
+			report.Errors++
+		}
+		if outcome.InvalidQuery {
+			report.SQLValidationFailures++
+		}
+		if outcome.FailedStage == "query" {
+			report.ExecutionErrors++
+		}
+		latencies = append(latencies, outcome.LatencyMs)
+		totalLatency += outcome.LatencyMs
+		report.TotalTokens += outcome.TotalTokens
+		report.Cost += outcome.Cost
+
+		if value, ok := helpful[outcome.RequestID]; ok {
+			report.Feedback++
+			if value {
+				report.Helpful++
+			}
+		}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	report.LatencyAvgMs = round(float64(totalLatency)/float64(len(outcomes)), 1)
+	report.LatencyP50Ms = latencies[int(float64(len(latencies)-1)*0.50)]
+	report.LatencyP95Ms = latencies[int(float64(len(latencies)-1)*0.95)]
+	report.TokensAvg = round(float64(report.TotalTokens)/float64(len(outcomes)), 1)
+	report.Cost = round(report.Cost, 6)
+	if report.Feedback > 0 {
+		report.HelpfulRate = round(float64(report.Helpful)/float64(report.Feedback), 3)
+	}
+	return report
+}
+
+// round rounds x to the given number of decimal places
+func round(x float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(x*scale) / scale
+}
@@ -0,0 +1,74 @@
+package experiments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReportComparesTheVariants(t *testing.T) {
+	set, err := New([]Experiment{tone})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outcomes := []Outcome{
+		{RequestID: "r1", Variants: []string{"tom/controle"}, LatencyMs: 100, TotalTokens: 10, Cost: 0.001},
+		{RequestID: "r2", Variants: []string{"tom/controle"}, LatencyMs: 300, TotalTokens: 30, Cost: 0.002, FailedStage: "sql_generation", InvalidQuery: true},
+		{RequestID: "r3", Variants: []string{"tom/controle"}, LatencyMs: 200, TotalTokens: 20, Cost: 0.003, FailedStage: "query"},
+		// Outcomes of other experiments are left out
+		{RequestID: "r4", Variants: []string{"outro/x"}, LatencyMs: 900},
+	}
+	feedback := []Feedback{
+		{RequestID: "r1", Helpful: false},
+		// The latest rating of a request counts
+		{RequestID: "r1", Helpful: true},
+		{RequestID: "r3", Helpful: false},
+	}
+
+	reports := set.Report(outcomes, feedback)
+	if len(reports) != 1 || reports[0].Experiment != "tom" || len(reports[0].Variants) != 2 {
+		t.Fatalf("reports = %+v, want one for tom with both variants", reports)
+	}
+	want := VariantReport{
+		Variant: "controle", Weight: 9, Requests: 3,
+		Errors: 2, SQLValidationFailures: 1, ExecutionErrors: 1,
+		LatencyAvgMs: 200, LatencyP50Ms: 200, LatencyP95Ms: 200,
+		TotalTokens: 60, TokensAvg: 20, Cost: 0.006,
+		Feedback: 2, Helpful: 1, HelpfulRate: 0.5,
+	}
+	if got := reports[0].Variants[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("controle = %+v\nwant %+v", got, want)
+	}
+	if got := reports[0].Variants[1]; got.Variant != "formal" || got.Requests != 0 || got.Prompts["narration"] != "v2" {
+		t.Errorf("formal = %+v, want its overrides and no requests", got)
+	}
+}
+
+func TestFileStoreKeepsResultsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcome := Outcome{RequestID: "r1", Time: time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC), Variants: []string{"tom/formal"}, LatencyMs: 120}
+	feedback := Feedback{RequestID: "r1", Time: outcome.Time.Add(time.Minute), Helpful: true}
+	if err := store.AddOutcome(outcome); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddFeedback(feedback); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes, feedbacks := reopened.List()
+	if !reflect.DeepEqual(outcomes, []Outcome{outcome}) || !reflect.DeepEqual(feedbacks, []Feedback{feedback}) {
+		t.Errorf("List = %+v, %+v", outcomes, feedbacks)
+	}
+	if got, ok := reopened.Outcome("r1"); !ok || got.LatencyMs != 120 {
+		t.Errorf("Outcome(r1) = %+v, %v", got, ok)
+	}
+}
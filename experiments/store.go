@@ -0,0 +1,191 @@
+package experiments
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outcome is how a request taking part in experiments went
+type Outcome struct {
+	RequestID    string    `json:"request_id"`
+	Time         time.Time `json:"time"`
+	Variants     []string  `json:"variants"`
+	LatencyMs    int64     `json:"latency_ms"`
+	UsedDatabase bool      `json:"used_database"`
+	Cached       bool      `json:"cached,omitempty"`
+	TotalTokens  int       `json:"total_tokens"`
+	Cost         float64   `json:"cost"`
+	// FailedStage is the pipeline stage that failed, empty on success
+	FailedStage string `json:"failed_stage,omitempty"`
+	// InvalidQuery is set when the generated query failed validation
+	InvalidQuery bool `json:"invalid_query,omitempty"`
+}
+
+// Feedback is a user's rating of an answer
+type Feedback struct {
+	RequestID string    `json:"request_id"`
+	Time      time.Time `json:"time"`
+	Helpful   bool      `json:"helpful"`
+	Comment   string    `json:"comment,omitempty"`
+}
+
+// Store keeps the outcomes of requests and the feedback on them
+type Store interface {
+	AddOutcome(outcome Outcome) error
+	AddFeedback(feedback Feedback) error
+	// Outcome returns the outcome of a request
+	Outcome(requestID string) (Outcome, bool)
+	// List returns every outcome and feedback, oldest first
+	List() ([]Outcome, []Feedback)
+}
+
+// NewStore creates a file store in dir, or a memory store when dir is empty
+func NewStore(dir string) (Store, error) {
+	if dir == "" {
+		return NewMemoryStore(), nil
+	}
+	return NewFileStore(dir)
+}
+
+// MemoryStore keeps outcomes and feedback in memory; they are lost on restart
+type MemoryStore struct {
+	mu        sync.RWMutex
+	outcomes  []Outcome
+	requests  map[string]int
+	feedbacks []Feedback
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{requests: make(map[string]int)}
+}
+
+// AddOutcome stores an outcome
+func (s *MemoryStore) AddOutcome(outcome Outcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[outcome.RequestID] = len(s.outcomes)
+	s.outcomes = append(s.outcomes, outcome)
+	return nil
+}
+
+// AddFeedback stores feedback
+func (s *MemoryStore) AddFeedback(feedback Feedback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feedbacks = append(s.feedbacks, feedback)
+	return nil
+}
+
+// Outcome returns the outcome of a request
+func (s *MemoryStore) Outcome(requestID string) (Outcome, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	index, ok := s.requests[requestID]
+	if !ok {
+		return Outcome{}, false
+	}
+	return s.outcomes[index], true
+}
+
+// List returns every outcome and feedback
+func (s *MemoryStore) List() ([]Outcome, []Feedback) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Outcome(nil), s.outcomes...), append([]Feedback(nil), s.feedbacks...)
+}
+
+// fileEntry is a line of the store file, holding either an outcome or feedback
+type fileEntry struct {
+	Outcome  *Outcome  `json:"outcome,omitempty"`
+	Feedback *Feedback `json:"feedback,omitempty"`
+}
+
+// FileStore appends outcomes and feedback as JSON lines to a file, so experiment
+// results survive restarts. The file is read back into memory when the store opens.
+type FileStore struct {
+	*MemoryStore
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore opens the store in dir, creating the directory when needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create experiments store directory: %w", err)
+	}
+
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: filepath.Join(dir, "experiments.jsonl")}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AddOutcome appends an outcome to the file
+func (s *FileStore) AddOutcome(outcome Outcome) error {
+	if err := s.append(fileEntry{Outcome: &outcome}); err != nil {
+		return err
+	}
+	return s.MemoryStore.AddOutcome(outcome)
+}
+
+// AddFeedback appends feedback to the file
+func (s *FileStore) AddFeedback(feedback Feedback) error {
+	if err := s.append(fileEntry{Feedback: &feedback}); err != nil {
+		return err
+	}
+	return s.MemoryStore.AddFeedback(feedback)
+}
+
+func (s *FileStore) append(entry fileEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// load reads the file into memory; a missing file has no entries
+func (s *FileStore) load() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry fileEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("failed to read %s: %w", filepath.Base(s.path), err)
+		}
+		if entry.Outcome != nil {
+			s.MemoryStore.AddOutcome(*entry.Outcome)
+		}
+		if entry.Feedback != nil {
+			s.MemoryStore.AddFeedback(*entry.Feedback)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,358 @@
+package grpcserver
+
+import (
+	"context"
+	"credibot-api/auth"
+	"credibot-api/config"
+	"credibot-api/conversations"
+	"credibot-api/credibotpb"
+	"credibot-api/experiments"
+	"credibot-api/handlers"
+	"credibot-api/models"
+	"credibot-api/tokens"
+	"credibot-api/usage"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// server implements the Credibot gRPC service on top of the REST handler logic
+type server struct {
+	credibotpb.UnimplementedCredibotServer
+}
+
+// Start serves the gRPC API on the given port in the background, with server reflection
+// when enabled
+func Start(port string, enableReflection bool) {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC on port %s: %v", port, err)
+	}
+
+	grpcServer := newServer()
+	if enableReflection {
+		reflection.Register(grpcServer)
+	}
+
+	go func() {
+		log.Printf("gRPC server starting on port %s", port)
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Fatalf("gRPC server stopped: %v", err)
+		}
+	}()
+}
+
+// newServer creates a gRPC server of the Credibot service that authenticates and attributes RPCs
+func newServer() *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(attributeUnary),
+		grpc.StreamInterceptor(attributeStream),
+	)
+	credibotpb.RegisterCredibotServer(grpcServer, &server{})
+	return grpcServer
+}
+
+// Metadata keys identifying the caller and the user, like the X-Caller-ID and X-User-ID headers,
+// and carrying the API key, like the X-API-Key and Authorization headers
+const (
+	callerMetadata        = "x-caller-id"
+	userMetadata          = "x-user-id"
+	apiKeyMetadata        = "x-api-key"
+	authorizationMetadata = "authorization"
+)
+
+// withCaller authenticates the API key of an RPC, attributes its model calls to its caller
+// and method, and identifies its user for experiment assignment (the caller when no user is given)
+func withCaller(ctx context.Context, method string) (context.Context, error) {
+	var caller, user, key string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(callerMetadata); len(values) > 0 {
+			caller = handlers.UnverifiedCaller(values[0])
+		}
+		if values := md.Get(userMetadata); len(values) > 0 {
+			user = values[0]
+		}
+		if values := md.Get(apiKeyMetadata); len(values) > 0 {
+			key = values[0]
+		} else if values := md.Get(authorizationMetadata); len(values) > 0 {
+			key = auth.BearerToken(values[0])
+		}
+	}
+
+	identity, ok, err := handlers.Identify(key)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if ok {
+		caller = identity.Caller
+		ctx = auth.WithIdentity(ctx, identity)
+	}
+	if user == "" {
+		user = caller
+	}
+	ctx = experiments.WithUser(ctx, user)
+	return usage.WithCaller(ctx, caller, method), nil
+}
+
+// attributeUnary authenticates unary RPCs and attributes them in usage accounting
+func attributeUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := withCaller(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// attributeStream authenticates streaming RPCs and attributes them in usage accounting
+func attributeStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := withCaller(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &attributedStream{ServerStream: stream, ctx: ctx})
+}
+
+// attributedStream overrides the context of a server stream
+type attributedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *attributedStream) Context() context.Context {
+	return s.ctx
+}
+
+// withRequestTimeout bounds an RPC by REQUEST_TIMEOUT like REST requests, unless the client set
+// an earlier deadline
+func withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := config.AppConfig.RequestTimeout; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// errorStatus maps a pipeline error to a gRPC status: the caller's cancellation or deadline,
+// DeadlineExceeded when an upstream or stage budget ran out, Unauthenticated when an anonymous
+// caller sends a conversation id, InvalidArgument when the prompt cannot fit the model or the
+// generation parameters or message are not allowed, Internal otherwise
+func errorStatus(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if handlers.IsTimeout(err) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	if errors.Is(err, handlers.ErrAnonymousConversation) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if errors.Is(err, tokens.ErrContextExceeded) || errors.Is(err, handlers.ErrInvalidParameters) ||
+		errors.Is(err, handlers.ErrInvalidMessages) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Chat sends a single message to the model
+func (s *server) Chat(ctx context.Context, req *credibotpb.ChatRequest) (*credibotpb.ChatResponse, error) {
+	if req.GetMessage() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Message is required")
+	}
+
+	ctx, cancel := withRequestTimeout(ctx)
+	defer cancel()
+	resp, err := handlers.RunChat(ctx, toChatRequest(req), nil)
+	if err != nil {
+		return nil, errorStatus(ctx, err)
+	}
+
+	return &credibotpb.ChatResponse{
+		Message:   resp.Message,
+		Model:     resp.Model,
+		Usage:     toUsage(&resp.Usage),
+		CreatedAt: timestamppb.New(resp.CreatedAt),
+	}, nil
+}
+
+// SmartChat streams partial answers followed by the complete smart chat response
+func (s *server) SmartChat(req *credibotpb.ChatRequest, stream credibotpb.Credibot_SmartChatServer) error {
+	if req.GetMessage() == "" {
+		return status.Error(codes.InvalidArgument, "Message is required")
+	}
+
+	ctx, cancel := withRequestTimeout(stream.Context())
+	defer cancel()
+
+	var sendErr error
+	resp, err := handlers.RunSmartChat(ctx, toChatRequest(req), func(delta string) {
+		if sendErr != nil {
+			return
+		}
+		sendErr = stream.Send(&credibotpb.SmartChatEvent{
+			Event: &credibotpb.SmartChatEvent_Delta{Delta: delta},
+		})
+		if sendErr != nil {
+			// The client went away: stop the pipeline instead of answering nobody
+			cancel()
+		}
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return errorStatus(ctx, err)
+	}
+
+	return stream.Send(&credibotpb.SmartChatEvent{
+		Event: &credibotpb.SmartChatEvent_Done{Done: &credibotpb.SmartChatResponse{
+			Message:      resp.Message,
+			UsedDatabase: resp.UsedDatabase,
+			SqlQuery:     resp.SQLQuery,
+			CreatedAt:    timestamppb.New(resp.CreatedAt),
+			Model:        resp.Model,
+			Refusal:      toRefusal(resp.Refusal),
+			Redacted:     resp.Redacted,
+			Usage:        toUsage(resp.Usage),
+		}},
+	})
+}
+
+// GetData reads rows from a table
+func (s *server) GetData(ctx context.Context, req *credibotpb.GetDataRequest) (*credibotpb.GetDataResponse, error) {
+	if req.GetTable() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Table name is required")
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = 10
+	}
+	orderBy := req.GetOrderBy()
+	if orderBy == "" {
+		orderBy = "created_at"
+	}
+
+	data, err := handlers.FetchData(ctx, req.GetTable(), limit, strconv.Itoa(int(req.GetOffset())), orderBy)
+	if err != nil {
+		return nil, errorStatus(ctx, err)
+	}
+
+	rows := make([]*structpb.Struct, 0, len(data))
+	for _, record := range data {
+		row, err := structpb.NewStruct(record)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "Failed to encode row: "+err.Error())
+		}
+		rows = append(rows, row)
+	}
+
+	return &credibotpb.GetDataResponse{Rows: rows}, nil
+}
+
+// requireIdentity rejects RPCs not authenticated with an API key, returning their caller
+func requireIdentity(ctx context.Context) (string, error) {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "API key required")
+	}
+	return identity.Caller, nil
+}
+
+// ListConversations lists the conversations recorded by the caller
+func (s *server) ListConversations(ctx context.Context, req *credibotpb.ListConversationsRequest) (*credibotpb.ListConversationsResponse, error) {
+	caller, err := requireIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := conversations.Default.List(caller)
+
+	resp := &credibotpb.ListConversationsResponse{
+		Conversations: make([]*credibotpb.Conversation, 0, len(list)),
+	}
+	for _, conversation := range list {
+		resp.Conversations = append(resp.Conversations, toConversation(conversation))
+	}
+	return resp, nil
+}
+
+// GetConversation returns the transcript of a conversation of the caller
+func (s *server) GetConversation(ctx context.Context, req *credibotpb.GetConversationRequest) (*credibotpb.Conversation, error) {
+	caller, err := requireIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conversation, ok := conversations.Default.Get(caller, req.GetId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "Conversation not found")
+	}
+	return toConversation(conversation), nil
+}
+
+// DeleteConversation removes a conversation transcript of the caller
+func (s *server) DeleteConversation(ctx context.Context, req *credibotpb.DeleteConversationRequest) (*credibotpb.DeleteConversationResponse, error) {
+	caller, err := requireIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !conversations.Default.Delete(caller, req.GetId()) {
+		return nil, status.Error(codes.NotFound, "Conversation not found")
+	}
+	return &credibotpb.DeleteConversationResponse{}, nil
+}
+
+// toChatRequest converts a gRPC chat request to the REST model
+func toChatRequest(req *credibotpb.ChatRequest) models.ChatRequest {
+	return models.ChatRequest{
+		Message:        req.GetMessage(),
+		Model:          req.GetModel(),
+		MaxTokens:      int(req.GetMaxTokens()),
+		ConversationID: req.GetConversationId(),
+	}
+}
+
+// toUsage converts token usage to its protobuf form
+func toUsage(u *models.Usage) *credibotpb.Usage {
+	if u == nil {
+		return nil
+	}
+	return &credibotpb.Usage{
+		PromptTokens:     int32(u.PromptTokens),
+		CompletionTokens: int32(u.CompletionTokens),
+		TotalTokens:      int32(u.TotalTokens),
+	}
+}
+
+// toRefusal converts the refusal of an off-topic or injected question to its protobuf form
+func toRefusal(r *models.Refusal) *credibotpb.Refusal {
+	if r == nil {
+		return nil
+	}
+	return &credibotpb.Refusal{Category: r.Category, Reason: r.Reason}
+}
+
+// toConversation converts a stored conversation to its protobuf form
+func toConversation(c conversations.Conversation) *credibotpb.Conversation {
+	messages := make([]*credibotpb.ConversationMessage, 0, len(c.Messages))
+	for _, message := range c.Messages {
+		messages = append(messages, &credibotpb.ConversationMessage{
+			Role:      message.Role,
+			Content:   message.Content,
+			CreatedAt: timestamppb.New(message.CreatedAt),
+		})
+	}
+
+	return &credibotpb.Conversation{
+		Id:        c.ID,
+		Messages:  messages,
+		CreatedAt: timestamppb.New(c.CreatedAt),
+		UpdatedAt: timestamppb.New(c.UpdatedAt),
+	}
+}
@@ -0,0 +1,151 @@
+package guard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Categories of incoming messages
+const (
+	InDomain  = "in_domain"
+	OffTopic  = "off_topic"
+	Injection = "injection"
+)
+
+// Reason codes of the built-in rules and of model verdicts
+const (
+	ReasonInstructionOverride = "instruction_override"
+	ReasonPromptLeak          = "prompt_leak"
+	ReasonSQLCommand          = "sql_command"
+	ReasonOffTopic            = "off_topic"
+	ReasonPromptInjection     = "prompt_injection"
+)
+
+// Rule flags messages matching a regular expression. Patterns are matched case-insensitively
+// against the message without accents, so they are written in lowercase without accents.
+type Rule struct {
+	ID       string `json:"id"`
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+	Pattern  string `json:"pattern"`
+
+	re *regexp.Regexp
+}
+
+// Verdict is the classification of a message. Rule is the id of the rule that flagged it,
+// or "model" when the model did.
+type Verdict struct {
+	Category string `json:"category"`
+	Reason   string `json:"reason,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+}
+
+// Allowed reports whether the message may go on to the pipeline
+func (v Verdict) Allowed() bool {
+	return v.Category == InDomain
+}
+
+// defaultRules catch the usual attempts to override the instructions, leak the system prompt
+// or smuggle data-changing SQL into the generated query, in Portuguese and English
+var defaultRules = []Rule{
+	{
+		ID:       "ignore_instructions",
+		Category: Injection,
+		Reason:   ReasonInstructionOverride,
+		Pattern:  `\b(ignor\w*|desconsider\w*|esquec\w*|disregard|forget)\b.{0,40}\b(instruc\w*|orientac\w*|instructions?|prompt|regras (anteriores|acima|do sistema)|(previous|prior|above) rules)\b`,
+	},
+	{
+		ID:       "new_instructions",
+		Category: Injection,
+		Reason:   ReasonInstructionOverride,
+		Pattern:  `\b(novas instrucoes|new instructions|a partir de agora voce|from now on you|voce agora e|you are now)\b`,
+	},
+	{
+		ID:       "reveal_prompt",
+		Category: Injection,
+		Reason:   ReasonPromptLeak,
+		Pattern:  `\b(mostr\w*|revel\w*|repit\w*|imprim\w*|exib\w*|show|reveal|repeat|print)\b.{0,40}\b(prompt|instrucoes do sistema|system message|suas instrucoes|your instructions)\b`,
+	},
+	{
+		ID:       "data_changing_sql",
+		Category: Injection,
+		Reason:   ReasonSQLCommand,
+		Pattern:  `\b(delete\s+from|drop\s+(table|database|schema)|truncate\s+(table\s+)?\w+|insert\s+into|update\s+\w+\s+set|alter\s+table|grant\s+\w+|revoke\s+\w+)\b`,
+	},
+	{
+		ID:       "run_sql_command",
+		Category: Injection,
+		Reason:   ReasonSQLCommand,
+		Pattern:  `\b(rod\w*|execut\w*|run|execute)\b.{0,30}\b(delete|drop|truncate|insert|update|alter)\b`,
+	},
+}
+
+// Rules classifies messages with the built-in rules and the rules of a file
+type Rules struct {
+	rules []Rule
+}
+
+// LoadRules compiles the built-in rules and, when path is set, the rules of a JSON file,
+// which are checked first
+func LoadRules(path string) (*Rules, error) {
+	var custom []Rule
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read guard rules: %w", err)
+		}
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("failed to parse guard rules: %w", err)
+		}
+	}
+
+	r := &Rules{}
+	for _, rule := range append(custom, defaultRules...) {
+		if rule.ID == "" || rule.Pattern == "" {
+			return nil, fmt.Errorf("guard rule needs an id and a pattern")
+		}
+		if rule.Category != OffTopic && rule.Category != Injection {
+			return nil, fmt.Errorf("guard rule %s: category must be %s or %s", rule.ID, OffTopic, Injection)
+		}
+		if rule.Reason == "" {
+			rule.Reason = rule.Category
+		}
+		re, err := regexp.Compile("(?is)" + rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("guard rule %s: %w", rule.ID, err)
+		}
+		rule.re = re
+		r.rules = append(r.rules, rule)
+	}
+	return r, nil
+}
+
+// Check returns the verdict of the first rule matching a message; a message no rule
+// matches is in domain as far as the rules can tell
+func (r *Rules) Check(message string) Verdict {
+	text := normalize(message)
+	for _, rule := range r.rules {
+		if rule.re.MatchString(text) {
+			return Verdict{Category: rule.Category, Reason: rule.Reason, Rule: rule.ID}
+		}
+	}
+	return Verdict{Category: InDomain}
+}
+
+// accents folds the accented letters of Portuguese
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a",
+	"é", "e", "ê", "e",
+	"í", "i",
+	"ó", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ü", "u",
+	"ç", "c",
+)
+
+// normalize lowercases a message and folds its accents, so rules are written once
+func normalize(message string) string {
+	return accents.Replace(strings.ToLower(message))
+}
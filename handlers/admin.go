@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"credibot-api/config"
+	"credibot-api/httpclient"
+	"credibot-api/models"
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireAdmin only lets requests carrying the configured admin key through
+func RequireAdmin(c *fiber.Ctx) error {
+	adminKey := config.AppConfig.AdminAPIKey
+	if adminKey == "" {
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Admin API key not configured",
+			Code:    fiber.StatusForbidden,
+		})
+	}
+
+	if !isAdmin(c) {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Invalid admin key",
+			Code:    fiber.StatusUnauthorized,
+		})
+	}
+
+	return c.Next()
+}
+
+// isAdmin reports whether a request carries the configured admin key
+func isAdmin(c *fiber.Ctx) bool {
+	adminKey := config.AppConfig.AdminAPIKey
+	return adminKey != "" && subtle.ConstantTimeCompare([]byte(c.Get("X-Admin-Key")), []byte(adminKey)) == 1
+}
+
+// UpstreamStats reports latency and connection reuse of the shared upstream HTTP clients
+func UpstreamStats(c *fiber.Ctx) error {
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    httpclient.Stats(),
+		Message: "Upstream statistics retrieved successfully",
+	})
+}
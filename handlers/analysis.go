@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"credibot-api/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Intents returned by the analysis stage
+const (
+	intentDataQuery     = "data_query"
+	intentGeneral       = "general"
+	intentClarification = "clarification"
+)
+
+// withoutStructuredOutputs remembers the models that rejected json_schema response formats
+var withoutStructuredOutputs sync.Map
+
+// structuredFormat constrains a stage's output to a JSON schema, or only to a JSON object for
+// models known not to support schemas. Either way the stage decodes the output strictly.
+func structuredFormat(model, name string, schema json.RawMessage) *openai.ChatCompletionResponseFormat {
+	if _, unsupported := withoutStructuredOutputs.Load(model); unsupported {
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   name,
+			Schema: schema,
+			Strict: true,
+		},
+	}
+}
+
+// structuredCompletion runs a stage request built with structuredFormat. When the model rejects
+// the json_schema format (e.g. gpt-3.5-turbo), it is retried with a plain JSON object, and the
+// model is remembered so later requests skip the rejected attempt.
+func structuredCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := llmProvider.CreateChatCompletion(ctx, req)
+	if err == nil || req.ResponseFormat == nil || req.ResponseFormat.Type != openai.ChatCompletionResponseFormatTypeJSONSchema ||
+		!rejectsStructuredOutputs(err) {
+		return resp, err
+	}
+
+	log.Printf("Model %s does not support structured outputs, falling back to JSON mode: %v", req.Model, err)
+	withoutStructuredOutputs.Store(req.Model, true)
+	req.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	return llmProvider.CreateChatCompletion(ctx, req)
+}
+
+// rejectsStructuredOutputs reports whether an upstream error refuses the json_schema response format
+func rejectsStructuredOutputs(err error) bool {
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusBadRequest {
+		return false
+	}
+	message := strings.ToLower(apiErr.Message)
+	return strings.Contains(message, "response_format") || strings.Contains(message, "json_schema")
+}
+
+// analysisFields are the keys the analysis output must contain, no more and no less
+var analysisFields = []string{"intent", "needs_database", "sql", "tables_used", "confidence", "clarification"}
+
+// analysisSchema returns the JSON schema the analysis stage output is constrained to
+func analysisSchema() json.RawMessage {
+	tables := make([]string, 0, len(CreditSchema))
+	for _, table := range CreditSchema {
+		tables = append(tables, table.Name)
+	}
+
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"intent": map[string]interface{}{
+				"type": "string",
+				"enum": []string{intentDataQuery, intentGeneral, intentClarification},
+			},
+			"needs_database": map[string]interface{}{"type": "boolean"},
+			"sql":            map[string]interface{}{"type": []string{"string", "null"}},
+			"tables_used": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string", "enum": tables},
+			},
+			"confidence":    map[string]interface{}{"type": "number"},
+			"clarification": map[string]interface{}{"type": []string{"string", "null"}},
+		},
+		"required":             analysisFields,
+		"additionalProperties": false,
+	}
+
+	data, _ := json.Marshal(schema)
+	return data
+}
+
+// sqlSchema returns the JSON schema the SQL generation stage output is constrained to
+func sqlSchema() json.RawMessage {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"sql": map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"sql"},
+		"additionalProperties": false,
+	})
+	return data
+}
+
+// decodeSQL strictly decodes the SQL generation output
+func decodeSQL(content string) (string, error) {
+	var output struct {
+		SQL *string `json:"sql"`
+	}
+	decoder := json.NewDecoder(strings.NewReader(strings.TrimSpace(content)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&output); err != nil {
+		return "", fmt.Errorf("SQL generation output violates schema: %w", err)
+	}
+	if output.SQL == nil || strings.TrimSpace(*output.SQL) == "" {
+		return "", fmt.Errorf("SQL generation output violates schema: missing sql")
+	}
+	return *output.SQL, nil
+}
+
+// decodeAnalysis strictly decodes the analysis output, rejecting anything the schema does not allow.
+// withSQL tells whether the analysis had to generate the SQL or leave it to the SQL generation stage.
+func decodeAnalysis(content string, withSQL bool) (models.QuestionAnalysis, error) {
+	var analysis models.QuestionAnalysis
+	data := []byte(strings.TrimSpace(content))
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return analysis, fmt.Errorf("analysis output is not a JSON object: %w", err)
+	}
+	var missing []string
+	for _, field := range analysisFields {
+		if _, ok := fields[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return analysis, fmt.Errorf("analysis output violates schema: missing %s", strings.Join(missing, ", "))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&analysis); err != nil {
+		return analysis, fmt.Errorf("analysis output violates schema: %w", err)
+	}
+
+	switch analysis.Intent {
+	case intentDataQuery, intentGeneral, intentClarification:
+	default:
+		return analysis, fmt.Errorf("analysis output violates schema: unknown intent %q", analysis.Intent)
+	}
+	if analysis.Confidence < 0 || analysis.Confidence > 1 {
+		return analysis, fmt.Errorf("analysis output violates schema: confidence %v outside [0, 1]", analysis.Confidence)
+	}
+	for _, table := range analysis.TablesUsed {
+		if _, ok := findTable(table); !ok {
+			return analysis, fmt.Errorf("analysis output violates schema: unknown table %q", table)
+		}
+	}
+	sort.Strings(analysis.TablesUsed)
+
+	switch {
+	case withSQL && analysis.NeedsDatabase && strings.TrimSpace(analysis.SQL) == "":
+		return analysis, fmt.Errorf("analysis output violates schema: needs_database is true but sql is empty")
+	case !withSQL && analysis.SQL != "":
+		return analysis, fmt.Errorf("analysis output violates schema: sql must be null")
+	case !analysis.NeedsDatabase && analysis.SQL != "":
+		return analysis, fmt.Errorf("analysis output violates schema: sql given but needs_database is false")
+	case analysis.Intent == intentClarification && strings.TrimSpace(analysis.Clarification) == "":
+		return analysis, fmt.Errorf("analysis output violates schema: clarification intent without a clarification")
+	}
+
+	return analysis, nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"credibot-api/auth"
+	"credibot-api/models"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyHeader carries the API key of a caller; "Authorization: Bearer <key>" is accepted too
+const APIKeyHeader = "X-API-Key"
+
+// localIdentity carries the authenticated caller from the middleware to the handlers
+const localIdentity = "identity"
+
+// ErrInvalidAPIKey is returned when a request carries an API key that is not configured
+var ErrInvalidAPIKey = errors.New("Invalid API key")
+
+// Identify resolves an API key to the caller it authenticates. Requests without a key are
+// anonymous; so is every request when no API keys are configured.
+func Identify(key string) (auth.Identity, bool, error) {
+	if key == "" || !apiKeys.Enabled() {
+		return auth.Identity{}, false, nil
+	}
+	identity, ok := apiKeys.Lookup(key)
+	if !ok {
+		return auth.Identity{}, false, ErrInvalidAPIKey
+	}
+	return identity, true, nil
+}
+
+// Authenticate resolves the API key of a request, rejecting unknown keys
+func Authenticate(c *fiber.Ctx) error {
+	key := strings.TrimSpace(c.Get(APIKeyHeader))
+	if key == "" {
+		key = auth.BearerToken(c.Get(fiber.HeaderAuthorization))
+	}
+
+	identity, ok, err := Identify(key)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+			Code:    fiber.StatusUnauthorized,
+		})
+	}
+	if ok {
+		c.Locals(localIdentity, identity)
+	}
+	return c.Next()
+}
+
+// RequireIdentity only lets requests authenticated with an API key through
+func RequireIdentity(c *fiber.Ctx) error {
+	if _, ok := requestIdentity(c); !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "API key required",
+			Code:    fiber.StatusUnauthorized,
+		})
+	}
+	return c.Next()
+}
+
+// UnverifiedCaller returns the caller an unauthenticated request names for usage attribution.
+// Callers that have an API key can only be named with their key, so they are dropped.
+func UnverifiedCaller(caller string) string {
+	caller = strings.TrimSpace(caller)
+	if apiKeys.Claimed(caller) {
+		return ""
+	}
+	return caller
+}
+
+// requestIdentity returns the authenticated caller of a request
+func requestIdentity(c *fiber.Ctx) (auth.Identity, bool) {
+	identity, ok := c.Locals(localIdentity).(auth.Identity)
+	return identity, ok
+}
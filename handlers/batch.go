@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/config"
+	"credibot-api/jobs"
+	"credibot-api/models"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SmartChatBatch answers a list of questions through the smart chat pipeline.
+// Failed items are reported individually without failing the whole batch.
+func SmartChatBatch(c *fiber.Ctx) error {
+	var req models.BatchRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request format",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	if err := validateBatch(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	// Items have their own timeouts, so the batch is only cancelled when the client leaves
+	ctx, cancel := RequestContext(c, 0)
+	defer cancel()
+
+	response := RunSmartChatBatch(ctx, req.Questions)
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    response,
+		Message: "Batch processed successfully",
+	})
+}
+
+// validateBatch checks the batch size against the configured limits
+func validateBatch(req models.BatchRequest) error {
+	if len(req.Questions) == 0 {
+		return fmt.Errorf("At least one question is required")
+	}
+	if len(req.Questions) > config.AppConfig.Batch.MaxItems {
+		return fmt.Errorf("Too many questions: maximum is %d", config.AppConfig.Batch.MaxItems)
+	}
+	return nil
+}
+
+// RunSmartChatBatch processes questions with a bounded worker pool and a timeout per item
+func RunSmartChatBatch(ctx context.Context, questions []string) models.BatchResponse {
+	results := make([]models.BatchItemResult, len(questions))
+
+	workers := config.AppConfig.Batch.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(questions) {
+		workers = len(questions)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = runBatchItem(ctx, i, questions[i])
+
+				mu.Lock()
+				done++
+				jobs.ReportStage(ctx, fmt.Sprintf("%d/%d questions answered", done, len(questions)))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range questions {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	response := models.BatchResponse{Results: results, CreatedAt: time.Now()}
+	for _, result := range results {
+		if result.Error != "" {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+	}
+	return response
+}
+
+// runBatchItem answers a single batch question within the per-item timeout
+func runBatchItem(ctx context.Context, index int, question string) models.BatchItemResult {
+	result := models.BatchItemResult{Index: index, Question: question}
+	if question == "" {
+		result.Error = "Message is required"
+		return result
+	}
+
+	itemCtx, cancel := context.WithTimeout(ctx, config.AppConfig.Batch.ItemTimeout)
+	defer cancel()
+
+	// Item stages would overwrite the batch progress of a job
+	itemCtx = jobs.WithProgress(itemCtx, nil)
+
+	resp, err := RunSmartChat(itemCtx, models.ChatRequest{Message: question}, nil)
+	if err != nil {
+		if itemCtx.Err() == context.DeadlineExceeded {
+			result.Error = "Timed out after " + config.AppConfig.Batch.ItemTimeout.String()
+		} else {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	result.Response = &resp
+	return result
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/cache"
+	"credibot-api/config"
+	"credibot-api/models"
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Cache namespaces, one per endpoint, each with its own TTL
+const (
+	cacheChat      = "chat"
+	cacheSmartChat = "smart_chat"
+)
+
+// cacheTTL returns how long the answers of a namespace are kept; 0 disables caching
+func cacheTTL(namespace string) time.Duration {
+	if responseCache == nil {
+		return 0
+	}
+	switch namespace {
+	case cacheChat:
+		return config.AppConfig.Cache.ChatTTL
+	case cacheSmartChat:
+		return config.AppConfig.Cache.SmartChatTTL
+	}
+	return 0
+}
+
+// requestKey identifies a model request. The key covers the model, its parameters and every
+// message, so a changed prompt or a different data snapshot in the prompt never hits an old
+// entry. User messages are normalized so trivially different questions share an entry.
+func requestKey(namespace string, req openai.ChatCompletionRequest) string {
+	req.Stream = false
+	req.StreamOptions = nil
+	req.Messages = append([]openai.ChatCompletionMessage(nil), req.Messages...)
+	for i, message := range req.Messages {
+		if message.Role == openai.ChatMessageRoleUser {
+			req.Messages[i].Content = cache.NormalizeQuestion(message.Content)
+		}
+	}
+
+	data, _ := json.Marshal(req)
+	return cache.Key(namespace, data)
+}
+
+// cacheGet decodes a cached entry of a namespace into v
+func cacheGet(namespace, key string, v interface{}) bool {
+	if cacheTTL(namespace) <= 0 {
+		return false
+	}
+	data, ok := responseCache.Get(key)
+	return ok && json.Unmarshal(data, v) == nil
+}
+
+// cacheSet stores v with the TTL of its namespace
+func cacheSet(namespace, key string, v interface{}) {
+	ttl := cacheTTL(namespace)
+	if ttl <= 0 {
+		return
+	}
+	if data, err := json.Marshal(v); err == nil {
+		responseCache.Set(key, data, ttl)
+	}
+}
+
+// cachedCompletion answers from the cache when an identical request was answered recently,
+// and caches fresh answers. Cached answers are streamed to onDelta in one piece and report
+// no usage, since no tokens were spent. It reports whether the answer came from the cache.
+func cachedCompletion(ctx context.Context, namespace string, req openai.ChatCompletionRequest, onDelta func(string)) (openai.ChatCompletionResponse, bool, error) {
+	key := requestKey(namespace, req)
+
+	var resp openai.ChatCompletionResponse
+	if cacheGet(namespace, key, &resp) && len(resp.Choices) > 0 {
+		if onDelta != nil {
+			onDelta(resp.Choices[0].Message.Content)
+		}
+		resp.Usage = openai.Usage{}
+		return resp, true, nil
+	}
+
+	resp, err := createCompletion(ctx, req, onDelta)
+	if err != nil {
+		return resp, false, err
+	}
+	cacheSet(namespace, key, resp)
+	return resp, false, nil
+}
+
+// CacheStats reports the size and hit rate of the response cache
+func CacheStats(c *fiber.Ctx) error {
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    responseCache.Stats(),
+		Message: "Cache statistics retrieved successfully",
+	})
+}
+
+// PurgeCache removes the cached responses of an endpoint (namespace chat or smart_chat),
+// or every cached response when no namespace is given
+func PurgeCache(c *fiber.Ctx) error {
+	namespace := c.Query("namespace")
+	if namespace != "" && namespace != cacheChat && namespace != cacheSmartChat {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Invalid namespace: must be chat or smart_chat",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	purged := responseCache.Purge(namespace)
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    fiber.Map{"purged": purged},
+		Message: "Cache purged successfully",
+	})
+}
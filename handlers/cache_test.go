@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/cache"
+	"credibot-api/config"
+	"credibot-api/models"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// dataQuestion answers every question with a query on clientes and narrates the rows
+const dataQuestion = `[
+	{"contains": ["needs_database", "tables_used"], "response": "{\"intent\":\"data_query\",\"needs_database\":true,\"sql\":\"SELECT classe_risco FROM clientes\",\"tables_used\":[\"clientes\"],\"confidence\":0.9,\"clarification\":null}"},
+	{"contains": ["RESUMO DOS DADOS"], "response": "A carteira tem clientes de risco baixo."}
+]`
+
+// useCache gives the handlers an empty response cache and records the requests reaching the model
+func useCache(t *testing.T) *recordingProvider {
+	t.Helper()
+	responseCache = cache.NewMemory(0)
+	provider := &recordingProvider{Provider: llmProvider}
+	llmProvider = provider
+	return provider
+}
+
+func TestRepeatedChatQuestionsAreAnsweredFromTheCache(t *testing.T) {
+	useFakeLLM(t, "")
+	provider := useCache(t)
+	ask := func(req models.ChatRequest) models.ChatResponse {
+		t.Helper()
+		response, err := RunChat(context.Background(), req, nil)
+		if err != nil {
+			t.Fatalf("RunChat: %v", err)
+		}
+		return response
+	}
+
+	first := ask(models.ChatRequest{Message: "O que é score de crédito?"})
+	second := ask(models.ChatRequest{Message: "o que é  score de crédito"})
+	if first.Cached || !second.Cached || len(provider.requests) != 1 {
+		t.Fatalf("cached %v then %v after %d model requests, want a miss then a hit", first.Cached, second.Cached, len(provider.requests))
+	}
+	if second.Message != first.Message || second.Usage.TotalTokens != 0 {
+		t.Errorf("cached answer %q with %d tokens, want %q without usage", second.Message, second.Usage.TotalTokens, first.Message)
+	}
+
+	// Another model or another system prompt is another request
+	config.AppConfig.Generation.AllowedModels = []string{config.AppConfig.OpenAI.Model, "gpt-4o"}
+	if ask(models.ChatRequest{Message: "O que é score de crédito?", Model: "gpt-4o"}).Cached {
+		t.Error("another model hit the cache")
+	}
+	config.AppConfig.Chat.SystemPrompt = "Responda em uma frase"
+	if ask(models.ChatRequest{Message: "O que é score de crédito?"}).Cached {
+		t.Error("another system prompt hit the cache")
+	}
+}
+
+func TestSmartChatAnswersAreCachedPerDataSnapshot(t *testing.T) {
+	useFakeLLM(t, dataQuestion)
+	provider := useCache(t)
+	fakeSupabase(t, map[string][]map[string]interface{}{"clientes": {{"classe_risco": "baixo"}}})
+	ask := func() models.SmartChatResponse {
+		t.Helper()
+		response, err := RunSmartChat(context.Background(), models.ChatRequest{Message: "Qual o risco da carteira?"}, nil)
+		if err != nil {
+			t.Fatalf("RunSmartChat: %v", err)
+		}
+		return response
+	}
+
+	if ask().Cached {
+		t.Error("the first answer came from the cache")
+	}
+	requests := len(provider.requests)
+	if !ask().Cached || len(provider.requests) != requests {
+		t.Errorf("the repeated question made %d more model requests, want an answer from the cache", len(provider.requests)-requests)
+	}
+
+	// New rows change the prompt of the answer
+	fakeSupabase(t, map[string][]map[string]interface{}{"clientes": {{"classe_risco": "alto"}}})
+	if ask().Cached {
+		t.Error("the answer on other rows came from the cache")
+	}
+}
+
+func TestPurgeCacheEmptiesANamespace(t *testing.T) {
+	useFakeLLM(t, "")
+	useCache(t)
+	if _, err := RunChat(context.Background(), models.ChatRequest{Message: "O que é score de crédito?"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Delete("/cache", PurgeCache)
+	purge := func(namespace string) int {
+		resp, err := app.Test(httptest.NewRequest("DELETE", "/cache?namespace="+namespace, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := purge("outro"); status != fiber.StatusBadRequest {
+		t.Errorf("unknown namespace: status %d, want 400", status)
+	}
+	if status := purge(cacheSmartChat); status != fiber.StatusOK || responseCache.Stats().Entries != 1 {
+		t.Errorf("purging smart_chat: status %d, %d entries left, want the chat entry kept", status, responseCache.Stats().Entries)
+	}
+	if status := purge(cacheChat); status != fiber.StatusOK || responseCache.Stats().Entries != 0 {
+		t.Errorf("purging chat: status %d, %d entries left", status, responseCache.Stats().Entries)
+	}
+
+	response, err := RunChat(context.Background(), models.ChatRequest{Message: "O que é score de crédito?"}, nil)
+	if err != nil || response.Cached {
+		t.Errorf("after the purge: cached %v, %v; want a fresh answer", response.Cached, err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"credibot-api/config"
 	"credibot-api/models"
-	"os"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -23,14 +22,43 @@ func Chat(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Message == "" {
+	if req.Message == "" && len(req.Messages) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   true,
-			Message: "Message is required",
+			Message: "Message or messages is required",
 			Code:    fiber.StatusBadRequest,
 		})
 	}
 
+	ctx, cancel := RequestContext(c, config.AppConfig.RequestTimeout)
+	defer cancel()
+
+	response, err := RunChat(ctx, req, nil)
+	if err != nil {
+		return pipelineErrorResponse(c, ctx, err)
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    response,
+		Message: "Chat response generated successfully",
+	})
+}
+
+// RunChat sends a message, or a conversation sent by the client, to OpenAI, streaming partial
+// content to onDelta when it is set
+func RunChat(ctx context.Context, req models.ChatRequest, onDelta func(string)) (models.ChatResponse, error) {
+	if err := validateGeneration(req); err != nil {
+		return models.ChatResponse{}, err
+	}
+	if err := checkConversationOwner(ctx, req.ConversationID); err != nil {
+		return models.ChatResponse{}, err
+	}
+	system, history, question, err := chatPrompt(req)
+	if err != nil {
+		return models.ChatResponse{}, err
+	}
+
 	// Default configurations from .env
 	if req.Model == "" {
 		req.Model = config.AppConfig.OpenAI.Model
@@ -39,49 +67,39 @@ func Chat(c *fiber.Ctx) error {
 		req.MaxTokens = config.AppConfig.OpenAI.MaxTokens
 	}
 
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   true,
-			Message: "OpenAI API key not configured",
-			Code:    fiber.StatusInternalServerError,
-		})
+	var messages []openai.ChatCompletionMessage
+	budget := newBudget(req.Model, req.MaxTokens)
+	for _, prompt := range system {
+		budget.AddSystem(prompt)
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: prompt})
+	}
+	budget.AddUser(question)
+	// The oldest turns of long client conversations are dropped to fit the context window
+	messages = append(messages, budget.FitHistory(history)...)
+	report, err := budget.Report()
+	if err != nil {
+		return models.ChatResponse{}, &pipelineError{stageResponse, "Failed to build prompt", err}
 	}
 
-	client := openai.NewClient(apiKey)
-	
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: req.Model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: req.Message,
-				},
-			},
-			MaxTokens:   req.MaxTokens,
-			Temperature: config.AppConfig.OpenAI.Temperature,
-		},
-	)
+	completion := openai.ChatCompletionRequest{
+		Model: req.Model,
+		Messages: append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: question,
+		}),
+		MaxTokens:   req.MaxTokens,
+		Temperature: config.AppConfig.OpenAI.Temperature,
+	}
+	applyGeneration(req, &completion)
 
+	resp, cached, err := cachedCompletion(ctx, cacheChat, completion, onDelta)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   true,
-			Message: "Failed to get response from OpenAI: " + err.Error(),
-			Code:    fiber.StatusInternalServerError,
-		})
+		return models.ChatResponse{}, &pipelineError{stageResponse, "Failed to get response from OpenAI", err}
 	}
 
-	if len(resp.Choices) == 0 {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   true,
-			Message: "No response from OpenAI",
-			Code:    fiber.StatusInternalServerError,
-		})
-	}
+	recordExchange(ctx, req.ConversationID, question, resp.Choices[0].Message.Content)
 
-	response := models.ChatResponse{
+	return models.ChatResponse{
 		Message: resp.Choices[0].Message.Content,
 		Model:   resp.Model,
 		Usage: models.Usage{
@@ -89,12 +107,8 @@ func Chat(c *fiber.Ctx) error {
 			CompletionTokens: resp.Usage.CompletionTokens,
 			TotalTokens:      resp.Usage.TotalTokens,
 		},
+		Budget:    &report,
+		Cached:    cached,
 		CreatedAt: time.Now(),
-	}
-
-	return c.JSON(models.SuccessResponse{
-		Success: true,
-		Data:    response,
-		Message: "Chat response generated successfully",
-	})
-}
\ No newline at end of file
+	}, nil
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// GetClientProfile gathers a client record with its recent analyses, operations and score history.
+// The client is looked up by id, or by name when no id is given. Personal data is masked
+// unless the caller's role sees it.
+func GetClientProfile(ctx context.Context, clientID, name string) (map[string]interface{}, error) {
+	filter := map[string]string{"select": "*", "limit": "1"}
+	switch {
+	case clientID != "":
+		filter["id"] = "eq." + url.QueryEscape(clientID)
+	case name != "":
+		filter["nome"] = "ilike." + url.QueryEscape("*"+name+"*")
+	default:
+		return nil, fmt.Errorf("cliente_id or nome is required")
+	}
+
+	clients, err := fetchRows(ctx, "clientes", filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("client not found")
+	}
+
+	client := clients[0]
+	id := fmt.Sprint(client["id"])
+	redactRows(ctx, "clientes", clients)
+
+	profile := map[string]interface{}{"cliente": client}
+	for _, table := range []string{"analises_credito", "operacoes_credito", "score_historico"} {
+		rows, err := fetchRows(ctx, table, map[string]string{
+			"select":     "*",
+			"cliente_id": "eq." + url.QueryEscape(id),
+			"order":      "created_at.desc",
+			"limit":      "10",
+		})
+		if err != nil {
+			return nil, err
+		}
+		redactRows(ctx, table, rows)
+		profile[table] = rows
+	}
+
+	return profile, nil
+}
+
+// fetchRows runs a GET request against a table and decodes the returned rows
+func fetchRows(ctx context.Context, table string, queryParams map[string]string) ([]map[string]interface{}, error) {
+	responseBody, err := makeSupabaseRequest(ctx, "GET", table, nil, queryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(responseBody, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/config"
+	"credibot-api/tokens"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// newBudget creates the prompt budget of a request, reserving its completion tokens
+func newBudget(model string, maxTokens int) *tokens.Budget {
+	window := config.AppConfig.LLM.ContextWindow
+	if window <= 0 {
+		window = tokens.ContextWindow(model)
+	}
+	return tokens.NewBudget(model, window, maxTokens)
+}
+
+// createCompletion runs a chat completion, streaming partial content to onDelta when it is set
+func createCompletion(ctx context.Context, req openai.ChatCompletionRequest, onDelta func(string)) (openai.ChatCompletionResponse, error) {
+	if onDelta == nil {
+		resp, err := llmProvider.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		if len(resp.Choices) == 0 {
+			return resp, fmt.Errorf("no response from OpenAI")
+		}
+		return resp, nil
+	}
+
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+	stream, err := llmProvider.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer stream.Close()
+
+	// Accumulate the streamed chunks into a regular response
+	result := openai.ChatCompletionResponse{Model: req.Model}
+	var content string
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		if chunk.Model != "" {
+			result.ID = chunk.ID
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			content += chunk.Choices[0].Delta.Content
+			onDelta(chunk.Choices[0].Delta.Content)
+		}
+	}
+
+	if content == "" {
+		return result, fmt.Errorf("no response from OpenAI")
+	}
+
+	result.Choices = []openai.ChatCompletionChoice{{
+		Message: openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: content,
+		},
+	}}
+	return result, nil
+}
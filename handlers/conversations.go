@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/auth"
+	"credibot-api/conversations"
+	"credibot-api/models"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrAnonymousConversation is returned when an anonymous caller sends a conversation_id, since
+// anonymous callers cannot be told apart and would share their conversations
+var ErrAnonymousConversation = errors.New("conversation_id requires an API key")
+
+// conversationNamespaceKey is the context key of the conversation namespace of an anonymous caller
+type conversationNamespaceKey struct{}
+
+// withConversationNamespace gives an anonymous caller conversations of its own, e.g. for the
+// lifetime of a WebSocket connection
+func withConversationNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, conversationNamespaceKey{}, namespace)
+}
+
+// ConversationOwner returns the owner of the conversations recorded with a context: the
+// caller its API key authenticates, or the namespace of an anonymous caller. It reports false
+// for anonymous callers without a namespace, who cannot have conversations.
+func ConversationOwner(ctx context.Context) (string, bool) {
+	if identity, ok := auth.FromContext(ctx); ok {
+		return identity.Caller, true
+	}
+	namespace, ok := ctx.Value(conversationNamespaceKey{}).(string)
+	return namespace, ok
+}
+
+// checkConversationOwner rejects a conversation_id the caller cannot own
+func checkConversationOwner(ctx context.Context, conversationID string) error {
+	if conversationID == "" {
+		return nil
+	}
+	if _, ok := ConversationOwner(ctx); !ok {
+		return ErrAnonymousConversation
+	}
+	return nil
+}
+
+// recordExchange appends a question and its answer to a conversation transcript of the caller
+func recordExchange(ctx context.Context, conversationID, question, answer string) {
+	owner, ok := ConversationOwner(ctx)
+	if conversationID == "" || !ok {
+		return
+	}
+
+	conversations.Default.Append(owner, conversationID,
+		conversations.Message{Role: openai.ChatMessageRoleUser, Content: question},
+		conversations.Message{Role: openai.ChatMessageRoleAssistant, Content: answer},
+	)
+}
+
+// conversationHistory returns the earlier turns of a conversation of the caller as chat messages
+func conversationHistory(ctx context.Context, conversationID string) []openai.ChatCompletionMessage {
+	owner, ok := ConversationOwner(ctx)
+	if conversationID == "" || !ok {
+		return nil
+	}
+	conversation, ok := conversations.Default.Get(owner, conversationID)
+	if !ok {
+		return nil
+	}
+
+	history := make([]openai.ChatCompletionMessage, 0, len(conversation.Messages))
+	for _, message := range conversation.Messages {
+		history = append(history, openai.ChatCompletionMessage{Role: message.Role, Content: message.Content})
+	}
+	return history
+}
+
+// ListConversations lists the conversations recorded by the caller
+func ListConversations(c *fiber.Ctx) error {
+	identity, _ := requestIdentity(c)
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    conversations.Default.List(identity.Caller),
+		Message: "Conversations retrieved successfully",
+	})
+}
+
+// GetConversation returns the transcript of a conversation of the caller
+func GetConversation(c *fiber.Ctx) error {
+	identity, _ := requestIdentity(c)
+	conversation, ok := conversations.Default.Get(identity.Caller, c.Params("id"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Conversation not found",
+			Code:    fiber.StatusNotFound,
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    conversation,
+		Message: "Conversation retrieved successfully",
+	})
+}
+
+// DeleteConversation removes a conversation transcript of the caller
+func DeleteConversation(c *fiber.Ctx) error {
+	identity, _ := requestIdentity(c)
+	if !conversations.Default.Delete(identity.Caller, c.Params("id")) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Conversation not found",
+			Code:    fiber.StatusNotFound,
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Message: "Conversation deleted successfully",
+	})
+}
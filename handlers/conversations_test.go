@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"credibot-api/auth"
+	"credibot-api/conversations"
+	"credibot-api/models"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// useConversations replaces the conversation store for the duration of a test
+func useConversations(t *testing.T) {
+	previous := conversations.Default
+	conversations.Default = conversations.NewStore(0, 0)
+	t.Cleanup(func() { conversations.Default = previous })
+}
+
+func TestConversationsRequireAnAPIKey(t *testing.T) {
+	useFakeLLM(t, "")
+	useConversations(t)
+	apiKeys = auth.NewKeys([]models.APIKey{{Key: "k-painel", Caller: "painel", Role: "viewer"}})
+
+	app := fiber.New()
+	app.Use(Authenticate)
+	app.Post("/chat", Chat)
+	app.Post("/smart-chat", SmartChat)
+	app.Post("/jobs", SubmitJob)
+	post := func(path, key, body string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	for path, body := range map[string]string{
+		"/chat":       `{"message": "Meu CPF é 529.982.247-25", "conversation_id": "c1"}`,
+		"/smart-chat": `{"message": "Meu CPF é 529.982.247-25", "conversation_id": "c1"}`,
+		"/jobs":       `{"type": "chat", "message": "Meu CPF é 529.982.247-25", "conversation_id": "c1"}`,
+	} {
+		if status := post(path, "", body); status != fiber.StatusUnauthorized {
+			t.Errorf("anonymous %s: status %d, want 401", path, status)
+		}
+	}
+	if list := conversations.Default.List(""); len(list) != 0 {
+		t.Errorf("anonymous callers recorded %d conversations", len(list))
+	}
+
+	if status := post("/chat", "k-painel", `{"message": "Meu CPF é 529.982.247-25", "conversation_id": "c1"}`); status != fiber.StatusOK {
+		t.Fatalf("chat with a key: status %d", status)
+	}
+	if _, ok := conversations.Default.Get("painel", "c1"); !ok {
+		t.Error("the conversation was not recorded for the caller of the key")
+	}
+}
+
+func TestAnonymousSocketsHaveConversationsOfTheirOwn(t *testing.T) {
+	useFakeLLM(t, "")
+	useConversations(t)
+	provider := &recordingProvider{Provider: llmProvider}
+	llmProvider = provider
+
+	// Smart chat answers with the recorded conversation
+	ask := func(session *wsSession, question string) string {
+		session.handle(models.WSRequest{Type: wsTypeSmartChat, ChatRequest: models.ChatRequest{ConversationID: "c1", Message: question}})
+		session.wg.Wait()
+		var history []string
+		for _, message := range provider.last().Messages {
+			history = append(history, message.Content)
+		}
+		return strings.Join(history, "\n")
+	}
+
+	first, _ := newRecordedSession()
+	ask(first, "Meu CPF é 529.982.247-25")
+	if prompt := ask(first, "Qual é o meu CPF?"); !strings.Contains(prompt, "529.982.247-25") {
+		t.Errorf("the connection lost its own conversation: %q", prompt)
+	}
+
+	second, _ := newRecordedSession()
+	if prompt := ask(second, "Qual é o meu CPF?"); strings.Contains(prompt, "529.982.247-25") {
+		t.Errorf("another anonymous connection read the conversation: %q", prompt)
+	}
+}
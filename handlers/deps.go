@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"credibot-api/auth"
+	"credibot-api/cache"
+	"credibot-api/documents"
+	"credibot-api/experiments"
+	"credibot-api/guard"
+	"credibot-api/llm"
+	"credibot-api/prompts"
+	"credibot-api/usage"
+	"credibot-api/webhooks"
+	"net/http"
+)
+
+// Dependencies are the shared clients created once at startup
+type Dependencies struct {
+	LLM          llm.Provider
+	SupabaseHTTP *http.Client
+	WebhookHTTP  *http.Client
+	// WebhookAddresses decides which hosts callbacks may be sent to
+	WebhookAddresses *webhooks.AddressPolicy
+	Usage            usage.Store
+	Cache            cache.Cache
+	Prompts          *prompts.Registry
+	Experiments      *experiments.Set
+	// ExperimentStore keeps the outcomes of requests taking part in experiments
+	ExperimentStore experiments.Store
+	Documents       *documents.Library
+	Guard           *guard.Rules
+	APIKeys         *auth.Keys
+}
+
+var (
+	llmProvider        llm.Provider
+	supabaseHTTPClient *http.Client
+	webhookHTTPClient  *http.Client
+	webhookAddresses   *webhooks.AddressPolicy
+	usageStore         usage.Store
+	responseCache      cache.Cache
+	promptRegistry     *prompts.Registry
+	experimentSet      *experiments.Set
+	experimentStore    experiments.Store
+	documentLibrary    *documents.Library
+	guardRules         *guard.Rules
+	apiKeys            *auth.Keys
+)
+
+// Configure injects the shared clients used by every handler
+func Configure(deps Dependencies) {
+	llmProvider = deps.LLM
+	supabaseHTTPClient = deps.SupabaseHTTP
+	webhookHTTPClient = deps.WebhookHTTP
+	webhookAddresses = deps.WebhookAddresses
+	usageStore = deps.Usage
+	responseCache = deps.Cache
+	promptRegistry = deps.Prompts
+	experimentSet = deps.Experiments
+	experimentStore = deps.ExperimentStore
+	documentLibrary = deps.Documents
+	guardRules = deps.Guard
+	apiKeys = deps.APIKeys
+}
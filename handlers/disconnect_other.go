@@ -0,0 +1,10 @@
+//go:build !unix
+
+package handlers
+
+import "net"
+
+// peerClosed cannot peek at sockets on this platform; requests then end at their deadline
+func peerClosed(conn net.Conn) bool {
+	return false
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package handlers
+
+import (
+	"crypto/tls"
+	"net"
+	"syscall"
+)
+
+// peerClosed peeks at the socket without consuming data: a zero-byte read means the peer closed it
+func peerClosed(conn net.Conn) bool {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	closed := false
+	buf := make([]byte, 1)
+	raw.Read(func(fd uintptr) bool {
+		// The socket is non-blocking, so this returns EAGAIN while the peer is idle
+		n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK)
+		closed = n == 0 && err == nil
+		return true
+	})
+	return closed
+}
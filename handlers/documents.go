@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/config"
+	"credibot-api/documents"
+	"credibot-api/models"
+	"credibot-api/prompts"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// citationPattern matches the [n] markers the policy prompt asks the model to cite passages with
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// searchDocuments retrieves the policy document passages relevant to a question
+func searchDocuments(question string) []documents.Passage {
+	cfg := config.AppConfig.Documents
+	return documentLibrary.Search(question, cfg.TopK, cfg.MinScore)
+}
+
+// generatePolicyResponse answers a question from policy document passages, keeping as many
+// passages as the model's context window allows. The sources are the passages the answer
+// cites, or every passage shown when it cites none.
+func generatePolicyResponse(ctx context.Context, turn smartChatTurn, passages []documents.Passage, onDelta func(string)) (stageAnswer, error) {
+	systemPrompt, err := renderPrompt(ctx, prompts.Policy, prompts.Data{})
+	if err != nil {
+		return stageAnswer{}, err
+	}
+
+	answer, err := answerWithBudget(ctx, answerSettings(ctx, turn, config.AppConfig.SmartChat.GeneralAnswer), systemPrompt+"\n", turn.Question,
+		turn.History, len(passages), func(n int) string {
+			return createPassagesSummary(passages[:n])
+		}, onDelta)
+	if err != nil {
+		return stageAnswer{}, err
+	}
+
+	shown := passages[:len(passages)-answer.Budget.DroppedRecords]
+	answer.Sources = citations(answer.Content, shown)
+	return answer, nil
+}
+
+// createPassagesSummary numbers the passages for the model to cite
+func createPassagesSummary(passages []documents.Passage) string {
+	if len(passages) == 0 {
+		return "Nenhum trecho encontrado."
+	}
+
+	var summary strings.Builder
+	for i, passage := range passages {
+		fmt.Fprintf(&summary, "[%d] %s", i+1, passage.Title)
+		if passage.Section != "" {
+			fmt.Fprintf(&summary, " — %s", passage.Section)
+		}
+		fmt.Fprintf(&summary, " (%s)\n%s\n\n", passage.Document, passage.Text)
+	}
+	return summary.String()
+}
+
+// citations lists the passages an answer cites by their [n] marker
+func citations(answer string, passages []documents.Passage) []models.Citation {
+	cited := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		if ref, err := strconv.Atoi(match[1]); err == nil && ref >= 1 && ref <= len(passages) {
+			cited[ref] = true
+		}
+	}
+
+	var sources []models.Citation
+	for i, passage := range passages {
+		if len(cited) > 0 && !cited[i+1] {
+			continue
+		}
+		sources = append(sources, models.Citation{
+			Ref:      i + 1,
+			Document: passage.Document,
+			Title:    passage.Title,
+			Section:  passage.Section,
+			Score:    passage.Score,
+		})
+	}
+	return sources
+}
+
+// SearchDocuments returns the policy document passages retrieved for a query, to check
+// what the smart chat grounds its answers on
+func SearchDocuments(c *fiber.Ctx) error {
+	query := c.Query("q")
+	if query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Query parameter q is required",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	cfg := config.AppConfig.Documents
+	passages := documentLibrary.Search(query, c.QueryInt("k", cfg.TopK), cfg.MinScore)
+	if passages == nil {
+		passages = []documents.Passage{}
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    passages,
+		Message: "Documents searched successfully",
+	})
+}
+
+// ListDocuments lists the indexed policy documents
+func ListDocuments(c *fiber.Ctx) error {
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    documentLibrary.List(),
+		Message: "Documents retrieved successfully",
+	})
+}
+
+// ReindexDocuments indexes the policy documents again; on error the index in use is kept
+func ReindexDocuments(c *fiber.Ctx) error {
+	if err := documentLibrary.Reload(); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+			Code:    fiber.StatusUnprocessableEntity,
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    documentLibrary.List(),
+		Message: "Documents reindexed successfully",
+	})
+}
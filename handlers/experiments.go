@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/experiments"
+	"credibot-api/models"
+	"credibot-api/prompts"
+	"credibot-api/usage"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// UserHeader identifies the end user of a request, so experiments keep assigning them the
+// same variants. Without it requests are assigned by caller.
+const UserHeader = "X-User-ID"
+
+// experimentUser returns the unit of sticky experiment assignment of a request
+func experimentUser(c *fiber.Ctx) string {
+	if user := strings.TrimSpace(c.Get(UserHeader)); user != "" {
+		return user
+	}
+	return callerID(c)
+}
+
+// enterExperiments assigns a request to a variant of every running experiment, rendering
+// the prompt versions of the variants and tagging usage records with them. The models of
+// the variants are applied by stageSettings.
+func enterExperiments(ctx context.Context) (context.Context, experiments.Assignment) {
+	assignment := experimentSet.Assign(experiments.User(ctx))
+	if len(assignment) == 0 {
+		return ctx, nil
+	}
+
+	ctx = experiments.WithAssignment(ctx, assignment)
+	ctx = prompts.WithVersions(ctx, assignment.Prompts())
+	ctx = usage.WithVariants(ctx, assignment.IDs())
+	return ctx, assignment
+}
+
+// stageSettings returns the model settings of a stage, with the model of the request's variant
+func stageSettings(ctx context.Context, stage string, settings models.StageConfig) models.StageConfig {
+	if model := experiments.FromContext(ctx).Model(stage); model != "" {
+		settings.Model = model
+	}
+	return settings
+}
+
+// recordOutcome stores how a request taking part in experiments went and returns its id,
+// which feedback on the answer refers to
+func recordOutcome(assignment experiments.Assignment, start time.Time, response models.SmartChatResponse, tally *usage.Tally, err error) string {
+	outcome := experiments.Outcome{
+		RequestID:    uuid.NewString(),
+		Time:         start.UTC(),
+		Variants:     assignment.IDs(),
+		LatencyMs:    time.Since(start).Milliseconds(),
+		UsedDatabase: response.UsedDatabase,
+		Cached:       response.Cached,
+		TotalTokens:  tally.Usage().TotalTokens,
+		Cost:         tally.Cost(),
+		InvalidQuery: errors.Is(err, errInvalidSQL) || errors.Is(err, errInvalidTableQuery),
+	}
+	var pipelineErr *pipelineError
+	if errors.As(err, &pipelineErr) {
+		outcome.FailedStage = pipelineErr.Stage
+	}
+
+	log.Printf("Smart chat %s variants=%s latency=%dms tokens=%d failed_stage=%s",
+		outcome.RequestID, strings.Join(outcome.Variants, ","), outcome.LatencyMs, outcome.TotalTokens, outcome.FailedStage)
+	if err := experimentStore.AddOutcome(outcome); err != nil {
+		log.Printf("Failed to record experiment outcome %s: %v", outcome.RequestID, err)
+	}
+	return outcome.RequestID
+}
+
+// SubmitFeedback records whether an answer was helpful, for the experiment report.
+// Only answers given while experiments run have a request_id to rate.
+func SubmitFeedback(c *fiber.Ctx) error {
+	var req models.FeedbackRequest
+	if err := c.BodyParser(&req); err != nil || req.RequestID == "" || req.Helpful == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "request_id and helpful are required",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	if _, ok := experimentStore.Outcome(req.RequestID); !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Request not found",
+			Code:    fiber.StatusNotFound,
+		})
+	}
+
+	feedback := experiments.Feedback{
+		RequestID: req.RequestID,
+		Time:      time.Now().UTC(),
+		Helpful:   *req.Helpful,
+		Comment:   req.Comment,
+	}
+	if err := experimentStore.AddFeedback(feedback); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Failed to record feedback: " + err.Error(),
+			Code:    fiber.StatusInternalServerError,
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    feedback,
+		Message: "Feedback recorded successfully",
+	})
+}
+
+// ExperimentReport compares the variants of the running experiments
+func ExperimentReport(c *fiber.Ctx) error {
+	outcomes, feedback := experimentStore.List()
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    experimentSet.Report(outcomes, feedback),
+		Message: "Experiment report generated successfully",
+	})
+}
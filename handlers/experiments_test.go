@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/experiments"
+	"credibot-api/models"
+	"credibot-api/usage"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestExperimentVariantsAreAppliedAndReported(t *testing.T) {
+	useFakeLLM(t, "")
+	provider := &recordingProvider{Provider: llmProvider}
+	llmProvider = usage.NewMeter(usage.NewMemoryStore(), usage.NewPricing(nil)).Wrap(provider)
+	experimentStore = experiments.NewMemoryStore()
+	// Every user gets the mini variant, which only overrides the model of the analysis
+	set, err := experiments.New([]experiments.Experiment{{ID: "modelo", Variants: []experiments.Variant{
+		{ID: "controle", Weight: 0},
+		{ID: "mini", Weight: 1, Models: map[string]string{stageAnalysis: "gpt-4.1-mini"}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	experimentSet = set
+
+	response, err := RunSmartChat(experiments.WithUser(context.Background(), "ana"), models.ChatRequest{Message: "O que é score de crédito?"}, nil)
+	if err != nil {
+		t.Fatalf("RunSmartChat: %v", err)
+	}
+	if len(response.Variants) != 1 || response.Variants[0] != "modelo/mini" || response.RequestID == "" {
+		t.Fatalf("variants %v, request %q; want modelo/mini and a request id", response.Variants, response.RequestID)
+	}
+	called := map[string]bool{}
+	for _, req := range provider.requests {
+		called[req.Model] = true
+	}
+	if !called["gpt-4.1-mini"] || len(called) != 2 {
+		t.Errorf("models called: %v, want the variant's for the analysis and the configured one for the answer", called)
+	}
+
+	app := fiber.New()
+	app.Post("/feedback", SubmitFeedback)
+	app.Get("/experiments", ExperimentReport)
+	feedback := func(requestID string) int {
+		req := httptest.NewRequest("POST", "/feedback", strings.NewReader(`{"request_id": "`+requestID+`", "helpful": true}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	if status := feedback("desconhecido"); status != fiber.StatusNotFound {
+		t.Errorf("feedback on an unknown request: status %d, want 404", status)
+	}
+	if status := feedback(response.RequestID); status != fiber.StatusOK {
+		t.Fatalf("feedback: status %d", status)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/experiments", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var report struct{ Data []experiments.Report }
+	if err := json.Unmarshal(body, &report); err != nil || len(report.Data) != 1 {
+		t.Fatalf("report %s: %v", body, err)
+	}
+	mini := report.Data[0].Variants[1]
+	if mini.Requests != 1 || mini.Helpful != 1 || mini.TotalTokens == 0 {
+		t.Errorf("mini = %+v, want the request, its tokens and its feedback", mini)
+	}
+	if control := report.Data[0].Variants[0]; control.Requests != 0 {
+		t.Errorf("controle got %d requests", control.Requests)
+	}
+}
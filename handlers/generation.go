@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"credibot-api/config"
+	"credibot-api/models"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrInvalidParameters is returned when a chat request sets generation parameters
+// outside the limits of the deployment
+var ErrInvalidParameters = errors.New("invalid generation parameters")
+
+// maxStopSequences is the most stop sequences the model API accepts
+const maxStopSequences = 4
+
+// Response formats accepted in ChatRequest.ResponseFormat
+const (
+	responseFormatText = "text"
+	responseFormatJSON = "json_object"
+)
+
+// invalidParameters describes a rejected generation parameter
+func invalidParameters(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParameters, fmt.Sprintf(format, args...))
+}
+
+// allowedModels returns the models clients may request: the default model and CHAT_ALLOWED_MODELS
+func allowedModels() []string {
+	return append([]string{config.AppConfig.OpenAI.Model}, config.AppConfig.Generation.AllowedModels...)
+}
+
+// validateGeneration checks the generation parameters of a chat request against the
+// models and ranges the deployment allows
+func validateGeneration(req models.ChatRequest) error {
+	limits := config.AppConfig.Generation
+
+	if req.Model != "" {
+		allowed := false
+		for _, model := range allowedModels() {
+			if model == req.Model {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return invalidParameters("model %s is not allowed; allowed models: %s", req.Model, strings.Join(allowedModels(), ", "))
+		}
+	}
+
+	if req.MaxTokens < 0 {
+		return invalidParameters("max_tokens must be positive")
+	}
+	if limits.MaxTokens > 0 && req.MaxTokens > limits.MaxTokens {
+		return invalidParameters("max_tokens must be at most %d", limits.MaxTokens)
+	}
+	if t := req.Temperature; t != nil && (*t < limits.TemperatureMin || *t > limits.TemperatureMax) {
+		return invalidParameters("temperature must be between %g and %g", limits.TemperatureMin, limits.TemperatureMax)
+	}
+	if p := req.TopP; p != nil && (*p < limits.TopPMin || *p > limits.TopPMax) {
+		return invalidParameters("top_p must be between %g and %g", limits.TopPMin, limits.TopPMax)
+	}
+
+	if len(req.Stop) > maxStopSequences {
+		return invalidParameters("at most %d stop sequences are allowed", maxStopSequences)
+	}
+	for _, stop := range req.Stop {
+		if stop == "" {
+			return invalidParameters("stop sequences must not be empty")
+		}
+	}
+
+	switch req.ResponseFormat {
+	case "", responseFormatText:
+	case responseFormatJSON:
+		// The model API rejects JSON mode unless the prompt asks for JSON
+		if !strings.Contains(strings.ToLower(conversationText(req)), "json") {
+			return invalidParameters("response_format json_object requires the system prompt or messages to ask for JSON")
+		}
+	default:
+		return invalidParameters("response_format must be %s or %s", responseFormatText, responseFormatJSON)
+	}
+	return nil
+}
+
+// validateSmartChatGeneration rejects the generation parameters of /chat in smart chat requests,
+// whose prompts and sampling are set per pipeline stage. max_tokens bounds the final answer.
+func validateSmartChatGeneration(req models.ChatRequest) error {
+	if req.SystemPrompt != "" || req.Temperature != nil || req.TopP != nil ||
+		len(req.Stop) > 0 || req.Seed != nil || req.ResponseFormat != "" {
+		return invalidParameters("system_prompt, temperature, top_p, stop, seed and response_format are only supported by /chat")
+	}
+	if req.MaxTokens < 0 {
+		return invalidParameters("max_tokens must be positive")
+	}
+	if limit := config.AppConfig.Generation.MaxTokens; limit > 0 && req.MaxTokens > limit {
+		return invalidParameters("max_tokens must be at most %d", limit)
+	}
+	return nil
+}
+
+// applyGeneration sets the generation parameters of a chat request on a completion request
+func applyGeneration(req models.ChatRequest, completion *openai.ChatCompletionRequest) {
+	if req.Temperature != nil {
+		completion.Temperature = nonZero(*req.Temperature)
+	}
+	if req.TopP != nil {
+		completion.TopP = nonZero(*req.TopP)
+	}
+	completion.Stop = req.Stop
+	completion.Seed = req.Seed
+	if req.ResponseFormat == responseFormatJSON {
+		completion.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+}
+
+// nonZero keeps an explicit 0 from being dropped: the client omits zero sampling parameters,
+// which the model API reads as its default of 1
+func nonZero(value float32) float32 {
+	if value == 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return value
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/config"
+	"credibot-api/guard"
+	"credibot-api/models"
+	"credibot-api/prompts"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Refusals answered instead of the questions the guard stops
+const (
+	refusalOffTopic  = "Desculpe, só posso ajudar com perguntas sobre crédito, análise de risco e os dados da carteira de clientes."
+	refusalInjection = "Desculpe, não posso atender a esse pedido. Posso ajudar com perguntas sobre crédito, análise de risco e os dados da carteira de clientes."
+)
+
+// guardQuestion classifies a question before it reaches the analysis and SQL generation prompts.
+// The rules run first; the model classifies what they let through when GUARD_MODEL_CHECK is set.
+// A failed model check lets the question through, since the rules already passed it.
+func guardQuestion(ctx context.Context, question string) guard.Verdict {
+	if !config.AppConfig.Guard.Enabled {
+		return guard.Verdict{Category: guard.InDomain}
+	}
+
+	verdict := guardRules.Check(question)
+	if !verdict.Allowed() || !config.AppConfig.Guard.ModelCheck {
+		return verdict
+	}
+
+	verdict, err := classifyQuestion(ctx, question)
+	if err != nil {
+		log.Printf("Guard model check failed, letting the question through: %v", err)
+		return guard.Verdict{Category: guard.InDomain}
+	}
+	return verdict
+}
+
+// classifyQuestion asks the guard model for the category of a question
+func classifyQuestion(ctx context.Context, question string) (guard.Verdict, error) {
+	systemPrompt, err := renderPrompt(ctx, prompts.Guard, prompts.Data{})
+	if err != nil {
+		return guard.Verdict{}, err
+	}
+
+	stage := config.AppConfig.SmartChat.Guard
+	ctx, cancel := withBudget(ctx, stage.Timeout)
+	defer cancel()
+
+	req := stageRequest(stage, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: question},
+	})
+	req.ResponseFormat = structuredFormat(req.Model, "guard_verdict", guardSchema())
+
+	key := requestKey(cacheSmartChat, req)
+	var verdict guard.Verdict
+	if cacheGet(cacheSmartChat, key, &verdict) {
+		return verdict, nil
+	}
+
+	resp, err := structuredCompletion(ctx, req)
+	if err != nil {
+		return guard.Verdict{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return guard.Verdict{}, fmt.Errorf("no response from OpenAI")
+	}
+
+	// Models without structured outputs only promise a JSON object, so the schema is enforced here
+	var output struct {
+		Category string `json:"category"`
+	}
+	decoder := json.NewDecoder(strings.NewReader(strings.TrimSpace(resp.Choices[0].Message.Content)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&output); err != nil {
+		return guard.Verdict{}, fmt.Errorf("invalid guard output: %w", err)
+	}
+
+	switch output.Category {
+	case guard.InDomain:
+		verdict = guard.Verdict{Category: guard.InDomain}
+	case guard.OffTopic:
+		verdict = guard.Verdict{Category: guard.OffTopic, Reason: guard.ReasonOffTopic, Rule: "model"}
+	case guard.Injection:
+		verdict = guard.Verdict{Category: guard.Injection, Reason: guard.ReasonPromptInjection, Rule: "model"}
+	default:
+		return guard.Verdict{}, fmt.Errorf("invalid guard category: %q", output.Category)
+	}
+
+	cacheSet(cacheSmartChat, key, verdict)
+	return verdict, nil
+}
+
+// guardSchema returns the JSON schema the guard model output is constrained to
+func guardSchema() json.RawMessage {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"category": map[string]interface{}{
+				"type": "string",
+				"enum": []string{guard.InDomain, guard.OffTopic, guard.Injection},
+			},
+		},
+		"required":             []string{"category"},
+		"additionalProperties": false,
+	})
+	return data
+}
+
+// refuse logs a question the guard stopped and builds the polite answer to it
+func refuse(verdict guard.Verdict) (string, *models.Refusal) {
+	log.Printf("Smart chat refused a question: category=%s reason=%s rule=%s", verdict.Category, verdict.Reason, verdict.Rule)
+	message := refusalOffTopic
+	if verdict.Category == guard.Injection {
+		message = refusalInjection
+	}
+	return message, &models.Refusal{Category: verdict.Category, Reason: verdict.Reason}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"credibot-api/config"
+	"credibot-api/documents"
+	"credibot-api/experiments"
+	"credibot-api/guard"
+	"credibot-api/llm"
+	"credibot-api/prompts"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useFakeLLM configures the handlers as at startup, with the fake provider answering by
+// script, a JSON list of llm.FakeRule ("" echoes the questions), and no response cache.
+// The previous configuration is restored when the test ends.
+func useFakeLLM(t *testing.T, script string) {
+	t.Helper()
+	previous, previousConfig := Dependencies{
+		LLM: llmProvider, SupabaseHTTP: supabaseHTTPClient, WebhookHTTP: webhookHTTPClient, WebhookAddresses: webhookAddresses,
+		Usage: usageStore, Cache: responseCache, Prompts: promptRegistry, Experiments: experimentSet,
+		ExperimentStore: experimentStore, Documents: documentLibrary, Guard: guardRules, APIKeys: apiKeys,
+	}, config.AppConfig
+	t.Cleanup(func() {
+		Configure(previous)
+		config.AppConfig = previousConfig
+	})
+
+	t.Setenv("OPENAI_API_KEY", "test")
+	config.LoadConfig()
+
+	scriptPath := ""
+	if script != "" {
+		scriptPath = filepath.Join(t.TempDir(), "script.json")
+		if err := os.WriteFile(scriptPath, []byte(script), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	provider, err := llm.NewFakeProvider(scriptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := prompts.Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	library, err := documents.Open("", "", documents.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := guard.LoadRules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := experiments.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Dependencies{LLM: provider, Prompts: registry, Documents: library, Guard: rules, Experiments: set})
+}
+
+// fakeSupabase serves the rows of each table from /rest/v1/<table>, whatever the filters
+func fakeSupabase(t *testing.T, tables map[string][]map[string]interface{}) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rows, ok := tables[strings.TrimPrefix(r.URL.Path, "/rest/v1/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("SUPABASE_URL", server.URL)
+	t.Setenv("SUPABASE_API_KEY", "test")
+	supabaseHTTPClient = server.Client()
+}
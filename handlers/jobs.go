@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/auth"
+	"credibot-api/config"
+	"credibot-api/experiments"
+	"credibot-api/jobs"
+	"credibot-api/models"
+	"credibot-api/usage"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Job types
+const (
+	jobTypeChat      = "chat"
+	jobTypeSmartChat = "smart_chat"
+	jobTypeBatch     = "batch"
+)
+
+// jobsEndpoint attributes the model calls of queued jobs in usage accounting
+const jobsEndpoint = "/api/v1/jobs"
+
+var jobManager *jobs.Manager
+
+// StartJobs creates the job store and launches the background workers
+func StartJobs() error {
+	var store jobs.Store = jobs.NewMemoryStore()
+	if dir := config.AppConfig.Jobs.StoreDir; dir != "" {
+		fileStore, err := jobs.NewFileStore(dir)
+		if err != nil {
+			return err
+		}
+		store = fileStore
+	}
+
+	cfg := config.AppConfig.Jobs
+	jobManager = jobs.NewManager(store, executeJob, cfg.QueueSize, cfg.Timeout, cfg.MaxFinished, cfg.Retention)
+	startWebhooks(jobManager)
+	return jobManager.Start(config.AppConfig.Jobs.Workers)
+}
+
+// executeJob runs a job request through the matching pipeline
+func executeJob(ctx context.Context, req models.JobRequest) (interface{}, error) {
+	ctx = usage.WithCaller(ctx, req.Caller, jobsEndpoint)
+	ctx = experiments.WithUser(ctx, req.User)
+	if req.Role != "" {
+		ctx = auth.WithIdentity(ctx, auth.Identity{Caller: req.Caller, Role: req.Role})
+	}
+	switch req.Type {
+	case jobTypeChat:
+		return RunChat(ctx, req.ChatRequest, nil)
+	case jobTypeSmartChat:
+		return RunSmartChat(ctx, req.ChatRequest, nil)
+	case jobTypeBatch:
+		return RunSmartChatBatch(ctx, req.Questions), nil
+	default:
+		return nil, fmt.Errorf("unknown job type: %s", req.Type)
+	}
+}
+
+// validateJob checks a job request before it is queued
+func validateJob(req models.JobRequest) error {
+	if err := validateCallbackURL(req.CallbackURL); err != nil {
+		return err
+	}
+
+	switch req.Type {
+	case jobTypeChat, jobTypeSmartChat:
+		if req.Message == "" && (req.Type != jobTypeChat || len(req.Messages) == 0) {
+			return fmt.Errorf("Message is required")
+		}
+		validate := validateGeneration
+		if req.Type == jobTypeSmartChat {
+			validate = validateSmartChatGeneration
+		}
+		if err := validate(req.ChatRequest); err != nil {
+			return err
+		}
+	case jobTypeBatch:
+		return validateBatch(models.BatchRequest{Questions: req.Questions})
+	default:
+		return fmt.Errorf("Invalid job type: must be chat, smart_chat or batch")
+	}
+	return nil
+}
+
+// SubmitJob queues a request and returns the job id immediately
+func SubmitJob(c *fiber.Ctx) error {
+	var req models.JobRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request format",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+	req.Caller = callerID(c)
+	req.User = experimentUser(c)
+	req.Role = ""
+	if identity, ok := requestIdentity(c); ok {
+		req.Role = identity.Role
+	} else if req.ConversationID != "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: ErrAnonymousConversation.Error(),
+			Code:    fiber.StatusUnauthorized,
+		})
+	}
+
+	if err := validateJob(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	job, err := jobManager.Submit(jobOwner(c), req)
+	if err != nil {
+		code := fiber.StatusInternalServerError
+		if errors.Is(err, jobs.ErrQueueFull) {
+			code = fiber.StatusServiceUnavailable
+		}
+		return c.Status(code).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Failed to submit job: " + err.Error(),
+			Code:    code,
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(models.SuccessResponse{
+		Success: true,
+		Data:    job,
+		Message: "Job submitted successfully",
+	})
+}
+
+// jobOwner returns the owner of the jobs a request submits and may see: the caller its API key
+// authenticates, or no owner for anonymous callers, who reach their jobs by id alone
+func jobOwner(c *fiber.Ctx) string {
+	identity, _ := requestIdentity(c)
+	return identity.Caller
+}
+
+// GetJob returns the status, progress stage and result of a job of the caller
+func GetJob(c *fiber.Ctx) error {
+	job, err := jobManager.Get(jobOwner(c), c.Params("id"))
+	if err != nil {
+		return jobError(c, err)
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    job,
+		Message: "Job retrieved successfully",
+	})
+}
+
+// CancelJob cancels a queued or running job of the caller
+func CancelJob(c *fiber.Ctx) error {
+	job, err := jobManager.Cancel(jobOwner(c), c.Params("id"))
+	if err != nil {
+		return jobError(c, err)
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    job,
+		Message: "Job cancellation requested",
+	})
+}
+
+// jobError maps job manager errors to HTTP responses
+func jobError(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		code = fiber.StatusNotFound
+	case errors.Is(err, jobs.ErrFinished):
+		code = fiber.StatusConflict
+	}
+
+	return c.Status(code).JSON(models.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+		Code:    code,
+	})
+}
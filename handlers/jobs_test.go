@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"credibot-api/auth"
+	"credibot-api/jobs"
+	"credibot-api/models"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestJobsAreOnlyVisibleToTheirSubmitter(t *testing.T) {
+	useFakeLLM(t, "")
+	apiKeys = auth.NewKeys([]models.APIKey{
+		{Key: "k-auditoria", Caller: "auditoria", Role: "auditor"},
+		{Key: "k-painel", Caller: "painel", Role: "viewer"},
+	})
+	previous := jobManager
+	defer func() { jobManager = previous }()
+	jobManager = jobs.NewManager(jobs.NewMemoryStore(), executeJob, 10, time.Minute, 0, 0)
+	finished := make(chan jobs.Job, 1)
+	jobManager.OnFinish(func(job jobs.Job) { finished <- job })
+	if err := jobManager.Start(1); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Use(Authenticate)
+	app.Post("/jobs", SubmitJob)
+	app.Get("/jobs/:id", GetJob)
+	app.Delete("/jobs/:id", CancelJob)
+	send := func(method, path, key string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	req := httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"type": "chat", "message": "Qual o CPF da Maria Souza?"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(APIKeyHeader, "k-auditoria")
+	resp, err := app.Test(req)
+	if err != nil || resp.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("submit: %v, %v", resp, err)
+	}
+	var submitted struct{ Data jobs.Job }
+	json.NewDecoder(resp.Body).Decode(&submitted)
+	path := "/jobs/" + submitted.Data.ID
+	<-finished
+
+	for _, key := range []string{"", "k-painel"} {
+		if status := send("GET", path, key); status != fiber.StatusNotFound {
+			t.Errorf("GET with key %q: status %d, want 404", key, status)
+		}
+		if status := send("DELETE", path, key); status != fiber.StatusNotFound {
+			t.Errorf("DELETE with key %q: status %d, want 404", key, status)
+		}
+	}
+	if status := send("GET", path, "k-auditoria"); status != fiber.StatusOK {
+		t.Errorf("GET by the submitter: status %d, want 200", status)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"credibot-api/config"
+	"credibot-api/models"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrInvalidMessages is returned when the conversation sent by a client is malformed,
+// too large or breaks the system prompt policy
+var ErrInvalidMessages = errors.New("invalid messages")
+
+// Policies for client system messages (CHAT_SYSTEM_PROMPT_POLICY)
+const (
+	// systemPolicyPrepend puts the server system prompt before the client ones
+	systemPolicyPrepend = "prepend"
+	// systemPolicyReplace drops client system messages in favor of the server system prompt
+	systemPolicyReplace = "replace"
+	// systemPolicyForbid rejects requests carrying client system messages
+	systemPolicyForbid = "forbid"
+)
+
+// invalidMessages describes a rejected conversation
+func invalidMessages(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidMessages, fmt.Sprintf(format, args...))
+}
+
+// chatPrompt splits a chat request into its system prompts, the earlier turns and the question.
+// The conversation is either the single message or the messages sent by the client, whose
+// system messages must come first and whose last message must be the user's question.
+// The server system prompt is applied according to the configured policy.
+func chatPrompt(req models.ChatRequest) ([]string, []openai.ChatCompletionMessage, string, error) {
+	limits := config.AppConfig.Chat
+
+	conversation := req.Messages
+	if req.Message != "" {
+		if len(req.Messages) > 0 {
+			return nil, nil, "", invalidMessages("send either message or messages, not both")
+		}
+		conversation = []models.ChatMessage{{Role: openai.ChatMessageRoleUser, Content: req.Message}}
+	}
+	if len(conversation) == 0 {
+		return nil, nil, "", invalidMessages("message or messages is required")
+	}
+	if limits.MaxMessages > 0 && len(conversation) > limits.MaxMessages {
+		return nil, nil, "", invalidMessages("at most %d messages are allowed", limits.MaxMessages)
+	}
+
+	var clientSystem []string
+	if req.SystemPrompt != "" {
+		clientSystem = append(clientSystem, req.SystemPrompt)
+	}
+	var history []openai.ChatCompletionMessage
+	for i, message := range conversation {
+		if strings.TrimSpace(message.Content) == "" {
+			return nil, nil, "", invalidMessages("message %d is empty", i)
+		}
+		if limits.MaxMessageChars > 0 && utf8.RuneCountInString(message.Content) > limits.MaxMessageChars {
+			return nil, nil, "", invalidMessages("message %d exceeds %d characters", i, limits.MaxMessageChars)
+		}
+
+		switch message.Role {
+		case openai.ChatMessageRoleSystem:
+			if len(history) > 0 {
+				return nil, nil, "", invalidMessages("system messages must come before the conversation")
+			}
+			clientSystem = append(clientSystem, message.Content)
+		case openai.ChatMessageRoleUser, openai.ChatMessageRoleAssistant:
+			history = append(history, openai.ChatCompletionMessage{Role: message.Role, Content: message.Content})
+		default:
+			return nil, nil, "", invalidMessages("message %d has invalid role %q: must be system, user or assistant", i, message.Role)
+		}
+	}
+	if limits.MaxMessageChars > 0 && utf8.RuneCountInString(req.SystemPrompt) > limits.MaxMessageChars {
+		return nil, nil, "", invalidMessages("system_prompt exceeds %d characters", limits.MaxMessageChars)
+	}
+
+	if len(history) == 0 || history[len(history)-1].Role != openai.ChatMessageRoleUser {
+		return nil, nil, "", invalidMessages("the last message must be from the user")
+	}
+	question := history[len(history)-1].Content
+	history = history[:len(history)-1]
+
+	var system []string
+	if limits.SystemPrompt != "" {
+		system = append(system, limits.SystemPrompt)
+	}
+	switch limits.SystemPromptPolicy {
+	case systemPolicyForbid:
+		if len(clientSystem) > 0 {
+			return nil, nil, "", invalidMessages("system messages are not allowed")
+		}
+	case systemPolicyReplace:
+		// Client system messages give way to the server system prompt
+	default:
+		// systemPolicyPrepend, also used for unknown policies, which the configuration warns about
+		system = append(system, clientSystem...)
+	}
+	return system, history, question, nil
+}
+
+// conversationText joins every text of a chat request, e.g. to look for a keyword
+func conversationText(req models.ChatRequest) string {
+	parts := []string{req.SystemPrompt, req.Message}
+	for _, message := range req.Messages {
+		parts = append(parts, message.Content)
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"credibot-api/config"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// chatApp serves /chat with the fake model, recording what reaches it, and the server system
+// prompt applied with the given policy
+func chatApp(t *testing.T, policy string) (*fiber.App, *recordingProvider) {
+	t.Helper()
+	useFakeLLM(t, "")
+	config.AppConfig.Chat.SystemPrompt = "servidor"
+	config.AppConfig.Chat.SystemPromptPolicy = policy
+	provider := &recordingProvider{Provider: llmProvider}
+	llmProvider = provider
+
+	app := fiber.New()
+	app.Post("/chat", Chat)
+	return app, provider
+}
+
+// postChat sends a chat request and returns its status
+func postChat(t *testing.T, app *fiber.App, body string) int {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/chat", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+// sent lists the messages of the last model request as "role: content"
+func sent(provider *recordingProvider) []string {
+	var messages []string
+	for _, message := range provider.last().Messages {
+		messages = append(messages, message.Role+": "+message.Content)
+	}
+	return messages
+}
+
+// clientConversation has a system message, an earlier turn and the question
+const clientConversation = `{"system_prompt": "cliente", "messages": [
+	{"role": "system", "content": "Responda em inglês"},
+	{"role": "user", "content": "Quantos clientes?"},
+	{"role": "assistant", "content": "120"},
+	{"role": "user", "content": "E PJ?"}]}`
+
+func TestSingleMessageStillWorks(t *testing.T) {
+	app, provider := chatApp(t, systemPolicyPrepend)
+
+	if status := postChat(t, app, `{"message": "E PJ?"}`); status != fiber.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if got, want := sent(provider), []string{"system: servidor", "user: E PJ?"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestClientSystemMessagesFollowThePolicy(t *testing.T) {
+	history := []string{"user: Quantos clientes?", "assistant: 120", "user: E PJ?"}
+
+	app, provider := chatApp(t, systemPolicyPrepend)
+	if status := postChat(t, app, clientConversation); status != fiber.StatusOK {
+		t.Fatalf("prepend: status %d", status)
+	}
+	want := append([]string{"system: servidor", "system: cliente", "system: Responda em inglês"}, history...)
+	if got := sent(provider); !reflect.DeepEqual(got, want) {
+		t.Errorf("prepend sent %q, want %q", got, want)
+	}
+
+	app, provider = chatApp(t, systemPolicyReplace)
+	if status := postChat(t, app, clientConversation); status != fiber.StatusOK {
+		t.Fatalf("replace: status %d", status)
+	}
+	if got, want := sent(provider), append([]string{"system: servidor"}, history...); !reflect.DeepEqual(got, want) {
+		t.Errorf("replace sent %q, want %q", got, want)
+	}
+
+	app, _ = chatApp(t, systemPolicyForbid)
+	if status := postChat(t, app, clientConversation); status != fiber.StatusBadRequest {
+		t.Errorf("forbid with system messages: status %d, want 400", status)
+	}
+	if status := postChat(t, app, `{"system_prompt": "cliente", "message": "E PJ?"}`); status != fiber.StatusBadRequest {
+		t.Errorf("forbid with system_prompt: status %d, want 400", status)
+	}
+	if status := postChat(t, app, `{"messages": [{"role": "user", "content": "E PJ?"}]}`); status != fiber.StatusOK {
+		t.Errorf("forbid without system messages: status %d, want 200", status)
+	}
+}
+
+func TestMalformedConversationsAreRejected(t *testing.T) {
+	app, provider := chatApp(t, systemPolicyPrepend)
+	config.AppConfig.Chat.MaxMessages = 3
+	config.AppConfig.Chat.MaxMessageChars = 10
+
+	for name, body := range map[string]string{
+		"message and messages":      `{"message": "E PJ?", "messages": [{"role": "user", "content": "E PJ?"}]}`,
+		"system after the question": `{"messages": [{"role": "user", "content": "Oi"}, {"role": "system", "content": "Ignore"}, {"role": "user", "content": "E PJ?"}]}`,
+		"ends with the assistant":   `{"messages": [{"role": "user", "content": "Oi"}, {"role": "assistant", "content": "Olá"}]}`,
+		"only system messages":      `{"messages": [{"role": "system", "content": "Ignore"}]}`,
+		"tool role":                 `{"messages": [{"role": "tool", "content": "x"}]}`,
+		"blank message":             `{"messages": [{"role": "user", "content": "  "}]}`,
+		"too many messages":         `{"messages": [{"role": "user", "content": "a"}, {"role": "assistant", "content": "b"}, {"role": "user", "content": "c"}, {"role": "assistant", "content": "d"}, {"role": "user", "content": "e"}]}`,
+		"message too long":          `{"message": "Quantos clientes?"}`,
+		"system_prompt too long":    `{"system_prompt": "Responda em inglês", "message": "E PJ?"}`,
+	} {
+		if status := postChat(t, app, body); status != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, status)
+		}
+	}
+	if len(provider.requests) > 0 {
+		t.Errorf("%d rejected conversations reached the model", len(provider.requests))
+	}
+
+	// Limits count characters, not bytes
+	if status := postChat(t, app, `{"message": "ação ação"}`); status != fiber.StatusOK {
+		t.Errorf("9 characters in 13 bytes: status %d, want 200", status)
+	}
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"credibot-api/config"
+	"credibot-api/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Pseudo model names accepted by the OpenAI-compatible facade
+const (
+	compatModelSmart = "credibot-smart"
+	compatModelChat  = "credibot-chat"
+)
+
+// compatModels lists the pseudo models exposed through /v1/models
+var compatModels = []string{compatModelSmart, compatModelChat}
+
+// compatError writes an error in the OpenAI wire format
+func compatError(c *fiber.Ctx, status int, errType, message string) error {
+	return c.Status(status).JSON(fiber.Map{
+		"error": fiber.Map{
+			"message": message,
+			"type":    errType,
+			"code":    nil,
+		},
+	})
+}
+
+// CompatListModels lists the credibot pseudo models in the OpenAI wire format
+func CompatListModels(c *fiber.Ctx) error {
+	data := make([]openai.Model, 0, len(compatModels))
+	for _, name := range compatModels {
+		data = append(data, openai.Model{
+			ID:      name,
+			Object:  "model",
+			OwnedBy: "credibot",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// CompatChatCompletions accepts OpenAI Chat Completions requests and answers them through credibot.
+// The pseudo model selects the pipeline: credibot-smart uses smart chat, credibot-chat the plain chat.
+func CompatChatCompletions(c *fiber.Ctx) error {
+	var req openai.ChatCompletionRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return compatError(c, fiber.StatusBadRequest, "invalid_request_error", "Invalid request format")
+	}
+
+	if req.Model != compatModelSmart && req.Model != compatModelChat {
+		return compatError(c, fiber.StatusNotFound, "invalid_request_error",
+			fmt.Sprintf("The model '%s' does not exist. Available models: %s", req.Model, strings.Join(compatModels, ", ")))
+	}
+
+	if lastUserMessage(req.Messages) == "" {
+		return compatError(c, fiber.StatusBadRequest, "invalid_request_error", "At least one user message is required")
+	}
+
+	id := "chatcmpl-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	created := time.Now().Unix()
+
+	run := func(ctx context.Context, onDelta func(string)) (string, openai.Usage, error) {
+		if req.Model == compatModelSmart {
+			resp, err := RunSmartChat(ctx, compatSmartChatRequest(req), onDelta)
+			var used openai.Usage
+			if resp.Usage != nil {
+				used = openai.Usage{
+					PromptTokens:     resp.Usage.PromptTokens,
+					CompletionTokens: resp.Usage.CompletionTokens,
+					TotalTokens:      resp.Usage.TotalTokens,
+				}
+			}
+			return resp.Message, used, err
+		}
+
+		resp, err := RunChat(ctx, compatChatRequest(req), onDelta)
+		return resp.Message, openai.Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		}, err
+	}
+
+	if req.Stream {
+		return streamCompatCompletion(c, id, created, req.Model, run)
+	}
+
+	ctx, cancel := RequestContext(c, config.AppConfig.RequestTimeout)
+	defer cancel()
+
+	content, usage, err := run(ctx, nil)
+	if err != nil {
+		status, errType := compatFailure(err)
+		return compatError(c, status, errType, err.Error())
+	}
+
+	return c.JSON(openai.ChatCompletionResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   req.Model,
+		Choices: []openai.ChatCompletionChoice{{
+			Index: 0,
+			Message: openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: content,
+			},
+			FinishReason: openai.FinishReasonStop,
+		}},
+		Usage: usage,
+	})
+}
+
+// compatFailure maps a pipeline error to its HTTP status and OpenAI error type
+func compatFailure(err error) (int, string) {
+	switch {
+	case IsTimeout(err):
+		return fiber.StatusGatewayTimeout, "timeout"
+	case errors.Is(err, ErrInvalidParameters) || errors.Is(err, ErrInvalidMessages):
+		return fiber.StatusBadRequest, "invalid_request_error"
+	}
+	return fiber.StatusInternalServerError, "api_error"
+}
+
+// compatMaxTokens returns the answer token limit of an OpenAI request: max_completion_tokens,
+// or the deprecated max_tokens older clients send
+func compatMaxTokens(req openai.ChatCompletionRequest) int {
+	if req.MaxCompletionTokens > 0 {
+		return req.MaxCompletionTokens
+	}
+	return req.MaxTokens
+}
+
+// compatSmartChatRequest carries the conversation and answer token limit of an OpenAI request over
+// to a smart chat request. Smart chat sets the prompts and sampling of each stage, so the system
+// and developer messages and sampling parameters SDKs send are ignored rather than rejected.
+func compatSmartChatRequest(req openai.ChatCompletionRequest) models.ChatRequest {
+	chatReq := models.ChatRequest{MaxTokens: compatMaxTokens(req)}
+	for _, message := range req.Messages {
+		if message.Role == openai.ChatMessageRoleSystem || message.Role == openai.ChatMessageRoleDeveloper {
+			continue
+		}
+		chatReq.Messages = append(chatReq.Messages, models.ChatMessage{Role: message.Role, Content: messageText(message)})
+	}
+	return chatReq
+}
+
+// compatChatRequest carries the conversation and generation parameters of an OpenAI request over
+// to a chat request. Developer messages count as system messages. Zero temperature and top_p
+// cannot be told apart from unset ones, so they keep the defaults.
+func compatChatRequest(req openai.ChatCompletionRequest) models.ChatRequest {
+	chatReq := models.ChatRequest{MaxTokens: compatMaxTokens(req), Stop: req.Stop, Seed: req.Seed}
+	for _, message := range req.Messages {
+		role := message.Role
+		if role == openai.ChatMessageRoleDeveloper {
+			role = openai.ChatMessageRoleSystem
+		}
+		chatReq.Messages = append(chatReq.Messages, models.ChatMessage{Role: role, Content: messageText(message)})
+	}
+	if req.Temperature != 0 {
+		chatReq.Temperature = &req.Temperature
+	}
+	if req.TopP != 0 {
+		chatReq.TopP = &req.TopP
+	}
+	if req.ResponseFormat != nil {
+		chatReq.ResponseFormat = string(req.ResponseFormat.Type)
+	}
+	return chatReq
+}
+
+// streamCompatCompletion streams the answer as OpenAI chat.completion.chunk server-sent events
+func streamCompatCompletion(c *fiber.Ctx, id string, created int64, model string,
+	run func(context.Context, func(string)) (string, openai.Usage, error)) error {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+
+	// The stream writer runs after the handler returns, so the caller is captured now
+	base := withCaller(context.Background(), c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := withBudget(base, config.AppConfig.RequestTimeout)
+		defer cancel()
+
+		// A failed flush means the client went away
+		disconnected := false
+		writeEvent := func(payload interface{}) {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			if err := w.Flush(); err != nil {
+				disconnected = true
+				cancel()
+			}
+		}
+
+		chunk := func(delta openai.ChatCompletionStreamChoiceDelta, finish openai.FinishReason) openai.ChatCompletionStreamResponse {
+			return openai.ChatCompletionStreamResponse{
+				ID:      id,
+				Object:  "chat.completion.chunk",
+				Created: created,
+				Model:   model,
+				Choices: []openai.ChatCompletionStreamChoice{{Index: 0, Delta: delta, FinishReason: finish}},
+			}
+		}
+
+		writeEvent(chunk(openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant}, ""))
+
+		_, usage, err := run(ctx, func(delta string) {
+			writeEvent(chunk(openai.ChatCompletionStreamChoiceDelta{Content: delta}, ""))
+		})
+		// Nobody is left to read the end of the stream; a timeout still ends it with an error
+		if disconnected {
+			return
+		}
+		if err != nil {
+			_, errType := compatFailure(err)
+			writeEvent(fiber.Map{"error": fiber.Map{"message": err.Error(), "type": errType, "code": nil}})
+		} else {
+			final := chunk(openai.ChatCompletionStreamChoiceDelta{}, openai.FinishReasonStop)
+			final.Usage = &usage
+			writeEvent(final)
+		}
+
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		w.Flush()
+	})
+
+	return nil
+}
+
+// lastUserMessage returns the text of the most recent user message
+func lastUserMessage(messages []openai.ChatCompletionMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != openai.ChatMessageRoleUser {
+			continue
+		}
+		return messageText(messages[i])
+	}
+	return ""
+}
+
+// messageText returns the content of a message, joining the text parts of multi-part content
+func messageText(message openai.ChatCompletionMessage) string {
+	if message.Content != "" {
+		return message.Content
+	}
+
+	var parts []string
+	for _, part := range message.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText {
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/config"
+	"credibot-api/llm"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sashabaranov/go-openai"
+)
+
+// recordingProvider keeps the requests sent to the model it wraps
+type recordingProvider struct {
+	llm.Provider
+	mu       sync.Mutex
+	requests []openai.ChatCompletionRequest
+}
+
+func (p *recordingProvider) record(req openai.ChatCompletionRequest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+}
+
+// last returns the last request sent to the model
+func (p *recordingProvider) last() openai.ChatCompletionRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests[len(p.requests)-1]
+}
+
+func (p *recordingProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	p.record(req)
+	return p.Provider.CreateChatCompletion(ctx, req)
+}
+
+func (p *recordingProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (llm.Stream, error) {
+	p.record(req)
+	return p.Provider.CreateChatCompletionStream(ctx, req)
+}
+
+// hangingProvider never answers before the request deadline
+type hangingProvider struct{ llm.Provider }
+
+func (hangingProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	<-ctx.Done()
+	return openai.ChatCompletionResponse{}, ctx.Err()
+}
+
+func (hangingProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (llm.Stream, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// compatClient serves the OpenAI-compatible routes and returns an OpenAI SDK client of them
+func compatClient(t *testing.T) *openai.Client {
+	t.Helper()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Post("/v1/chat/completions", CompatChatCompletions)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	cfg := openai.DefaultConfig("unused")
+	cfg.BaseURL = "http://" + listener.Addr().String() + "/v1"
+	return openai.NewClientWithConfig(cfg)
+}
+
+// sdkRequest is what OpenAI SDK clients typically send: a system message and sampling parameters
+func sdkRequest(model string) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "You are a helpful assistant."},
+			{Role: openai.ChatMessageRoleDeveloper, Content: "Answer briefly."},
+			{Role: openai.ChatMessageRoleUser, Content: "Quantos clientes PJ?"},
+			{Role: openai.ChatMessageRoleAssistant, Content: "São 120."},
+			{Role: openai.ChatMessageRoleUser, Content: "E PF?"},
+		},
+		Temperature:         0.7,
+		TopP:                0.9,
+		Stop:                []string{"\n\n"},
+		Seed:                new(int),
+		ResponseFormat:      &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeText},
+		MaxCompletionTokens: 64,
+	}
+}
+
+func TestCompatSmartChatAcceptsSDKRequests(t *testing.T) {
+	useFakeLLM(t, "")
+	provider := &recordingProvider{Provider: llmProvider}
+	llmProvider = provider
+	client := compatClient(t)
+
+	resp, err := client.CreateChatCompletion(context.Background(), sdkRequest(compatModelSmart))
+	if err != nil {
+		t.Fatalf("CreateChatCompletion: %v", err)
+	}
+	if want := "Resposta simulada: E PF?"; resp.Choices[0].Message.Content != want {
+		t.Errorf("answer = %q, want %q", resp.Choices[0].Message.Content, want)
+	}
+
+	// The answer stage keeps its own prompt and sampling, with the conversation and token limit of the client
+	answer := provider.last()
+	if answer.MaxTokens != 64 || answer.Temperature != config.AppConfig.SmartChat.GeneralAnswer.Temperature || answer.Seed != nil || len(answer.Stop) > 0 {
+		t.Errorf("answer request: max_tokens %d, temperature %v, seed %v, stop %v", answer.MaxTokens, answer.Temperature, answer.Seed, answer.Stop)
+	}
+	for _, message := range answer.Messages[1:] {
+		if strings.Contains(message.Content, "helpful assistant") || strings.Contains(message.Content, "briefly") {
+			t.Errorf("client system message reached the model: %q", message.Content)
+		}
+	}
+	if got := len(answer.Messages); got != 4 {
+		t.Errorf("answer request has %d messages, want the stage prompt and the 3 turns", got)
+	}
+}
+
+func TestCompatChatForwardsMaxCompletionTokens(t *testing.T) {
+	useFakeLLM(t, "")
+	provider := &recordingProvider{Provider: llmProvider}
+	llmProvider = provider
+	client := compatClient(t)
+
+	req := sdkRequest(compatModelChat)
+	req.ResponseFormat = nil
+	if _, err := client.CreateChatCompletion(context.Background(), req); err != nil {
+		t.Fatalf("CreateChatCompletion: %v", err)
+	}
+	if got := provider.last(); got.MaxTokens != 64 || got.Temperature != 0.7 {
+		t.Errorf("model request: max_tokens %d, temperature %v; want 64, 0.7", got.MaxTokens, got.Temperature)
+	}
+
+	// Older clients send max_tokens
+	req.MaxCompletionTokens, req.MaxTokens = 0, 32
+	if _, err := client.CreateChatCompletion(context.Background(), req); err != nil {
+		t.Fatalf("CreateChatCompletion: %v", err)
+	}
+	if got := provider.last(); got.MaxTokens != 32 {
+		t.Errorf("model request: max_tokens %d, want 32", got.MaxTokens)
+	}
+}
+
+func TestCompatStreamEndsWithAnErrorOnTimeout(t *testing.T) {
+	useFakeLLM(t, "")
+	llmProvider = hangingProvider{llmProvider}
+	config.AppConfig.RequestTimeout = 100 * time.Millisecond
+	config.AppConfig.LLMHTTP.Retry.MaxAttempts = 1
+	client := compatClient(t)
+
+	for _, model := range compatModels {
+		req := sdkRequest(model)
+		req.Stream = true
+		stream, err := client.CreateChatCompletionStream(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: CreateChatCompletionStream: %v", model, err)
+		}
+
+		// The SDK reports the error event; a stream cut without [DONE] would surface as an unexpected EOF
+		var apiErr *openai.APIError
+		for {
+			_, err = stream.Recv()
+			if err != nil {
+				break
+			}
+		}
+		stream.Close()
+		if !errors.As(err, &apiErr) || apiErr.Type != "timeout" {
+			t.Errorf("%s: stream ended with %v, want a timeout error event", model, err)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: stream ended without an error event", model)
+		}
+	}
+}
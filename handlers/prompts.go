@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/models"
+	"credibot-api/prompts"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// renderPrompt renders the active version of a system prompt with the credit schema
+func renderPrompt(ctx context.Context, name string, data prompts.Data) (string, error) {
+	data.Schema = CreditSchema
+	return promptRegistry.Render(ctx, name, data)
+}
+
+// ListPrompts lists the prompt templates with their versions and the active one
+func ListPrompts(c *fiber.Ctx) error {
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    promptRegistry.List(),
+		Message: "Prompts retrieved successfully",
+	})
+}
+
+// ReloadPrompts reads the prompt templates again; on error the templates in use are kept
+func ReloadPrompts(c *fiber.Ctx) error {
+	if err := promptRegistry.Reload(); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+			Code:    fiber.StatusUnprocessableEntity,
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    promptRegistry.List(),
+		Message: "Prompts reloaded successfully",
+	})
+}
+
+// ActivatePrompt switches a prompt to another of its versions, e.g. to roll back a change
+func ActivatePrompt(c *fiber.Ctx) error {
+	var req models.ActivatePromptRequest
+	if err := c.BodyParser(&req); err != nil || req.Version == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Version is required",
+			Code:    fiber.StatusBadRequest,
+		})
+	}
+
+	if err := promptRegistry.Activate(c.Params("name"), req.Version); err != nil {
+		status := fiber.StatusNotFound
+		if errors.Is(err, prompts.ErrUnknownVersion) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+			Code:    status,
+		})
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    promptRegistry.List(),
+		Message: "Prompt version activated successfully",
+	})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/auth"
+	"credibot-api/config"
+	"credibot-api/models"
+	"credibot-api/redact"
+)
+
+// personNameColumns are the columns holding the names of clients, by table
+var personNameColumns = map[string][]string{
+	"clientes": {"nome"},
+}
+
+// answerRedactor returns the redactor of the caller's answers, or nil when its role sees
+// personal data unmasked. Only roles authenticated by an API key may; anonymous callers are
+// always masked. names are the full names of the data set the answer is about.
+func answerRedactor(ctx context.Context, names []string) *redact.Redactor {
+	cfg := config.AppConfig.Redaction
+	if !cfg.Enabled {
+		return nil
+	}
+	if identity, ok := auth.FromContext(ctx); ok {
+		for _, fullAccess := range cfg.FullAccessRoles {
+			if identity.Role == fullAccess {
+				return nil
+			}
+		}
+	}
+	return redact.New(names)
+}
+
+// redactAnswer masks the personal data of an answer for callers whose role does not see it.
+// The returned callback streams the redacted answer to onDelta; finish redacts the final
+// answer, flushes the stream and reports whether anything was masked.
+func redactAnswer(ctx context.Context, names []string, onDelta func(string)) (func(string), func(string) (string, bool)) {
+	redactor := answerRedactor(ctx, names)
+	if redactor == nil {
+		return onDelta, func(answer string) (string, bool) { return answer, false }
+	}
+
+	var stream *redact.Stream
+	if onDelta != nil {
+		stream = redactor.Stream(onDelta)
+		onDelta = stream.Write
+	}
+	return onDelta, func(answer string) (string, bool) {
+		if stream != nil {
+			stream.Close(answer)
+		}
+		redacted := redactor.Redact(answer)
+		return redacted, redacted != answer
+	}
+}
+
+// queriedTables returns the tables a smart chat query read: the table of the table query, or
+// the one the SQL query runs against, whatever tables the model reported using
+func queriedTables(sqlQuery string, tableQuery *models.TableQuery) []string {
+	if tableQuery != nil {
+		return []string{tableQuery.Table}
+	}
+	if sqlQuery != "" {
+		return []string{convertSQLToPostgREST(sqlQuery)}
+	}
+	return nil
+}
+
+// redactRows masks the personal data of rows read from a table for callers whose role does
+// not see it: client names word by word, and CPFs, emails and the like in every other text
+func redactRows(ctx context.Context, table string, rows []map[string]interface{}) {
+	redactor := answerRedactor(ctx, nil)
+	if redactor == nil {
+		return
+	}
+
+	nameColumns := make(map[string]bool)
+	for _, column := range personNameColumns[table] {
+		nameColumns[column] = true
+	}
+	for _, row := range rows {
+		for column, value := range row {
+			text, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if nameColumns[column] {
+				row[column] = redact.MaskName(text)
+			} else {
+				row[column] = redactor.Redact(text)
+			}
+		}
+	}
+}
+
+// datasetNames collects the client names in query results of the given tables
+func datasetNames(tables []string, data []map[string]interface{}) []string {
+	var columns []string
+	for _, table := range tables {
+		columns = append(columns, personNameColumns[table]...)
+	}
+
+	var names []string
+	for _, record := range data {
+		for _, column := range columns {
+			if name, ok := record[column].(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
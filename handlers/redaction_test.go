@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/auth"
+	"credibot-api/models"
+	"reflect"
+	"testing"
+)
+
+// misreportedTables answers the analysis with a query on clientes while claiming to use
+// operacoes_credito, which has no names to mask
+const misreportedTables = `[
+	{"contains": ["needs_database", "tables_used"], "response": "{\"intent\":\"data_query\",\"needs_database\":true,\"sql\":\"SELECT nome, score_credito FROM clientes\",\"tables_used\":[\"operacoes_credito\"],\"confidence\":0.9,\"clarification\":null}"},
+	{"contains": ["RESUMO DOS DADOS"], "response": "O cliente João da Silva tem score 720."}
+]`
+
+var auditor = auth.Identity{Caller: "auditoria", Role: "auditor"}
+
+func TestSmartChatMasksNamesOfTheQueriedTable(t *testing.T) {
+	useFakeLLM(t, misreportedTables)
+	fakeSupabase(t, map[string][]map[string]interface{}{
+		"clientes": {{"nome": "João da Silva", "score_credito": 720}},
+	})
+	req := models.ChatRequest{Message: "Qual o score do João da Silva?"}
+
+	response, err := RunSmartChat(context.Background(), req, nil)
+	if err != nil {
+		t.Fatalf("RunSmartChat: %v", err)
+	}
+	if want := "O cliente J*** d* S**** tem score 720."; response.Message != want || !response.Redacted {
+		t.Errorf("anonymous answer = %q (redacted %v), want %q", response.Message, response.Redacted, want)
+	}
+
+	response, err = RunSmartChat(auth.WithIdentity(context.Background(), auditor), req, nil)
+	if err != nil {
+		t.Fatalf("RunSmartChat: %v", err)
+	}
+	if want := "O cliente João da Silva tem score 720."; response.Message != want || response.Redacted {
+		t.Errorf("auditor answer = %q (redacted %v), want %q", response.Message, response.Redacted, want)
+	}
+}
+
+func TestQueryTableMasksRowsByRole(t *testing.T) {
+	useFakeLLM(t, "")
+	fakeSupabase(t, map[string][]map[string]interface{}{
+		"clientes": {{"id": "c1", "nome": "Maria Souza", "classe_risco": "contato maria@exemplo.com", "score_credito": 650}},
+	})
+	query := models.TableQuery{Table: "clientes", Columns: []string{"id", "nome", "classe_risco", "score_credito"}}
+
+	rows, err := QueryTable(context.Background(), query)
+	if err != nil {
+		t.Fatalf("QueryTable: %v", err)
+	}
+	masked := map[string]interface{}{"id": "c1", "nome": "M**** S****", "classe_risco": "contato m****@exemplo.com", "score_credito": 650.0}
+	if len(rows) != 1 || !reflect.DeepEqual(rows[0], masked) {
+		t.Errorf("anonymous rows = %v, want [%v]", rows, masked)
+	}
+
+	rows, err = QueryTable(auth.WithIdentity(context.Background(), auth.Identity{Caller: "painel", Role: "viewer"}), query)
+	if err != nil {
+		t.Fatalf("QueryTable: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["nome"] != "M**** S****" {
+		t.Errorf("viewer rows = %v, want the name masked", rows)
+	}
+
+	rows, err = QueryTable(auth.WithIdentity(context.Background(), auditor), query)
+	if err != nil {
+		t.Fatalf("QueryTable: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["nome"] != "Maria Souza" || rows[0]["classe_risco"] != "contato maria@exemplo.com" {
+		t.Errorf("auditor rows = %v, want them unmasked", rows)
+	}
+}
+
+func TestGetClientProfileMasksEveryTable(t *testing.T) {
+	useFakeLLM(t, "")
+	fakeSupabase(t, map[string][]map[string]interface{}{
+		"clientes":          {{"id": "c1", "nome": "Maria Souza"}},
+		"analises_credito":  {{"id": "a1", "cliente_id": "c1", "decisao": "aprovado para CPF 529.982.247-25"}},
+		"operacoes_credito": {},
+		"score_historico":   {},
+	})
+
+	profile, err := GetClientProfile(context.Background(), "c1", "")
+	if err != nil {
+		t.Fatalf("GetClientProfile: %v", err)
+	}
+	client := profile["cliente"].(map[string]interface{})
+	analyses := profile["analises_credito"].([]map[string]interface{})
+	if client["nome"] != "M**** S****" || analyses[0]["decisao"] != "aprovado para CPF ***.982.247-**" {
+		t.Errorf("anonymous profile = %v, want names and CPFs masked", profile)
+	}
+
+	profile, err = GetClientProfile(auth.WithIdentity(context.Background(), auditor), "c1", "")
+	if err != nil {
+		t.Fatalf("GetClientProfile: %v", err)
+	}
+	if client := profile["cliente"].(map[string]interface{}); client["nome"] != "Maria Souza" {
+		t.Errorf("auditor profile = %v, want it unmasked", profile)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/models"
+	"credibot-api/tokens"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// disconnectPollInterval is how often an in-flight request checks whether its client went away
+const disconnectPollInterval = 250 * time.Millisecond
+
+// statusClientClosedRequest is reported when the client disconnected before the answer was ready
+const statusClientClosedRequest = 499
+
+// RequestContext returns the context of a request: bounded by timeout (0 means no deadline)
+// and cancelled when the client disconnects, so abandoned requests stop their upstream calls.
+// Model calls made with it are attributed to the caller and route in usage accounting.
+func RequestContext(c *fiber.Ctx, timeout time.Duration) (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(withCaller(c.UserContext(), c), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(withCaller(c.UserContext(), c))
+	}
+
+	if conn := c.Context().Conn(); conn != nil {
+		go watchDisconnect(ctx, conn, cancel)
+	}
+	return ctx, cancel
+}
+
+// watchDisconnect cancels the request when the peer closes the connection
+func watchDisconnect(ctx context.Context, conn net.Conn, cancel context.CancelFunc) {
+	ticker := time.NewTicker(disconnectPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if peerClosed(conn) {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// IsTimeout reports whether an error comes from an expired deadline
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// timeoutMessage describes a timeout, naming the pipeline stage that ran out of time
+func timeoutMessage(err error) string {
+	var pErr *pipelineError
+	if errors.As(err, &pErr) {
+		return "Request timed out during the " + pErr.Stage + " stage"
+	}
+	return "Request timed out"
+}
+
+// pipelineErrorResponse maps a pipeline error to its HTTP status: 504 naming the stage that
+// ran out of time, 499 when the client disconnected, 400 when the prompt cannot fit the model or the
+// generation parameters are not allowed, 500 otherwise
+func pipelineErrorResponse(c *fiber.Ctx, ctx context.Context, err error) error {
+	var stage string
+	var pErr *pipelineError
+	if errors.As(err, &pErr) {
+		stage = pErr.Stage
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return c.Status(statusClientClosedRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Client closed request",
+			Code:    statusClientClosedRequest,
+			Stage:   stage,
+		})
+	case IsTimeout(err):
+		return c.Status(fiber.StatusGatewayTimeout).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: timeoutMessage(err),
+			Code:    fiber.StatusGatewayTimeout,
+			Stage:   stage,
+		})
+	case errors.Is(err, ErrAnonymousConversation):
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+			Code:    fiber.StatusUnauthorized,
+		})
+	case errors.Is(err, tokens.ErrContextExceeded), errors.Is(err, ErrInvalidParameters),
+		errors.Is(err, ErrInvalidMessages):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+			Code:    fiber.StatusBadRequest,
+			Stage:   stage,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+		Code:    fiber.StatusInternalServerError,
+		Stage:   stage,
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import "credibot-api/models"
+
+// CreditSchema describes the credit tables available for read-only queries
+var CreditSchema = []models.TableSchema{
+	{
+		Name:        "clientes",
+		Description: "Informações dos clientes",
+		Columns:     []string{"id", "nome", "score_credito", "classe_risco", "tipo_pessoa", "renda_mensal", "created_at"},
+	},
+	{
+		Name:        "analises_credito",
+		Description: "Análises de crédito realizadas",
+		Columns:     []string{"id", "cliente_id", "decisao", "valor_solicitado", "valor_aprovado", "created_at"},
+	},
+	{
+		Name:        "operacoes_credito",
+		Description: "Operações de crédito ativas",
+		Columns:     []string{"id", "cliente_id", "valor_contratado", "status", "modalidade", "dias_atraso", "created_at"},
+	},
+	{
+		Name:        "historico_pagamentos",
+		Description: "Histórico de pagamentos",
+		Columns:     []string{"id", "operacao_id", "status", "valor_pago", "dias_atraso", "created_at"},
+	},
+	{
+		Name:        "modalidades_credito",
+		Description: "Modalidades de crédito disponíveis",
+		Columns:     []string{"id", "nome", "categoria", "taxa_minima", "taxa_maxima", "created_at"},
+	},
+	{
+		Name:        "score_historico",
+		Description: "Histórico de scores",
+		Columns:     []string{"id", "cliente_id", "score_atual", "score_anterior", "created_at"},
+	},
+}
+
+// findTable returns the schema of a credit table
+func findTable(name string) (models.TableSchema, bool) {
+	for _, table := range CreditSchema {
+		if table.Name == name {
+			return table, true
+		}
+	}
+	return models.TableSchema{}, false
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"credibot-api/config"
 	"credibot-api/models"
+	"credibot-api/prompts"
+	"credibot-api/usage"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,7 +31,7 @@ func SmartChat(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Message == "" {
+	if req.Message == "" && len(req.Messages) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   true,
 			Message: "Message is required",
@@ -35,154 +39,358 @@ func SmartChat(c *fiber.Ctx) error {
 		})
 	}
 
+	ctx, cancel := RequestContext(c, config.AppConfig.RequestTimeout)
+	defer cancel()
+
+	response, err := RunSmartChat(ctx, req, nil)
+	if err != nil {
+		return pipelineErrorResponse(c, ctx, err)
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    response,
+		Message: "Smart chat response generated successfully",
+	})
+}
+
+// Smart chat pipeline stages
+const (
+	stageGuard    = "guard"
+	stageAnalysis = "analysis"
+	stageSQL      = "sql_generation"
+	stageQuery    = "query"
+	stageResponse = "response"
+)
+
+// errInvalidSQL is returned when the generated SQL fails the safety validation
+var errInvalidSQL = errors.New("invalid or unsafe SQL query generated")
+
+// stageRequest builds a chat completion request with the model settings of a stage
+func stageRequest(stage models.StageConfig, messages []openai.ChatCompletionMessage) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model:       stage.Model,
+		Messages:    messages,
+		MaxTokens:   stage.MaxTokens,
+		Temperature: stage.Temperature,
+	}
+}
+
+// withBudget bounds a context by the time budget of a stage; 0 means no budget beyond the request deadline
+func withBudget(ctx context.Context, budget time.Duration) (context.Context, context.CancelFunc) {
+	if budget <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, budget)
+}
+
+// pipelineError reports the smart chat stage that failed
+type pipelineError struct {
+	Stage   string
+	Message string
+	Err     error
+}
+
+func (e *pipelineError) Error() string {
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *pipelineError) Unwrap() error {
+	return e.Err
+}
+
+// RunSmartChat answers a question, consulting the database when needed.
+// The final answer is streamed to onDelta when it is set.
+func RunSmartChat(ctx context.Context, req models.ChatRequest, onDelta func(string)) (models.SmartChatResponse, error) {
+	if err := validateSmartChatGeneration(req); err != nil {
+		return models.SmartChatResponse{}, err
+	}
+	if err := checkConversationOwner(ctx, req.ConversationID); err != nil {
+		return models.SmartChatResponse{}, err
+	}
+
+	ctx, tally := usage.WithTally(ctx)
+	ctx, assignment := enterExperiments(ctx)
+	start := time.Now()
+
+	response, err := runSmartChat(ctx, req, tally, onDelta)
+	if len(assignment) > 0 {
+		response.RequestID = recordOutcome(assignment, start, response, tally, err)
+		response.Variants = assignment.IDs()
+	}
+	return response, err
+}
+
+// smartChatTurn is a smart chat question with the conversation before it and the max tokens
+// of the answer; 0 keeps the max tokens of the answer stage
+type smartChatTurn struct {
+	Question  string
+	History   []openai.ChatCompletionMessage
+	MaxTokens int
+}
+
+// smartChatQuestion takes the question of a smart chat request and the conversation before it:
+// the messages sent by the client, or the recorded conversation when only message is sent.
+// The stage prompts are the system prompts, so client system messages are rejected.
+func smartChatQuestion(ctx context.Context, req models.ChatRequest) (smartChatTurn, error) {
+	turn := smartChatTurn{MaxTokens: req.MaxTokens}
+	if len(req.Messages) == 0 {
+		turn.Question = req.Message
+		turn.History = conversationHistory(ctx, req.ConversationID)
+		return turn, nil
+	}
+
+	if req.ConversationID != "" {
+		return turn, invalidMessages("send either messages or conversation_id, not both")
+	}
+	for i, message := range req.Messages {
+		if message.Role == openai.ChatMessageRoleSystem {
+			return turn, invalidMessages("message %d: system messages are only supported by /chat", i)
+		}
+	}
+	_, history, question, err := chatPrompt(models.ChatRequest{Message: req.Message, Messages: req.Messages})
+	if err != nil {
+		return turn, err
+	}
+	turn.Question, turn.History = question, history
+	return turn, nil
+}
+
+// answerSettings returns the settings of the answer stage for a turn
+func answerSettings(ctx context.Context, turn smartChatTurn, settings models.StageConfig) models.StageConfig {
+	settings = stageSettings(ctx, stageResponse, settings)
+	if turn.MaxTokens > 0 {
+		settings.MaxTokens = turn.MaxTokens
+	}
+	return settings
+}
+
+// runSmartChat runs the smart chat pipeline stages
+func runSmartChat(ctx context.Context, req models.ChatRequest, tally *usage.Tally, onDelta func(string)) (models.SmartChatResponse, error) {
+	turn, err := smartChatQuestion(ctx, req)
+	if err != nil {
+		return models.SmartChatResponse{}, err
+	}
+	question := turn.Question
+	ctx, trace := prompts.WithTrace(ctx)
+
+	// Off-topic questions and injection attempts never reach the analysis and SQL prompts
+	ctx = enterStage(ctx, stageGuard)
+	if verdict := guardQuestion(ctx, question); !verdict.Allowed() {
+		message, refused := refuse(verdict)
+		if onDelta != nil {
+			onDelta(message)
+		}
+		return models.SmartChatResponse{
+			Message:        message,
+			Refusal:        refused,
+			PromptVersions: trace.Versions(),
+			Usage:          toUsage(tally),
+			CreatedAt:      time.Now(),
+		}, nil
+	}
+
 	// First, determine if the question requires database consultation
-	needsDatabase, sqlQuery, err := analyzeQuestionAndGenerateSQL(req.Message)
+	ctx = enterStage(ctx, stageAnalysis)
+	var (
+		needsDatabase bool
+		sqlQuery      string
+		tableQuery    *models.TableQuery
+		analysis      *models.QuestionAnalysis
+	)
+	if config.AppConfig.SmartChat.Mode == smartChatModeTools {
+		tableQuery, err = analyzeQuestionWithTools(ctx, question)
+		needsDatabase = tableQuery != nil
+	} else {
+		// A distinct SQL model means classification only routes and SQL is generated separately
+		smartChat := config.AppConfig.SmartChat
+		withSQL := smartChat.SQLGeneration.Model == smartChat.Classification.Model
+
+		var result models.QuestionAnalysis
+		result, err = analyzeQuestion(ctx, question, withSQL)
+		analysis = &result
+		needsDatabase, sqlQuery = result.NeedsDatabase, result.SQL
+		if err == nil && needsDatabase && !withSQL {
+			ctx = enterStage(ctx, stageSQL)
+			sqlQuery, err = generateSQL(ctx, question, result.TablesUsed)
+			if err != nil {
+				return models.SmartChatResponse{}, &pipelineError{stageSQL, "Failed to generate SQL query", err}
+			}
+			analysis.SQL = sqlQuery
+		}
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   true,
-			Message: "Failed to analyze question: " + err.Error(),
-			Code:    fiber.StatusInternalServerError,
-		})
+		return models.SmartChatResponse{}, &pipelineError{stageAnalysis, "Failed to analyze question", err}
 	}
 
-	var finalResponse string
+	var (
+		finalResponse string
+		answer        stageAnswer
+		redacted      bool
+	)
 
-	if needsDatabase && sqlQuery != "" {
-		// Execute the SQL query against Supabase
-		queryResult, err := executeSupabaseQuery(sqlQuery)
+	// Personal data in answers is masked unless the caller's role may see it
+	if analysis != nil && analysis.Intent == intentClarification {
+		// Ambiguous questions are answered with the clarifying question
+		onAnswer, finish := redactAnswer(ctx, nil, onDelta)
+		if onAnswer != nil {
+			onAnswer(analysis.Clarification)
+		}
+		finalResponse, redacted = finish(analysis.Clarification)
+	} else if needsDatabase {
+		// Execute the query against Supabase
+		ctx = enterStage(ctx, stageQuery)
+		queryCtx, cancel := withBudget(ctx, config.AppConfig.SmartChat.QueryTimeout)
+		var queryResult []map[string]interface{}
+		if tableQuery != nil {
+			queryResult, err = executeTableQuery(queryCtx, *tableQuery)
+		} else {
+			queryResult, err = executeSupabaseQuery(queryCtx, sqlQuery)
+		}
+		cancel()
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-				Error:   true,
-				Message: "Failed to execute database query: " + err.Error(),
-				Code:    fiber.StatusInternalServerError,
-			})
+			return models.SmartChatResponse{}, &pipelineError{stageQuery, "Failed to execute database query", err}
 		}
 
 		// Generate final response based on the data
-		finalResponse, err = generateResponseWithData(req.Message, queryResult)
+		ctx = enterStage(ctx, stageResponse)
+		onAnswer, finish := redactAnswer(ctx, datasetNames(queriedTables(sqlQuery, tableQuery), queryResult), onDelta)
+		answer, err = generateResponseWithData(ctx, turn, queryResult, onAnswer)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-				Error:   true,
-				Message: "Failed to generate response with data: " + err.Error(),
-				Code:    fiber.StatusInternalServerError,
-			})
+			return models.SmartChatResponse{}, &pipelineError{stageResponse, "Failed to generate response with data", err}
 		}
+		finalResponse, redacted = finish(answer.Content)
 	} else {
 		// For general questions, use regular OpenAI chat
-		finalResponse, err = generateRegularResponse(req.Message)
+		ctx = enterStage(ctx, stageResponse)
+		onAnswer, finish := redactAnswer(ctx, nil, onDelta)
+		answer, err = generateRegularResponse(ctx, turn, onAnswer)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-				Error:   true,
-				Message: "Failed to generate response: " + err.Error(),
-				Code:    fiber.StatusInternalServerError,
-			})
+			return models.SmartChatResponse{}, &pipelineError{stageResponse, "Failed to generate response", err}
 		}
+		finalResponse, redacted = finish(answer.Content)
 	}
 
-	response := models.SmartChatResponse{
-		Message:      finalResponse,
-		UsedDatabase: needsDatabase,
-		SQLQuery:     sqlQuery,
-		DatabaseData: nil, // Removido para melhor performance
-		CreatedAt:    time.Now(),
+	recordExchange(ctx, req.ConversationID, question, finalResponse)
+
+	return models.SmartChatResponse{
+		Message:        finalResponse,
+		Model:          answer.Model,
+		UsedDatabase:   needsDatabase,
+		SQLQuery:       sqlQuery,
+		TableQuery:     tableQuery,
+		Analysis:       analysis,
+		Budget:         answer.Budget,
+		Cached:         answer.Cached,
+		PromptVersions: trace.Versions(),
+		Sources:        answer.Sources,
+		Redacted:       redacted,
+		Usage:          toUsage(tally),
+		DatabaseData:   nil, // Removido para melhor performance
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// analyzeQuestion classifies a question with schema-constrained JSON output.
+// With withSQL it also generates the SQL; otherwise the SQL generation stage does.
+func analyzeQuestion(ctx context.Context, question string, withSQL bool) (models.QuestionAnalysis, error) {
+	// Without SQL the classification only routes, so it uses the prompt without the sql rule
+	name := prompts.Analysis
+	if !withSQL {
+		name = prompts.Classification
+	}
+	systemPrompt, err := renderPrompt(ctx, name, prompts.Data{})
+	if err != nil {
+		return models.QuestionAnalysis{}, err
 	}
 
-	return c.JSON(models.SuccessResponse{
-		Success: true,
-		Data:    response,
-		Message: "Smart chat response generated successfully",
+	stage := stageSettings(ctx, stageAnalysis, config.AppConfig.SmartChat.Classification)
+	ctx, cancel := withBudget(ctx, stage.Timeout)
+	defer cancel()
+
+	req := stageRequest(stage, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: question},
 	})
-}
+	req.ResponseFormat = structuredFormat(req.Model, "question_analysis", analysisSchema())
 
-// analyzeQuestionAndGenerateSQL determines if a question needs database access and generates SQL
-func analyzeQuestionAndGenerateSQL(question string) (bool, string, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return false, "", fmt.Errorf("OpenAI API key not configured")
-	}
-
-	client := openai.NewClient(apiKey)
-
-	systemPrompt := `Assistente de análise de crédito com SQL.
-
-TABELAS:
-- clientes: nome, score_credito, classe_risco, tipo_pessoa, renda_mensal
-- analises_credito: decisao, valor_solicitado, valor_aprovado, cliente_id
-- operacoes_credito: valor_contratado, status, modalidade, dias_atraso, cliente_id
-- historico_pagamentos: status, valor_pago, dias_atraso, operacao_id
-- modalidades_credito: nome, categoria, taxa_minima, taxa_maxima
-- score_historico: score_atual, score_anterior, cliente_id
-
-REGRAS:
-1. Apenas SELECT permitido
-2. Sempre usar LIMIT (max 50)
-3. Se precisa de dados: responda EXATAMENTE "SQL: [query sem formatação]"
-4. Se não precisa: responda "NO_DATABASE_NEEDED"
-5. NÃO use markdown, code blocks ou formatação
-
-EXEMPLO: SQL: SELECT nome FROM clientes LIMIT 10
-
-PERGUNTA: ` + question
-
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: config.AppConfig.OpenAI.Model, // Use model from .env
-			Messages: []openai.ChatCompletionMessage{
-				{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-				{Role: openai.ChatMessageRoleUser, Content: question},
-			},
-			MaxTokens:   150, // Reduced for SQL generation
-			Temperature: 0.1, // Low temperature for consistent SQL generation
-		},
-	)
+	// Only validated analyses are cached, so a schema violation is retried on the next request
+	key := requestKey(cacheSmartChat, req)
+	var analysis models.QuestionAnalysis
+	if cacheGet(cacheSmartChat, key, &analysis) {
+		return analysis, nil
+	}
 
+	resp, err := structuredCompletion(ctx, req)
 	if err != nil {
-		return false, "", err
+		return models.QuestionAnalysis{}, err
 	}
 
 	if len(resp.Choices) == 0 {
-		return false, "", fmt.Errorf("no response from OpenAI")
+		return models.QuestionAnalysis{}, fmt.Errorf("no response from OpenAI")
 	}
 
-	response := strings.TrimSpace(resp.Choices[0].Message.Content)
-	
-	if response == "NO_DATABASE_NEEDED" {
-		return false, "", nil
+	analysis, err = decodeAnalysis(resp.Choices[0].Message.Content, withSQL)
+	if err != nil {
+		return models.QuestionAnalysis{}, err
 	}
 
-	// Extract SQL from response
-	sqlQuery := extractSQLFromResponse(response)
-	
-	if sqlQuery == "" {
-		return false, "", nil
+	if analysis.NeedsDatabase && withSQL {
+		// Validate SQL for security
+		analysis.SQL = cleanSQLFromMarkdown(analysis.SQL)
+		if !isValidSelectQuery(analysis.SQL) {
+			return models.QuestionAnalysis{}, errInvalidSQL
+		}
 	}
 
-	// Validate SQL for security
-	if !isValidSelectQuery(sqlQuery) {
-		return false, "", fmt.Errorf("invalid or unsafe SQL query generated")
+	cacheSet(cacheSmartChat, key, analysis)
+	return analysis, nil
+}
+
+// generateSQL writes the SQL query for a question classified as needing data
+func generateSQL(ctx context.Context, question string, tables []string) (string, error) {
+	systemPrompt, err := renderPrompt(ctx, prompts.SQLGeneration, prompts.Data{Tables: tables})
+	if err != nil {
+		return "", err
 	}
 
-	return true, sqlQuery, nil
-}
+	stage := stageSettings(ctx, stageSQL, config.AppConfig.SmartChat.SQLGeneration)
+	ctx, cancel := withBudget(ctx, stage.Timeout)
+	defer cancel()
+
+	req := stageRequest(stage, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: question},
+	})
+	req.ResponseFormat = structuredFormat(req.Model, "sql_query", sqlSchema())
 
-// extractSQLFromResponse extracts SQL query from OpenAI response
-func extractSQLFromResponse(response string) string {
-	// Look for "SQL:" prefix
-	if strings.HasPrefix(response, "SQL:") {
-		sql := strings.TrimSpace(strings.TrimPrefix(response, "SQL:"))
-		return cleanSQLFromMarkdown(sql)
+	key := requestKey(cacheSmartChat, req)
+	var sqlQuery string
+	if cacheGet(cacheSmartChat, key, &sqlQuery) {
+		return sqlQuery, nil
 	}
-	
-	// Look for SQL: anywhere in response
-	if idx := strings.Index(response, "SQL:"); idx != -1 {
-		sql := strings.TrimSpace(response[idx+4:])
-		return cleanSQLFromMarkdown(sql)
+
+	resp, err := structuredCompletion(ctx, req)
+	if err != nil {
+		return "", err
 	}
-	
-	// Try to find SQL pattern - more flexible regex
-	re := regexp.MustCompile(`(?i)SELECT\s+.+?FROM\s+\w+(?:\s+WHERE\s+.+?)?(?:\s+ORDER\s+BY\s+.+?)?(?:\s+LIMIT\s+\d+)?`)
-	return re.FindString(response)
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response from OpenAI")
+	}
+
+	sqlQuery, err = decodeSQL(resp.Choices[0].Message.Content)
+	if err != nil {
+		return "", err
+	}
+
+	// Validate SQL for security
+	sqlQuery = cleanSQLFromMarkdown(sqlQuery)
+	if !isValidSelectQuery(sqlQuery) {
+		return "", errInvalidSQL
+	}
+	cacheSet(cacheSmartChat, key, sqlQuery)
+	return sqlQuery, nil
 }
 
 // cleanSQLFromMarkdown removes markdown formatting from SQL
@@ -238,7 +446,7 @@ func isValidSelectQuery(query string) bool {
 }
 
 // executeSupabaseQuery executes the SQL query against Supabase
-func executeSupabaseQuery(sqlQuery string) ([]map[string]interface{}, error) {
+func executeSupabaseQuery(ctx context.Context, sqlQuery string) ([]map[string]interface{}, error) {
 	baseURL := os.Getenv("SUPABASE_URL")
 	apiKey := os.Getenv("SUPABASE_API_KEY")
 	
@@ -250,7 +458,7 @@ func executeSupabaseQuery(sqlQuery string) ([]map[string]interface{}, error) {
 	// Convert basic SQL to PostgREST format
 	restQuery := convertSQLToPostgREST(sqlQuery)
 	
-	responseBody, err := makeSupabaseRequest("GET", restQuery, nil, nil)
+	responseBody, err := makeSupabaseRequest(ctx, "GET", restQuery, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -279,64 +487,71 @@ func convertSQLToPostgREST(sqlQuery string) string {
 	return matches[1]
 }
 
-// generateResponseWithData creates a natural language response based on query results
-func generateResponseWithData(originalQuestion string, data []map[string]interface{}) (string, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return "", fmt.Errorf("OpenAI API key not configured")
-	}
+// stageAnswer is the outcome of a response stage: the answer, the model that gave it,
+// how its prompt used the context window and whether it came from the cache
+type stageAnswer struct {
+	Content string
+	Model   string
+	Budget  *models.PromptBudget
+	Cached  bool
+	// Sources are the document passages the answer cites
+	Sources []models.Citation
+}
 
-	client := openai.NewClient(apiKey)
+// answerWithBudget fits the data summary and conversation history into the context window
+// of the stage model, then asks for the answer. dataSummary renders the first n of records
+// records; it is nil when the answer needs no data.
+func answerWithBudget(ctx context.Context, stage models.StageConfig, systemPrompt, question string,
+	history []openai.ChatCompletionMessage, records int, dataSummary func(n int) string, onDelta func(string)) (stageAnswer, error) {
+	budget := newBudget(stage.Model, stage.MaxTokens)
+	budget.AddSystem(systemPrompt)
+	budget.AddUser(question)
+	// Data answers the question, so it is fitted before older turns of the conversation
+	if dataSummary != nil {
+		systemPrompt += budget.FitData(records, dataSummary)
+	}
+	history = budget.FitHistory(history)
 
-	// Limit data to avoid token overflow - take only first 10 records and summarize
-	limitedData := data
-	if len(data) > 10 {
-		limitedData = data[:10]
+	report, err := budget.Report()
+	if err != nil {
+		return stageAnswer{}, err
 	}
 
-	// Create a summary instead of full JSON to save tokens
-	dataSummary := createDataSummary(limitedData)
-	
-	systemPrompt := `Você é um assistente especializado em análise de crédito. 
-
-Baseado nos dados fornecidos do banco de dados, responda à pergunta do usuário de forma natural e informativa.
-
-INSTRUÇÕES:
-- Use os dados fornecidos para responder
-- Seja claro e objetivo  
-- Formate números adequadamente (valores monetários em R$, percentuais com %)
-- Destaque informações importantes
-- Se não houver dados, informe que não foram encontrados registros
-- Limite a resposta a no máximo 300 palavras
-
-RESUMO DOS DADOS: ` + dataSummary
-
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: config.AppConfig.OpenAI.Model,
-			Messages: []openai.ChatCompletionMessage{
-				{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-				{Role: openai.ChatMessageRoleUser, Content: originalQuestion},
-			},
-			MaxTokens:   400,
-			Temperature: config.AppConfig.OpenAI.Temperature,
-		},
-	)
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: systemPrompt}}
+	messages = append(messages, history...)
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: question})
+
+	ctx, cancel := withBudget(ctx, stage.Timeout)
+	defer cancel()
 
+	resp, cached, err := cachedCompletion(ctx, cacheSmartChat, stageRequest(stage, messages), onDelta)
 	if err != nil {
-		return "", err
+		return stageAnswer{}, err
 	}
 
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response from OpenAI")
+		return stageAnswer{}, fmt.Errorf("no response from OpenAI")
+	}
+
+	return stageAnswer{Content: resp.Choices[0].Message.Content, Model: resp.Model, Budget: &report, Cached: cached}, nil
+}
+
+// generateResponseWithData generates a natural language response based on the query
+// results, keeping as many records as the model's context window allows
+func generateResponseWithData(ctx context.Context, turn smartChatTurn, data []map[string]interface{}, onDelta func(string)) (stageAnswer, error) {
+	systemPrompt, err := renderPrompt(ctx, prompts.Narration, prompts.Data{})
+	if err != nil {
+		return stageAnswer{}, err
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return answerWithBudget(ctx, answerSettings(ctx, turn, config.AppConfig.SmartChat.DataNarration), systemPrompt+"\n", turn.Question,
+		turn.History, len(data), func(n int) string {
+			return createDataSummary(data, n)
+		}, onDelta)
 }
 
-// createDataSummary creates a concise summary of data to avoid token overflow
-func createDataSummary(data []map[string]interface{}) string {
+// createDataSummary summarizes the first shown records of data, noting how many were left out
+func createDataSummary(data []map[string]interface{}, shown int) string {
 	if len(data) == 0 {
 		return "Nenhum dado encontrado."
 	}
@@ -345,8 +560,8 @@ func createDataSummary(data []map[string]interface{}) string {
 	
 	// Show first few records with key information
 	for i, record := range data {
-		if i >= 5 { // Limit to first 5 records for summary
-			summary += fmt.Sprintf("... e mais %d registros\n", len(data)-5)
+		if i >= shown {
+			summary += fmt.Sprintf("... e mais %d registros\n", len(data)-shown)
 			break
 		}
 		
@@ -361,52 +576,39 @@ func createDataSummary(data []map[string]interface{}) string {
 				summary += fmt.Sprintf("  %s: %v\n", field, value)
 			}
 		}
+		// Aggregates computed by query_table are aliased as <function>_<column>; they are listed
+		// in name order so the same rows always produce the same prompt
+		var aggregates []string
+		for field := range record {
+			for function := range aggregateFunctions {
+				if strings.HasPrefix(field, function+"_") {
+					aggregates = append(aggregates, field)
+					break
+				}
+			}
+		}
+		sort.Strings(aggregates)
+		for _, field := range aggregates {
+			summary += fmt.Sprintf("  %s: %v\n", field, record[field])
+		}
 		summary += "\n"
 	}
 	
 	return summary
 }
 
-// generateRegularResponse generates a regular OpenAI response for general questions
-func generateRegularResponse(question string) (string, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return "", fmt.Errorf("OpenAI API key not configured")
+// generateRegularResponse generates a regular OpenAI response for general questions,
+// grounded on the credit policy documents when they have passages about the question
+func generateRegularResponse(ctx context.Context, turn smartChatTurn, onDelta func(string)) (stageAnswer, error) {
+	if passages := searchDocuments(turn.Question); len(passages) > 0 {
+		return generatePolicyResponse(ctx, turn, passages, onDelta)
 	}
 
-	client := openai.NewClient(apiKey)
-
-	systemPrompt := `Você é um assistente especializado em análise de crédito e serviços financeiros.
-	
-Responda perguntas sobre:
-- Conceitos de crédito e financiamento
-- Análise de risco
-- Scores de crédito
-- Modalidades de empréstimo
-- Educação financeira
-
-Seja profissional, claro e informativo.`
-
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: config.AppConfig.OpenAI.Model,
-			Messages: []openai.ChatCompletionMessage{
-				{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-				{Role: openai.ChatMessageRoleUser, Content: question},
-			},
-			MaxTokens:   300,
-			Temperature: config.AppConfig.OpenAI.Temperature,
-		},
-	)
-
+	systemPrompt, err := renderPrompt(ctx, prompts.General, prompts.Data{})
 	if err != nil {
-		return "", err
-	}
-
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response from OpenAI")
+		return stageAnswer{}, err
 	}
 
-	return resp.Choices[0].Message.Content, nil
-}
\ No newline at end of file
+	return answerWithBudget(ctx, answerSettings(ctx, turn, config.AppConfig.SmartChat.GeneralAnswer), systemPrompt, turn.Question,
+		turn.History, 0, nil, onDelta)
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"credibot-api/config"
 	"credibot-api/models"
 	"encoding/json"
 	"fmt"
@@ -14,7 +16,7 @@ import (
 )
 
 // makeSupabaseRequest makes HTTP requests to Supabase REST API
-func makeSupabaseRequest(method, table string, body interface{}, queryParams map[string]string) ([]byte, error) {
+func makeSupabaseRequest(ctx context.Context, method, table string, body interface{}, queryParams map[string]string) ([]byte, error) {
 	baseURL := os.Getenv("SUPABASE_URL")
 	apiKey := os.Getenv("SUPABASE_API_KEY")
 	
@@ -46,7 +48,7 @@ func makeSupabaseRequest(method, table string, body interface{}, queryParams map
 		reqBody = bytes.NewBuffer(jsonBody)
 	}
 
-	req, err := http.NewRequest(method, url, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, err
 	}
@@ -56,8 +58,7 @@ func makeSupabaseRequest(method, table string, body interface{}, queryParams map
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Prefer", "return=representation")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := supabaseHTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -87,46 +88,50 @@ func GetData(c *fiber.Ctx) error {
 	}
 
 	// Optional query parameters with safety limits
-	limit := c.Query("limit", "10")
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
 	offset := c.Query("offset", "0")
 	orderBy := c.Query("order_by", "created_at")
-	
-	limitInt, _ := strconv.Atoi(limit)
-	if limitInt > 50 { // Safety limit to prevent token overflow
-		limitInt = 50
+
+	ctx, cancel := RequestContext(c, config.AppConfig.RequestTimeout)
+	defer cancel()
+
+	data, err := FetchData(ctx, table, limit, offset, orderBy)
+	if err != nil {
+		return pipelineErrorResponse(c, ctx, err)
 	}
-	
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    data,
+		Message: "Data retrieved successfully",
+	})
+}
+
+// FetchData reads rows from a table, newest first according to orderBy
+func FetchData(ctx context.Context, table string, limit int, offset, orderBy string) ([]map[string]interface{}, error) {
+	if limit > 50 { // Safety limit to prevent token overflow
+		limit = 50
+	}
+
 	queryParams := map[string]string{
 		"select": "*",
-		"limit":  strconv.Itoa(limitInt), // Use the safety-limited value
+		"limit":  strconv.Itoa(limit), // Use the safety-limited value
 		"offset": offset,
 		"order":  orderBy + ".desc",
 	}
 
-	responseBody, err := makeSupabaseRequest("GET", table, nil, queryParams)
+	responseBody, err := makeSupabaseRequest(ctx, "GET", table, nil, queryParams)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   true,
-			Message: "Failed to fetch data: " + err.Error(),
-			Code:    fiber.StatusInternalServerError,
-		})
+		return nil, fmt.Errorf("Failed to fetch data: %w", err)
 	}
 
 	var data []map[string]interface{}
 	err = json.Unmarshal(responseBody, &data)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   true,
-			Message: "Failed to parse response: " + err.Error(),
-			Code:    fiber.StatusInternalServerError,
-		})
+		return nil, fmt.Errorf("Failed to parse response: %w", err)
 	}
 
-	return c.JSON(models.SuccessResponse{
-		Success: true,
-		Data:    data,
-		Message: "Data retrieved successfully",
-	})
+	return data, nil
 }
 
 // InsertData inserts new data into a table
@@ -149,7 +154,7 @@ func InsertData(c *fiber.Ctx) error {
 		})
 	}
 
-	responseBody, err := makeSupabaseRequest("POST", table, data, nil)
+	responseBody, err := makeSupabaseRequest(c.UserContext(), "POST", table, data, nil)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   true,
@@ -201,7 +206,7 @@ func UpdateData(c *fiber.Ctx) error {
 		"id": "eq." + id,
 	}
 
-	responseBody, err := makeSupabaseRequest("PATCH", table, data, queryParams)
+	responseBody, err := makeSupabaseRequest(c.UserContext(), "PATCH", table, data, queryParams)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   true,
@@ -244,7 +249,7 @@ func DeleteData(c *fiber.Ctx) error {
 		"id": "eq." + id,
 	}
 
-	responseBody, err := makeSupabaseRequest("DELETE", table, nil, queryParams)
+	responseBody, err := makeSupabaseRequest(c.UserContext(), "DELETE", table, nil, queryParams)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   true,
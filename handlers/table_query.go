@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/config"
+	"credibot-api/models"
+	"credibot-api/prompts"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Smart chat data access modes
+const (
+	smartChatModeSQL   = "sql"
+	smartChatModeTools = "tools"
+)
+
+// queryTableFunction is the name of the function offered to the model
+const queryTableFunction = "query_table"
+
+// errInvalidTableQuery is returned when the query_table arguments fail validation
+var errInvalidTableQuery = errors.New("invalid query_table arguments")
+
+// maxQueryLimit caps the rows returned by a table query
+const maxQueryLimit = 50
+
+// filterOperators maps the operators accepted in filters to PostgREST operators
+var filterOperators = map[string]string{
+	"eq":    "eq",
+	"neq":   "neq",
+	"gt":    "gt",
+	"gte":   "gte",
+	"lt":    "lt",
+	"lte":   "lte",
+	"like":  "like",
+	"ilike": "ilike",
+	"in":    "in",
+	"is":    "is",
+}
+
+// aggregateFunctions lists the aggregates accepted in table queries
+var aggregateFunctions = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+}
+
+// queryTableTool describes the query_table function offered to the model
+func queryTableTool() openai.Tool {
+	description, parameters := QueryTableSchema()
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        queryTableFunction,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}
+}
+
+// QueryTableSchema returns the description and JSON schema of the query_table arguments,
+// listing the tables and columns of CreditSchema
+func QueryTableSchema() (string, map[string]interface{}) {
+	tables := make([]string, 0, len(CreditSchema))
+	var description strings.Builder
+	description.WriteString("Consulta somente leitura às tabelas de crédito. Tabelas e colunas disponíveis:\n")
+	for _, table := range CreditSchema {
+		tables = append(tables, table.Name)
+		fmt.Fprintf(&description, "- %s (%s): %s\n", table.Name, table.Description, strings.Join(table.Columns, ", "))
+	}
+
+	operators := make([]string, 0, len(filterOperators))
+	for operator := range filterOperators {
+		operators = append(operators, operator)
+	}
+	functions := make([]string, 0, len(aggregateFunctions))
+	for function := range aggregateFunctions {
+		functions = append(functions, function)
+	}
+	sort.Strings(operators)
+	sort.Strings(functions)
+
+	parameters := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"table": map[string]interface{}{
+				"type": "string",
+				"enum": tables,
+			},
+			"columns": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Colunas retornadas; vazio retorna todas",
+			},
+			"filters": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"column":   map[string]interface{}{"type": "string"},
+						"operator": map[string]interface{}{"type": "string", "enum": operators},
+						"value":    map[string]interface{}{"description": "Valor comparado; lista para o operador in, null/true/false para is"},
+					},
+					"required": []string{"column", "operator", "value"},
+				},
+			},
+			"order": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"column":    map[string]interface{}{"type": "string"},
+						"direction": map[string]interface{}{"type": "string", "enum": []string{"asc", "desc"}},
+					},
+					"required": []string{"column"},
+				},
+			},
+			"limit": map[string]interface{}{
+				"type":    "integer",
+				"minimum": 1,
+				"maximum": maxQueryLimit,
+			},
+			"aggregates": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"function": map[string]interface{}{"type": "string", "enum": functions},
+						"column":   map[string]interface{}{"type": "string", "description": "Obrigatória exceto para count"},
+					},
+					"required": []string{"function"},
+				},
+			},
+		},
+		"required": []string{"table"},
+	}
+
+	return description.String(), parameters
+}
+
+// analyzeQuestionWithTools lets the model decide whether to call query_table.
+// It returns nil when the question does not need the database.
+func analyzeQuestionWithTools(ctx context.Context, question string) (*models.TableQuery, error) {
+	systemPrompt, err := renderPrompt(ctx, prompts.ToolsRouting, prompts.Data{})
+	if err != nil {
+		return nil, err
+	}
+
+	// The model both routes and writes the query, so it uses the SQL generation settings
+	stage := stageSettings(ctx, stageAnalysis, config.AppConfig.SmartChat.SQLGeneration)
+	ctx, cancel := withBudget(ctx, stage.Timeout)
+	defer cancel()
+
+	req := stageRequest(stage, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: question},
+	})
+	req.Tools = []openai.Tool{queryTableTool()}
+	req.ToolChoice = "auto"
+
+	// The routing decision is cached, including "no query" for general questions
+	key := requestKey(cacheSmartChat, req)
+	var cached struct{ Query *models.TableQuery }
+	if cacheGet(cacheSmartChat, key, &cached) {
+		return cached.Query, nil
+	}
+
+	resp, err := llmProvider.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from OpenAI")
+	}
+
+	for _, call := range resp.Choices[0].Message.ToolCalls {
+		if call.Function.Name != queryTableFunction {
+			continue
+		}
+
+		var query models.TableQuery
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &query); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidTableQuery, err)
+		}
+		if err := validateTableQuery(&query); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidTableQuery, err)
+		}
+		cached.Query = &query
+		cacheSet(cacheSmartChat, key, cached)
+		return &query, nil
+	}
+
+	cacheSet(cacheSmartChat, key, cached)
+	return nil, nil
+}
+
+// validateTableQuery checks a table query against CreditSchema and normalizes its limit
+func validateTableQuery(query *models.TableQuery) error {
+	table, ok := findTable(query.Table)
+	if !ok {
+		return fmt.Errorf("unknown table %q", query.Table)
+	}
+
+	columns := make(map[string]bool, len(table.Columns))
+	for _, column := range table.Columns {
+		columns[column] = true
+	}
+	checkColumn := func(column string) error {
+		if !columns[column] {
+			return fmt.Errorf("unknown column %q in table %s", column, table.Name)
+		}
+		return nil
+	}
+
+	for _, column := range query.Columns {
+		if err := checkColumn(column); err != nil {
+			return err
+		}
+	}
+
+	for _, filter := range query.Filters {
+		if err := checkColumn(filter.Column); err != nil {
+			return err
+		}
+		if _, ok := filterOperators[filter.Operator]; !ok {
+			return fmt.Errorf("unsupported filter operator %q", filter.Operator)
+		}
+		switch filter.Operator {
+		case "in":
+			items, ok := filter.Value.([]interface{})
+			if !ok || len(items) == 0 {
+				return fmt.Errorf("filter on %s with operator in requires a list", filter.Column)
+			}
+			for _, item := range items {
+				if !isScalar(item) {
+					return fmt.Errorf("filter on %s with operator in requires a list of values", filter.Column)
+				}
+			}
+		case "is":
+			if filter.Value != nil {
+				if _, ok := filter.Value.(bool); !ok {
+					return fmt.Errorf("filter on %s with operator is requires null, true or false", filter.Column)
+				}
+			}
+		default:
+			if !isScalar(filter.Value) {
+				return fmt.Errorf("filter on %s requires a single value", filter.Column)
+			}
+		}
+	}
+
+	for _, order := range query.Order {
+		if err := checkColumn(order.Column); err != nil {
+			return err
+		}
+		if order.Direction != "" && order.Direction != "asc" && order.Direction != "desc" {
+			return fmt.Errorf("invalid order direction %q", order.Direction)
+		}
+	}
+
+	for _, aggregate := range query.Aggregates {
+		if !aggregateFunctions[aggregate.Function] {
+			return fmt.Errorf("unsupported aggregate %q", aggregate.Function)
+		}
+		if aggregate.Column == "" {
+			if aggregate.Function != "count" {
+				return fmt.Errorf("aggregate %s requires a column", aggregate.Function)
+			}
+			continue
+		}
+		if err := checkColumn(aggregate.Column); err != nil {
+			return err
+		}
+	}
+
+	if query.Limit <= 0 || query.Limit > maxQueryLimit {
+		query.Limit = maxQueryLimit
+	}
+	return nil
+}
+
+// isScalar reports whether a decoded JSON value is a string, number or boolean
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, float64, bool:
+		return true
+	}
+	return false
+}
+
+// compileTableQuery translates a validated table query into PostgREST query parameters
+func compileTableQuery(query models.TableQuery) map[string]string {
+	params := map[string]string{
+		"limit": strconv.Itoa(query.Limit),
+	}
+
+	// Aggregates are selected together with the requested columns, which PostgREST groups by
+	selected := append([]string(nil), query.Columns...)
+	for _, aggregate := range query.Aggregates {
+		if aggregate.Column == "" {
+			selected = append(selected, "count()")
+			continue
+		}
+		selected = append(selected, fmt.Sprintf("%s_%s:%s.%s()", aggregate.Function, aggregate.Column, aggregate.Column, aggregate.Function))
+	}
+	if len(selected) > 0 {
+		params["select"] = url.QueryEscape(strings.Join(selected, ","))
+	}
+
+	// Filters are combined with and=(...) so the same column may be filtered more than once
+	if len(query.Filters) > 0 {
+		conditions := make([]string, 0, len(query.Filters))
+		for _, filter := range query.Filters {
+			conditions = append(conditions, fmt.Sprintf("%s.%s.%s", filter.Column, filterOperators[filter.Operator], filterValue(filter)))
+		}
+		params["and"] = url.QueryEscape("(" + strings.Join(conditions, ",") + ")")
+	}
+
+	if len(query.Order) > 0 {
+		orders := make([]string, 0, len(query.Order))
+		for _, order := range query.Order {
+			direction := order.Direction
+			if direction == "" {
+				direction = "asc"
+			}
+			orders = append(orders, order.Column+"."+direction)
+		}
+		params["order"] = url.QueryEscape(strings.Join(orders, ","))
+	}
+
+	return params
+}
+
+// filterValue formats a filter value for a PostgREST logical expression, quoting it so
+// reserved characters cannot change the expression
+func filterValue(filter models.QueryFilter) string {
+	switch filter.Operator {
+	case "is":
+		if filter.Value == nil {
+			return "null"
+		}
+		return strconv.FormatBool(filter.Value.(bool))
+	case "in":
+		items := filter.Value.([]interface{})
+		quoted := make([]string, 0, len(items))
+		for _, item := range items {
+			quoted = append(quoted, quoteValue(item))
+		}
+		return "(" + strings.Join(quoted, ",") + ")"
+	}
+	return quoteValue(filter.Value)
+}
+
+// quoteValue double-quotes a value, escaping backslashes and quotes
+func quoteValue(value interface{}) string {
+	text := fmt.Sprint(value)
+	if number, ok := value.(float64); ok {
+		text = strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, `"`, `\"`)
+	return `"` + text + `"`
+}
+
+// QueryTable validates a table query against CreditSchema and runs it, returning at most
+// maxQueryLimit rows with their personal data masked unless the caller's role sees it
+func QueryTable(ctx context.Context, query models.TableQuery) ([]map[string]interface{}, error) {
+	if err := validateTableQuery(&query); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidTableQuery, err)
+	}
+	rows, err := executeTableQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	redactRows(ctx, query.Table, rows)
+	return rows, nil
+}
+
+// executeTableQuery runs a validated table query against Supabase
+func executeTableQuery(ctx context.Context, query models.TableQuery) ([]map[string]interface{}, error) {
+	responseBody, err := makeSupabaseRequest(ctx, "GET", query.Table, nil, compileTableQuery(query))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// postgrestRequests records the query of every request made to a fake PostgREST, which answers
+// with no rows
+type postgrestRequests struct {
+	mu      sync.Mutex
+	queries []url.Values
+}
+
+func (r *postgrestRequests) last() url.Values {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.queries[len(r.queries)-1]
+}
+
+func (r *postgrestRequests) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.queries)
+}
+
+func recordPostgREST(t *testing.T) *postgrestRequests {
+	t.Helper()
+	requests := &postgrestRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.mu.Lock()
+		requests.queries = append(requests.queries, r.URL.Query())
+		requests.mu.Unlock()
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("SUPABASE_URL", server.URL)
+	t.Setenv("SUPABASE_API_KEY", "test")
+	supabaseHTTPClient = server.Client()
+	return requests
+}
+
+func TestQueryTableCompilesToPostgREST(t *testing.T) {
+	useFakeLLM(t, "")
+	requests := recordPostgREST(t)
+
+	_, err := QueryTable(context.Background(), models.TableQuery{
+		Table:   "clientes",
+		Columns: []string{"classe_risco"},
+		Filters: []models.QueryFilter{
+			// The same column twice, a large number and a list with a reserved character
+			{Column: "score_credito", Operator: "gte", Value: 600.0},
+			{Column: "score_credito", Operator: "lt", Value: 800.0},
+			{Column: "renda_mensal", Operator: "gt", Value: 15000000.0},
+			{Column: "tipo_pessoa", Operator: "in", Value: []interface{}{"PF", "P,J"}},
+			{Column: "classe_risco", Operator: "is", Value: nil},
+		},
+		Order:      []models.QueryOrder{{Column: "classe_risco", Direction: "desc"}, {Column: "nome"}},
+		Aggregates: []models.QueryAggregate{{Function: "count"}, {Function: "avg", Column: "renda_mensal"}},
+		Limit:      10,
+	})
+	if err != nil {
+		t.Fatalf("QueryTable: %v", err)
+	}
+
+	query := requests.last()
+	want := map[string]string{
+		"select": "classe_risco,count(),avg_renda_mensal:renda_mensal.avg()",
+		"and":    `(score_credito.gte."600",score_credito.lt."800",renda_mensal.gt."15000000",tipo_pessoa.in.("PF","P,J"),classe_risco.is.null)`,
+		"order":  "classe_risco.desc,nome.asc",
+		"limit":  "10",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestFilterValuesCannotChangeTheExpression(t *testing.T) {
+	useFakeLLM(t, "")
+	requests := recordPostgREST(t)
+
+	_, err := QueryTable(context.Background(), models.TableQuery{Table: "clientes", Filters: []models.QueryFilter{
+		{Column: "nome", Operator: "eq", Value: `a"),or(id.gt.0\`},
+		{Column: "nome", Operator: "ilike", Value: "*Silva, João (ME)*"},
+	}})
+	if err != nil {
+		t.Fatalf("QueryTable: %v", err)
+	}
+	if got, want := requests.last().Get("and"), `(nome.eq."a\"),or(id.gt.0\\",nome.ilike."*Silva, João (ME)*")`; got != want {
+		t.Errorf("and = %q, want %q", got, want)
+	}
+}
+
+func TestQueryTableCapsTheLimit(t *testing.T) {
+	useFakeLLM(t, "")
+	requests := recordPostgREST(t)
+
+	for _, limit := range []int{0, 1000} {
+		if _, err := QueryTable(context.Background(), models.TableQuery{Table: "clientes", Limit: limit}); err != nil {
+			t.Fatalf("QueryTable: %v", err)
+		}
+		if got := requests.last().Get("limit"); got != "50" {
+			t.Errorf("limit %d sent as %s, want 50", limit, got)
+		}
+	}
+}
+
+func TestInvalidTableQueriesNeverReachTheDatabase(t *testing.T) {
+	useFakeLLM(t, "")
+	requests := recordPostgREST(t)
+	filter := func(column, operator string, value interface{}) models.TableQuery {
+		return models.TableQuery{Table: "clientes", Filters: []models.QueryFilter{{Column: column, Operator: operator, Value: value}}}
+	}
+
+	invalid := []models.TableQuery{
+		{Table: "usuarios"},
+		{Table: "clientes", Columns: []string{"senha"}},
+		// decisao is a column of another table
+		{Table: "clientes", Columns: []string{"decisao"}},
+		{Table: "clientes", Order: []models.QueryOrder{{Column: "nome", Direction: "asc;drop"}}},
+		{Table: "clientes", Aggregates: []models.QueryAggregate{{Function: "median", Column: "renda_mensal"}}},
+		{Table: "clientes", Aggregates: []models.QueryAggregate{{Function: "sum"}}},
+		filter("senha", "eq", "x"),
+		filter("nome", "fts", "x"),
+		filter("tipo_pessoa", "in", "PF"),
+		filter("tipo_pessoa", "in", []interface{}{}),
+		filter("tipo_pessoa", "in", []interface{}{[]interface{}{"PF"}}),
+		filter("classe_risco", "is", "null"),
+		filter("nome", "eq", []interface{}{"a"}),
+		filter("nome", "eq", nil),
+	}
+	for _, query := range invalid {
+		if _, err := QueryTable(context.Background(), query); !errors.Is(err, errInvalidTableQuery) {
+			t.Errorf("QueryTable(%+v): error %v, want %v", query, err, errInvalidTableQuery)
+		}
+	}
+	if n := requests.count(); n > 0 {
+		t.Errorf("%d invalid queries reached the database", n)
+	}
+}
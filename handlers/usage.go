@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/auth"
+	"credibot-api/experiments"
+	"credibot-api/jobs"
+	"credibot-api/models"
+	"credibot-api/usage"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CallerHeader identifies the team or service making a request, for usage chargeback
+const CallerHeader = "X-Caller-ID"
+
+// Ranges reported by GET /usage when from is not given
+const (
+	defaultUsageDays   = 30
+	defaultUsageMonths = 12
+)
+
+// callerID returns the caller of a request: the one its API key authenticates, or else
+// the unverified X-Caller-ID header
+func callerID(c *fiber.Ctx) string {
+	if identity, ok := requestIdentity(c); ok {
+		return identity.Caller
+	}
+	return UnverifiedCaller(c.Get(CallerHeader))
+}
+
+// withCaller attributes the model calls of a request to its caller and route, identifies
+// its user for experiment assignment and carries the identity its API key authenticates
+func withCaller(ctx context.Context, c *fiber.Ctx) context.Context {
+	if identity, ok := requestIdentity(c); ok {
+		ctx = auth.WithIdentity(ctx, identity)
+	}
+	ctx = experiments.WithUser(ctx, experimentUser(c))
+	return usage.WithCaller(ctx, callerID(c), c.Route().Path)
+}
+
+// enterStage reports a pipeline stage to job progress and attributes its model calls
+func enterStage(ctx context.Context, stage string) context.Context {
+	jobs.ReportStage(ctx, stage)
+	return usage.WithStage(ctx, stage)
+}
+
+// toUsage converts the usage summed over a request to the response model
+func toUsage(tally *usage.Tally) *models.Usage {
+	u := tally.Usage()
+	return &models.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+// GetUsage aggregates token usage and estimated cost per day or month. Admins see every
+// caller and may filter by one; callers authenticated by an API key only see their own usage.
+func GetUsage(c *fiber.Ctx) error {
+	period := c.Query("period", usage.PeriodDay)
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	if value := c.Query("to"); value != "" {
+		day, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return usageError(c, "Invalid to date: use YYYY-MM-DD")
+		}
+		// The to day is included
+		to = day.AddDate(0, 0, 1)
+	}
+	from := to.AddDate(0, 0, -defaultUsageDays)
+	if period == usage.PeriodMonth {
+		from = time.Date(to.Year(), to.Month()-defaultUsageMonths+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if value := c.Query("from"); value != "" {
+		day, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return usageError(c, "Invalid from date: use YYYY-MM-DD")
+		}
+		from = day
+	}
+	if !from.Before(to) {
+		return usageError(c, "from must not be after to")
+	}
+
+	groupBy := []string{"caller", "endpoint", "stage"}
+	if value, ok := c.Queries()["group_by"]; ok {
+		groupBy = nil
+		for _, dimension := range strings.Split(value, ",") {
+			if dimension = strings.TrimSpace(dimension); dimension != "" {
+				groupBy = append(groupBy, dimension)
+			}
+		}
+	}
+
+	caller := c.Query("caller")
+	if !isAdmin(c) {
+		identity, ok := requestIdentity(c)
+		if !ok {
+			return RequireIdentity(c)
+		}
+		caller = identity.Caller
+	}
+
+	records, err := usageStore.List(from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   true,
+			Message: "Failed to read usage: " + err.Error(),
+			Code:    fiber.StatusInternalServerError,
+		})
+	}
+	if caller != "" {
+		filtered := records[:0]
+		for _, record := range records {
+			if record.Caller == caller {
+				filtered = append(filtered, record)
+			}
+		}
+		records = filtered
+	}
+
+	rows, total, err := usage.Summarize(records, period, groupBy)
+	if err != nil {
+		return usageError(c, err.Error())
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data: fiber.Map{
+			"from":     from.Format("2006-01-02"),
+			"to":       to.AddDate(0, 0, -1).Format("2006-01-02"),
+			"period":   period,
+			"group_by": groupBy,
+			"total":    total,
+			"rows":     rows,
+		},
+		Message: "Usage retrieved successfully",
+	})
+}
+
+func usageError(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   true,
+		Message: message,
+		Code:    fiber.StatusBadRequest,
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"credibot-api/auth"
+	"credibot-api/models"
+	"credibot-api/usage"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUsageIsChargedToTheCallerOfEachStage(t *testing.T) {
+	useFakeLLM(t, "")
+	usageStore = usage.NewMemoryStore()
+	llmProvider = usage.NewMeter(usageStore, usage.NewPricing(nil)).Wrap(llmProvider)
+	apiKeys = auth.NewKeys([]models.APIKey{
+		{Key: "k-painel", Caller: "painel", Role: "viewer"},
+		{Key: "k-auditoria", Caller: "auditoria", Role: "auditor"},
+	})
+
+	app := fiber.New()
+	app.Use(Authenticate)
+	app.Post("/smart-chat", SmartChat)
+	app.Post("/chat", Chat)
+	app.Get("/usage", GetUsage)
+	send := func(method, path, key, body string) (int, []byte) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(APIKeyHeader, key)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, data
+	}
+
+	// The smart chat response sums the usage of its stages
+	status, body := send("POST", "/smart-chat", "k-painel", `{"message": "O que é score de crédito?"}`)
+	var smart struct{ Data models.SmartChatResponse }
+	if err := json.Unmarshal(body, &smart); err != nil || status != fiber.StatusOK {
+		t.Fatalf("smart chat: status %d, %v", status, err)
+	}
+	if status, _ := send("POST", "/chat", "k-auditoria", `{"message": "O que é score de crédito?"}`); status != fiber.StatusOK {
+		t.Fatalf("chat: status %d", status)
+	}
+
+	status, body = send("GET", "/usage?group_by=caller,endpoint,stage", "k-painel", "")
+	var report struct {
+		Data struct {
+			Total usage.Summary
+			Rows  []usage.Summary
+		}
+	}
+	if err := json.Unmarshal(body, &report); err != nil || status != fiber.StatusOK {
+		t.Fatalf("usage: status %d, %v", status, err)
+	}
+
+	// Only the caller's own usage, one row per stage
+	var rows []string
+	for _, row := range report.Data.Rows {
+		rows = append(rows, row.Caller+" "+row.Endpoint+" "+row.Stage)
+	}
+	if want := []string{"painel /smart-chat analysis", "painel /smart-chat response"}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+	if report.Data.Total.TotalTokens != smart.Data.Usage.TotalTokens || report.Data.Total.TotalTokens == 0 {
+		t.Errorf("reported %d tokens, the response %d", report.Data.Total.TotalTokens, smart.Data.Usage.TotalTokens)
+	}
+}
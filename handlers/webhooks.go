@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/config"
+	"credibot-api/jobs"
+	"credibot-api/models"
+	"credibot-api/webhooks"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// eventJobFinished is sent when a job reaches a final status
+const eventJobFinished = "job.finished"
+
+// callbackLookupTimeout bounds the resolution of a callback host when a job is submitted
+const callbackLookupTimeout = 5 * time.Second
+
+var webhookDispatcher *webhooks.Dispatcher
+
+// startWebhooks creates the dispatcher and posts job results to their callback URLs
+func startWebhooks(manager *jobs.Manager) {
+	cfg := config.AppConfig.Webhooks
+	// Deliveries are kept as long as the jobs they report on
+	webhookDispatcher = webhooks.NewDispatcher(webhookHTTPClient, webhookAddresses, cfg.Secret, cfg.MaxAttempts, cfg.InitialBackoff,
+		config.AppConfig.Jobs.MaxFinished, config.AppConfig.Jobs.Retention)
+
+	manager.OnFinish(func(job jobs.Job) {
+		if job.Request.CallbackURL == "" {
+			return
+		}
+		if _, err := webhookDispatcher.Send(job.ID, eventJobFinished, job.Request.CallbackURL, job); err != nil {
+			log.Printf("Failed to send webhook for job %s: %v", job.ID, err)
+		}
+	})
+}
+
+// validateCallbackURL checks that callbacks can be signed and delivered, and that the callback
+// host does not resolve to an internal address of the server's network
+func validateCallbackURL(callbackURL string) error {
+	if callbackURL == "" {
+		return nil
+	}
+	if config.AppConfig.Webhooks.Secret == "" {
+		return fmt.Errorf("Callbacks are disabled: WEBHOOK_SECRET not configured")
+	}
+
+	parsed, err := url.Parse(callbackURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("callback_url must be an absolute http or https URL")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackLookupTimeout)
+	defer cancel()
+	if err := webhookAddresses.CheckURL(ctx, callbackURL); err != nil {
+		if errors.Is(err, webhooks.ErrForbiddenAddress) {
+			return fmt.Errorf("callback_url must not point to a loopback, link-local or private address")
+		}
+		return fmt.Errorf("callback_url host could not be resolved: %v", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries lists the recorded callback deliveries, optionally filtered by job_id
+func ListWebhookDeliveries(c *fiber.Ctx) error {
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    webhookDispatcher.List(c.Query("job_id")),
+		Message: "Deliveries retrieved successfully",
+	})
+}
+
+// GetWebhookDelivery returns a delivery with its attempts
+func GetWebhookDelivery(c *fiber.Ctx) error {
+	delivery, err := webhookDispatcher.Get(c.Params("id"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.JSON(models.SuccessResponse{
+		Success: true,
+		Data:    delivery,
+		Message: "Delivery retrieved successfully",
+	})
+}
+
+// ReplayWebhookDelivery delivers a recorded callback again
+func ReplayWebhookDelivery(c *fiber.Ctx) error {
+	delivery, err := webhookDispatcher.Replay(c.Params("id"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(models.SuccessResponse{
+		Success: true,
+		Data:    delivery,
+		Message: "Delivery replay started",
+	})
+}
+
+// webhookError maps dispatcher errors to HTTP responses
+func webhookError(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	if errors.Is(err, webhooks.ErrNotFound) {
+		code = fiber.StatusNotFound
+	}
+
+	return c.Status(code).JSON(models.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+		Code:    code,
+	})
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/auth"
+	"credibot-api/config"
+	"credibot-api/models"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// WebSocket message types
+const (
+	wsTypeChat      = "chat"
+	wsTypeSmartChat = "smart_chat"
+	wsTypeCancel    = "cancel"
+	wsTypeDelta     = "delta"
+	wsTypeDone      = "done"
+	wsTypeError     = "error"
+	wsTypeCancelled = "cancelled"
+)
+
+// localUsageContext carries the usage attribution from the upgrade request to the socket
+const localUsageContext = "usage_context"
+
+// wsWriter is the sending side of a WebSocket connection
+type wsWriter interface {
+	WriteJSON(v interface{}) error
+}
+
+// wsSession tracks the in-flight requests of a single WebSocket connection
+type wsSession struct {
+	conn     wsWriter
+	base     context.Context // attributes model calls to the caller that opened the connection
+	writeMu  sync.Mutex
+	mu       sync.Mutex
+	inFlight map[string]context.CancelFunc
+	// slots holds a token per running request, bounding them by WS_MAX_IN_FLIGHT; nil when unbounded
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// WebSocketUpgrade only lets WebSocket upgrade requests reach the socket handler
+func WebSocketUpgrade(c *fiber.Ctx) error {
+	if websocket.IsWebSocketUpgrade(c) {
+		c.Locals(localUsageContext, withCaller(context.Background(), c))
+		return c.Next()
+	}
+	return fiber.ErrUpgradeRequired
+}
+
+// ChatSocket serves chat and smart chat over a long-lived WebSocket connection.
+// Requests are multiplexed by conversation id and can be cancelled while in flight.
+var ChatSocket = websocket.New(func(conn *websocket.Conn) {
+	base, _ := conn.Locals(localUsageContext).(context.Context)
+	if base == nil {
+		base = context.Background()
+	}
+	session := newWSSession(conn, base)
+	defer session.close()
+
+	for {
+		var req models.WSRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("websocket read error: %v", err)
+			}
+			return
+		}
+		session.handle(req)
+	}
+})
+
+// newWSSession creates the session of a connection whose model calls are attributed with base
+func newWSSession(conn wsWriter, base context.Context) *wsSession {
+	// Every message names a conversation, so anonymous connections get conversations of their own
+	if _, ok := auth.FromContext(base); !ok {
+		base = withConversationNamespace(base, "ws:"+uuid.NewString())
+	}
+	session := &wsSession{
+		conn:     conn,
+		base:     base,
+		inFlight: make(map[string]context.CancelFunc),
+	}
+	if limit := config.AppConfig.WebSocket.MaxInFlight; limit > 0 {
+		session.slots = make(chan struct{}, limit)
+	}
+	return session
+}
+
+// handle dispatches a client message
+func (s *wsSession) handle(req models.WSRequest) {
+	if req.ConversationID == "" {
+		s.send(models.WSEvent{Type: wsTypeError, Message: "conversation_id is required"})
+		return
+	}
+
+	switch req.Type {
+	case wsTypeCancel:
+		s.mu.Lock()
+		cancel, ok := s.inFlight[req.ConversationID]
+		s.mu.Unlock()
+		if !ok {
+			s.send(models.WSEvent{Type: wsTypeError, ConversationID: req.ConversationID, Message: "No request in flight for this conversation"})
+			return
+		}
+		cancel()
+	case wsTypeChat, wsTypeSmartChat:
+		if req.Message == "" && (req.Type != wsTypeChat || len(req.Messages) == 0) {
+			s.send(models.WSEvent{Type: wsTypeError, ConversationID: req.ConversationID, Message: "Message is required"})
+			return
+		}
+
+		ctx, cancel := withBudget(s.base, config.AppConfig.RequestTimeout)
+		s.mu.Lock()
+		if _, busy := s.inFlight[req.ConversationID]; busy {
+			s.mu.Unlock()
+			cancel()
+			s.send(models.WSEvent{Type: wsTypeError, ConversationID: req.ConversationID, Message: "A request is already in flight for this conversation"})
+			return
+		}
+		if !s.acquire() {
+			s.mu.Unlock()
+			cancel()
+			s.send(models.WSEvent{Type: wsTypeError, ConversationID: req.ConversationID,
+				Message: fmt.Sprintf("Too many requests in flight on this connection (at most %d)", cap(s.slots))})
+			return
+		}
+		s.inFlight[req.ConversationID] = cancel
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.run(ctx, req)
+	default:
+		s.send(models.WSEvent{Type: wsTypeError, ConversationID: req.ConversationID, Message: "Unknown message type: " + req.Type})
+	}
+}
+
+// acquire takes a slot for a new request, reporting false when the connection has no free slot
+func (s *wsSession) acquire() bool {
+	if s.slots == nil {
+		return true
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees the slot of a finished request
+func (s *wsSession) release() {
+	if s.slots != nil {
+		<-s.slots
+	}
+}
+
+// run executes a chat or smart chat request, streaming partial answers back to the client
+func (s *wsSession) run(ctx context.Context, req models.WSRequest) {
+	defer s.wg.Done()
+
+	onDelta := func(delta string) {
+		s.send(models.WSEvent{Type: wsTypeDelta, ConversationID: req.ConversationID, Delta: delta})
+	}
+
+	var data interface{}
+	var err error
+	if req.Type == wsTypeChat {
+		data, err = RunChat(ctx, req.ChatRequest, onDelta)
+	} else {
+		data, err = RunSmartChat(ctx, req.ChatRequest, onDelta)
+	}
+
+	event := models.WSEvent{Type: wsTypeDone, ConversationID: req.ConversationID, Data: data}
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		event = models.WSEvent{Type: wsTypeCancelled, ConversationID: req.ConversationID}
+	case IsTimeout(err):
+		event = models.WSEvent{Type: wsTypeError, ConversationID: req.ConversationID, Message: timeoutMessage(err)}
+	case err != nil:
+		event = models.WSEvent{Type: wsTypeError, ConversationID: req.ConversationID, Message: err.Error()}
+	}
+
+	// A client that sends its next request as soon as it sees the final event must find the
+	// conversation and the slot free
+	s.finish(req.ConversationID)
+	s.send(event)
+}
+
+// finish removes a request from the in-flight ones and frees its slot
+func (s *wsSession) finish(conversationID string) {
+	s.mu.Lock()
+	if cancel, ok := s.inFlight[conversationID]; ok {
+		cancel()
+		delete(s.inFlight, conversationID)
+	}
+	s.mu.Unlock()
+	s.release()
+}
+
+// send writes an event to the connection; writes are serialized across goroutines
+func (s *wsSession) send(event models.WSEvent) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.conn.WriteJSON(event); err != nil {
+		log.Printf("websocket write error: %v", err)
+	}
+}
+
+// close cancels every in-flight request and waits for them to finish
+func (s *wsSession) close() {
+	s.mu.Lock()
+	for _, cancel := range s.inFlight {
+		cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
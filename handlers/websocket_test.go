@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"credibot-api/config"
+	"credibot-api/models"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// recordingSocket keeps the events sent on a session, along with whether the session still held
+// the conversation or a slot when each was sent
+type recordingSocket struct {
+	session *wsSession
+	mu      sync.Mutex
+	events  []models.WSEvent
+	held    []bool
+}
+
+func (r *recordingSocket) WriteJSON(v interface{}) error {
+	event := v.(models.WSEvent)
+	r.session.mu.Lock()
+	held := len(r.session.inFlight) > 0 || len(r.session.slots) > 0
+	r.session.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	r.held = append(r.held, held)
+	return nil
+}
+
+// newRecordedSession returns an anonymous session allowing one request in flight, and its recorded events
+func newRecordedSession() (*wsSession, *recordingSocket) {
+	config.AppConfig.WebSocket.MaxInFlight = 1
+	socket := &recordingSocket{}
+	socket.session = newWSSession(socket, context.Background())
+	return socket.session, socket
+}
+
+func chatMessage(conversationID, question string) models.WSRequest {
+	return models.WSRequest{Type: wsTypeChat, ChatRequest: models.ChatRequest{ConversationID: conversationID, Message: question}}
+}
+
+func TestSocketFreesTheRequestBeforeTheFinalEvent(t *testing.T) {
+	useFakeLLM(t, "")
+	session, socket := newRecordedSession()
+
+	session.handle(chatMessage("c1", "O que é análise de crédito?"))
+	session.wg.Wait()
+
+	last := len(socket.events) - 1
+	if last < 0 || socket.events[last].Type != wsTypeDone {
+		t.Fatalf("events = %+v, want a final done event", socket.events)
+	}
+	if socket.held[last] {
+		t.Error("the conversation or slot was still taken when done was sent")
+	}
+}
+
+func TestSocketReportsCancellation(t *testing.T) {
+	useFakeLLM(t, "")
+	llmProvider = hangingProvider{llmProvider}
+	session, socket := newRecordedSession()
+
+	session.handle(chatMessage("c1", "O que é análise de crédito?"))
+	session.handle(models.WSRequest{Type: wsTypeCancel, ChatRequest: models.ChatRequest{ConversationID: "c1"}})
+	session.wg.Wait()
+
+	data, _ := json.Marshal(socket.events)
+	if len(socket.events) != 1 || socket.events[0].Type != wsTypeCancelled || socket.held[0] {
+		t.Fatalf("events = %s, want a single cancelled event once the request was freed", data)
+	}
+}
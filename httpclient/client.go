@@ -0,0 +1,109 @@
+package httpclient
+
+import (
+	"context"
+	"credibot-api/models"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Option customizes the transport of a client
+type Option func(transport *http.Transport, dialer *net.Dialer)
+
+// WithResolver makes the client resolve hosts with resolve, which may refuse them, and connect
+// only to the addresses it returns
+func WithResolver(resolve func(ctx context.Context, host string) ([]net.IP, error)) Option {
+	return func(transport *http.Transport, dialer *net.Dialer) {
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, err
+			}
+			ips, err := resolve(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+
+			err = fmt.Errorf("no addresses found for %s", host)
+			for _, ip := range ips {
+				var conn net.Conn
+				if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+					return conn, nil
+				}
+			}
+			return nil, err
+		}
+	}
+}
+
+// New builds a pooled HTTP client for an upstream. Clients are meant to be
+// created once at startup and shared, so connections are kept alive and reused.
+// Transient failures are retried according to the upstream retry policy.
+func New(name string, cfg models.HTTPClientConfig, opts ...Option) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file for %s: %w", name, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file for %s", name)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL for %s: %w", name, err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.DialTimeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConnsPerHost * 2,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	for _, opt := range opts {
+		opt(transport, dialer)
+	}
+
+	// With retries the timeout applies to each attempt and the deadline to the whole request
+	timeout := cfg.Timeout
+	if cfg.Retry.MaxAttempts > 1 && cfg.Retry.Deadline > timeout {
+		timeout = cfg.Retry.Deadline
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &retryTransport{
+			name:    name,
+			policy:  cfg.Retry,
+			timeout: cfg.Timeout,
+			next:    &measuredTransport{name: name, next: transport},
+		},
+	}, nil
+}
@@ -0,0 +1,191 @@
+package httpclient
+
+import (
+	"context"
+	"credibot-api/models"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// retryTransport retries transient failures with exponential backoff and jitter,
+// honoring Retry-After and never exceeding the request deadline
+type retryTransport struct {
+	name    string
+	policy  models.RetryConfig
+	timeout time.Duration
+	next    http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.policy.MaxAttempts <= 1 || !t.replayable(req) {
+		return t.attempt(req)
+	}
+
+	deadline := time.Now().Add(t.policy.Deadline)
+	backoff := t.policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			// Each retry needs a fresh copy of the body
+			retry, err := rewind(req)
+			if err != nil {
+				return nil, err
+			}
+			req = retry
+		}
+
+		resp, err := t.attempt(req)
+		if attempt == t.policy.MaxAttempts || !retryable(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		wait := jitter(backoff)
+		if after, ok := retryAfter(resp); ok {
+			wait = after
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		recordRetry(t.name)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > t.policy.MaxBackoff {
+			backoff = t.policy.MaxBackoff
+		}
+	}
+}
+
+// attempt sends the request once, bounded by the per-attempt timeout
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout keeps applying while the caller reads the body
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// replayable reports whether the request may be sent more than once
+func (t *retryTransport) replayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return t.policy.NonIdempotent || req.Header.Get("Idempotency-Key") != ""
+}
+
+// rewind returns a copy of the request with a fresh body
+func rewind(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+// retryable reports whether a result is a transient failure worth retrying
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return transientError(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// transientError reports whether a transport error may go away on its own: per-attempt and
+// network timeouts, refused or reset connections and responses cut short. Failures that would
+// repeat, such as unknown hosts, invalid URLs and TLS certificate errors, are not retried.
+func transientError(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return true
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header, given in seconds or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// jitter spreads retries over [backoff/2, backoff) so clients do not retry in lockstep
+func jitter(backoff time.Duration) time.Duration {
+	half := backoff / 2
+	if half <= 0 {
+		return backoff
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// cancelOnClose releases the attempt context once the body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,196 @@
+package httpclient
+
+import (
+	"context"
+	"credibot-api/models"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries retries up to 3 times without noticeable waits
+var fastRetries = models.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Deadline: 5 * time.Second}
+
+// flakyUpstream answers with the given statuses in turn, repeating the last one, and counts the
+// attempts. Every POST must carry the body {"q":1}, retries included.
+func flakyUpstream(t *testing.T, header http.Header, statuses ...int) (string, *int32) {
+	t.Helper()
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&attempts, 1))
+		if body, _ := io.ReadAll(r.Body); r.Method == http.MethodPost && string(body) != `{"q":1}` {
+			t.Errorf("attempt %d: body %q", n, body)
+		}
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, &attempts
+}
+
+// send makes a request through a client with the given retry policy, returning its status
+func send(t *testing.T, policy models.RetryConfig, req *http.Request) int {
+	t.Helper()
+	client, err := New("test", models.HTTPClientConfig{Timeout: time.Second, DialTimeout: time.Second, Retry: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func get(url string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	return req
+}
+
+func post(url string) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"q":1}`))
+	return req
+}
+
+func TestTransientFailuresAreRetried(t *testing.T) {
+	url, attempts := flakyUpstream(t, nil, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+	if status := send(t, fastRetries, get(url)); status != http.StatusOK || *attempts != 3 {
+		t.Errorf("status %d after %d attempts, want 200 after 3", status, *attempts)
+	}
+}
+
+func TestRetriesStopAtTheLastAttempt(t *testing.T) {
+	url, attempts := flakyUpstream(t, nil, http.StatusServiceUnavailable)
+	if status := send(t, fastRetries, get(url)); status != http.StatusServiceUnavailable || *attempts != 3 {
+		t.Errorf("status %d after %d attempts, want 503 after 3", status, *attempts)
+	}
+
+	url, attempts = flakyUpstream(t, nil, http.StatusServiceUnavailable, http.StatusOK)
+	if status := send(t, models.RetryConfig{MaxAttempts: 1}, get(url)); status != http.StatusServiceUnavailable || *attempts != 1 {
+		t.Errorf("retries disabled: status %d after %d attempts, want 503 after 1", status, *attempts)
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	for _, code := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusNotImplemented} {
+		url, attempts := flakyUpstream(t, nil, code, http.StatusOK)
+		if status := send(t, fastRetries, get(url)); status != code || *attempts != 1 {
+			t.Errorf("%d: status %d after %d attempts, want no retry", code, status, *attempts)
+		}
+	}
+}
+
+func TestRetryAfterReplacesTheBackoff(t *testing.T) {
+	slow := models.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Minute, Deadline: 2 * time.Minute}
+
+	// Retry-After: 0 retries at once despite a one minute backoff
+	url, attempts := flakyUpstream(t, http.Header{"Retry-After": {"0"}}, http.StatusTooManyRequests, http.StatusOK)
+	if status := send(t, slow, get(url)); status != http.StatusOK || *attempts != 2 {
+		t.Errorf("status %d after %d attempts, want 200 after 2", status, *attempts)
+	}
+
+	// A wait past the deadline returns the last response instead of waiting
+	url, attempts = flakyUpstream(t, http.Header{"Retry-After": {"60"}}, http.StatusTooManyRequests, http.StatusOK)
+	start := time.Now()
+	if status := send(t, fastRetries, get(url)); status != http.StatusTooManyRequests || *attempts != 1 {
+		t.Errorf("status %d after %d attempts, want 429 after 1", status, *attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+}
+
+func TestPostIsOnlyRetriedWhenIdempotent(t *testing.T) {
+	url, attempts := flakyUpstream(t, nil, http.StatusServiceUnavailable, http.StatusOK)
+	if status := send(t, fastRetries, post(url)); status != http.StatusServiceUnavailable || *attempts != 1 {
+		t.Errorf("POST: status %d after %d attempts, want no retry", status, *attempts)
+	}
+
+	url, attempts = flakyUpstream(t, nil, http.StatusServiceUnavailable, http.StatusOK)
+	req := post(url)
+	req.Header.Set("Idempotency-Key", "k1")
+	if status := send(t, fastRetries, req); status != http.StatusOK || *attempts != 2 {
+		t.Errorf("POST with an Idempotency-Key: status %d after %d attempts, want 200 after 2", status, *attempts)
+	}
+
+	url, attempts = flakyUpstream(t, nil, http.StatusServiceUnavailable, http.StatusOK)
+	nonIdempotent := fastRetries
+	nonIdempotent.NonIdempotent = true
+	if status := send(t, nonIdempotent, post(url)); status != http.StatusOK || *attempts != 2 {
+		t.Errorf("POST with non-idempotent retries: status %d after %d attempts, want 200 after 2", status, *attempts)
+	}
+}
+
+// countingTransport counts the requests sent through it, failing them with err when set
+type countingTransport struct {
+	attempts int32
+	err      error
+	next     http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.attempts, 1)
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.next.RoundTrip(req)
+}
+
+func TestOnlyTransientTransportErrorsAreRetried(t *testing.T) {
+	// A closed listener refuses connections, which may not last
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + listener.Addr().String()
+	listener.Close()
+
+	attempts := func(url string, err error) int32 {
+		counter := &countingTransport{err: err, next: http.DefaultTransport}
+		transport := &retryTransport{name: "test", policy: fastRetries, next: counter}
+		if _, err := transport.RoundTrip(get(url)); err == nil {
+			t.Fatalf("%s: RoundTrip succeeded", url)
+		}
+		return counter.attempts
+	}
+
+	if n := attempts(refused, nil); n != 3 {
+		t.Errorf("connection refused: %d attempts, want 3", n)
+	}
+	if n := attempts("http://credibot.invalid", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "timeout", IsTimeout: true}}); n != 3 {
+		t.Errorf("DNS timeout: %d attempts, want 3", n)
+	}
+	// Failures that would repeat
+	if n := attempts("http://credibot.invalid", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}); n != 1 {
+		t.Errorf("unknown host: %d attempts, want 1", n)
+	}
+	if n := attempts("https://credibot.invalid", x509.UnknownAuthorityError{}); n != 1 {
+		t.Errorf("certificate error: %d attempts, want 1", n)
+	}
+}
+
+func TestCancellationStopsRetries(t *testing.T) {
+	url, attempts := flakyUpstream(t, nil, http.StatusServiceUnavailable)
+	slow := models.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Minute, Deadline: 2 * time.Minute}
+	client, err := New("test", models.HTTPClientConfig{Timeout: time.Second, DialTimeout: time.Second, Retry: slow})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Do(get(url).WithContext(ctx))
+	if !errors.Is(err, context.DeadlineExceeded) || *attempts != 1 {
+		t.Errorf("error %v after %d attempts, want the caller's deadline after 1", err, *attempts)
+	}
+}
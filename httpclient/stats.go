@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWindow is the number of recent requests kept per upstream for percentiles
+const latencyWindow = 1000
+
+// UpstreamStats summarizes the traffic sent to an upstream
+type UpstreamStats struct {
+	Name              string  `json:"name"`
+	Requests          int64   `json:"requests"`
+	Errors            int64   `json:"errors"`
+	Retries           int64   `json:"retries"`
+	ReusedConnections int64   `json:"reused_connections"`
+	NewConnections    int64   `json:"new_connections"`
+	LatencyP50MS      float64 `json:"latency_p50_ms"`
+	LatencyP95MS      float64 `json:"latency_p95_ms"`
+	LatencyP99MS      float64 `json:"latency_p99_ms"`
+}
+
+// upstream accumulates measurements for one upstream
+type upstream struct {
+	requests  int64
+	errors    int64
+	retries   int64
+	reused    int64
+	created   int64
+	latencies []time.Duration
+	next      int
+}
+
+var (
+	statsMu   sync.Mutex
+	upstreams = make(map[string]*upstream)
+)
+
+// measuredTransport records latency and connection reuse of every request
+type measuredTransport struct {
+	name string
+	next http.RoundTripper
+}
+
+func (t *measuredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reused, gotConn bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			gotConn = true
+			reused = info.Reused
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	record(t.name, time.Since(start), err != nil, gotConn, reused)
+	return resp, err
+}
+
+// record stores a request measurement
+func record(name string, latency time.Duration, failed, gotConn, reused bool) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	u := upstreamFor(name)
+	u.requests++
+	if failed {
+		u.errors++
+	}
+	if gotConn {
+		if reused {
+			u.reused++
+		} else {
+			u.created++
+		}
+	}
+
+	if len(u.latencies) < latencyWindow {
+		u.latencies = append(u.latencies, latency)
+	} else {
+		u.latencies[u.next] = latency
+		u.next = (u.next + 1) % latencyWindow
+	}
+}
+
+// recordRetry counts a retried request
+func recordRetry(name string) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	upstreamFor(name).retries++
+}
+
+// upstreamFor returns the measurements of an upstream, creating them on first use; statsMu must be held
+func upstreamFor(name string) *upstream {
+	u, ok := upstreams[name]
+	if !ok {
+		u = &upstream{}
+		upstreams[name] = u
+	}
+	return u
+}
+
+// Stats returns the measurements of every upstream, sorted by name
+func Stats() []UpstreamStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	list := make([]UpstreamStats, 0, len(upstreams))
+	for name, u := range upstreams {
+		sorted := append([]time.Duration(nil), u.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		list = append(list, UpstreamStats{
+			Name:              name,
+			Requests:          u.requests,
+			Errors:            u.errors,
+			Retries:           u.retries,
+			ReusedConnections: u.reused,
+			NewConnections:    u.created,
+			LatencyP50MS:      percentile(sorted, 0.50),
+			LatencyP95MS:      percentile(sorted, 0.95),
+			LatencyP99MS:      percentile(sorted, 0.99),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// percentile returns the p-th percentile of sorted latencies in milliseconds
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(float64(len(sorted)-1) * p)
+	return float64(sorted[index].Microseconds()) / 1000
+}
@@ -0,0 +1,338 @@
+package jobs
+
+import (
+	"context"
+	"credibot-api/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Job represents an asynchronous request and its outcome
+type Job struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// Owner is the caller that submitted the job; only it may read or cancel the job
+	Owner      string            `json:"owner,omitempty"`
+	Stage      string            `json:"stage,omitempty"`
+	Request    models.JobRequest `json:"request"`
+	Result     json.RawMessage   `json:"result,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job reached a final status
+func (j Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+// Executor runs a job request and returns its result
+type Executor func(ctx context.Context, req models.JobRequest) (interface{}, error)
+
+// pruneInterval is how often finished jobs past the retention period are deleted when no
+// job finishes in the meantime
+const pruneInterval = time.Minute
+
+// Errors returned by the manager
+var (
+	ErrNotFound  = errors.New("job not found")
+	ErrFinished  = errors.New("job already finished")
+	ErrQueueFull = errors.New("job queue is full")
+)
+
+// Manager queues jobs and executes them with a pool of background workers
+type Manager struct {
+	store       Store
+	execute     Executor
+	timeout     time.Duration
+	maxFinished int
+	retention   time.Duration
+	queue       chan string
+	mu          sync.Mutex
+	cancels     map[string]context.CancelFunc
+	// finished indexes the finished jobs from oldest to newest finish, so pruning never reads the store
+	finished []finishedJob
+
+	onFinish []func(Job)
+}
+
+// finishedJob is an entry of the index of finished jobs
+type finishedJob struct {
+	id string
+	at time.Time
+}
+
+// NewManager creates a manager; call Start to launch the workers. Finished jobs are deleted once
+// older than retention or beyond the newest maxFinished; 0 disables each bound.
+func NewManager(store Store, execute Executor, queueSize int, timeout time.Duration, maxFinished int, retention time.Duration) *Manager {
+	return &Manager{
+		store:       store,
+		execute:     execute,
+		timeout:     timeout,
+		maxFinished: maxFinished,
+		retention:   retention,
+		queue:       make(chan string, queueSize),
+		cancels:     make(map[string]context.CancelFunc),
+	}
+}
+
+// OnFinish registers a callback invoked whenever a job reaches a final status
+func (m *Manager) OnFinish(fn func(Job)) {
+	m.onFinish = append(m.onFinish, fn)
+}
+
+// Start launches the workers and requeues jobs left unfinished by a previous run
+func (m *Manager) Start(workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		go m.work()
+	}
+
+	list, err := m.store.List()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pending []string
+	for _, job := range list {
+		if job.Finished() {
+			at := job.CreatedAt
+			if job.FinishedAt != nil {
+				at = *job.FinishedAt
+			}
+			m.finished = append(m.finished, finishedJob{job.ID, at})
+			continue
+		}
+		job.Status = StatusQueued
+		job.Stage = ""
+		job.Error = ""
+		job.StartedAt = nil
+		if err := m.store.Save(job); err != nil {
+			return err
+		}
+		pending = append(pending, job.ID)
+	}
+
+	sort.Slice(m.finished, func(i, j int) bool { return m.finished[i].at.Before(m.finished[j].at) })
+	m.prune()
+	if m.retention > 0 {
+		go m.pruneEvery(pruneInterval)
+	}
+
+	if len(pending) > 0 {
+		log.Printf("Requeuing %d unfinished jobs", len(pending))
+		go func() {
+			for _, id := range pending {
+				m.queue <- id
+			}
+		}()
+	}
+	return nil
+}
+
+// Submit stores a new job of owner and queues it for execution. When the queue is full the job
+// is rejected before being stored, so no id is handed out and no listener is notified.
+func (m *Manager) Submit(owner string, req models.JobRequest) (Job, error) {
+	job := Job{
+		ID:        uuid.NewString(),
+		Status:    StatusQueued,
+		Owner:     owner,
+		Request:   req,
+		CreatedAt: time.Now(),
+	}
+
+	// Workers look jobs up under the lock, so the job is saved before any worker can run it
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case m.queue <- job.ID:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	if err := m.store.Save(job); err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// Get returns a job of owner by id; jobs of other owners are not found
+func (m *Manager) Get(owner, id string) (Job, error) {
+	job, err := m.get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	if job.Owner != owner {
+		return Job{}, ErrNotFound
+	}
+	return job, nil
+}
+
+// get returns a job by id, whoever owns it
+func (m *Manager) get(id string) (Job, error) {
+	job, ok, err := m.store.Get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return job, nil
+}
+
+// Cancel stops a queued or running job of owner
+func (m *Manager) Cancel(owner, id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, err := m.Get(owner, id)
+	if err != nil {
+		return Job{}, err
+	}
+	if job.Finished() {
+		return job, ErrFinished
+	}
+
+	// Running jobs are marked cancelled by their worker once the executor returns
+	if cancel, running := m.cancels[id]; running {
+		cancel()
+		return job, nil
+	}
+
+	job.Status = StatusCancelled
+	m.finish(job)
+	return job, nil
+}
+
+// work executes queued jobs until the process exits
+func (m *Manager) work() {
+	for id := range m.queue {
+		m.run(id)
+	}
+}
+
+// run executes a single job, recording its progress in the store
+func (m *Manager) run(id string) {
+	m.mu.Lock()
+	job, err := m.get(id)
+	if err != nil || job.Status != StatusQueued {
+		m.mu.Unlock()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	m.cancels[id] = cancel
+
+	now := time.Now()
+	job.Status = StatusRunning
+	job.StartedAt = &now
+	m.save(job)
+	m.mu.Unlock()
+
+	ctx = WithProgress(ctx, func(stage string) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		job.Stage = stage
+		m.save(job)
+	})
+
+	result, err := m.execute(ctx, job.Request)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cancels, id)
+
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		job.Status = StatusCancelled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		job.Status = StatusFailed
+		job.Error = fmt.Sprintf("Timed out after %s", m.timeout)
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	default:
+		data, err := json.Marshal(result)
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = "Failed to encode result: " + err.Error()
+			break
+		}
+		job.Status = StatusSucceeded
+		job.Result = data
+	}
+	m.finish(job)
+}
+
+// finish records a job's final status and notifies the listeners
+func (m *Manager) finish(job Job) {
+	now := time.Now()
+	job.FinishedAt = &now
+	m.save(job)
+	m.finished = append(m.finished, finishedJob{job.ID, now})
+	m.prune()
+
+	for _, fn := range m.onFinish {
+		go fn(job)
+	}
+}
+
+// prune deletes the finished jobs past the retention period and the oldest finished jobs
+// beyond the limit; queued and running jobs are always kept. Called with the lock held.
+func (m *Manager) prune() {
+	now := time.Now()
+	n := 0
+	for n < len(m.finished) {
+		excess := m.maxFinished > 0 && len(m.finished)-n > m.maxFinished
+		expired := m.retention > 0 && now.Sub(m.finished[n].at) > m.retention
+		if !excess && !expired {
+			break
+		}
+		if err := m.store.Delete(m.finished[n].id); err != nil {
+			log.Printf("Failed to delete job %s: %v", m.finished[n].id, err)
+		}
+		n++
+	}
+	m.finished = m.finished[n:]
+}
+
+// pruneEvery deletes expired jobs at every interval until the process exits
+func (m *Manager) pruneEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.mu.Lock()
+		m.prune()
+		m.mu.Unlock()
+	}
+}
+
+// save persists a job, logging failures since workers have no caller to report to
+func (m *Manager) save(job Job) {
+	if err := m.store.Save(job); err != nil {
+		log.Printf("Failed to save job %s: %v", job.ID, err)
+	}
+}
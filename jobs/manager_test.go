@@ -0,0 +1,172 @@
+package jobs
+
+import (
+	"context"
+	"credibot-api/models"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// listCountingStore counts how often every job is read back
+type listCountingStore struct {
+	Store
+	lists int32
+}
+
+func (s *listCountingStore) List() ([]Job, error) {
+	atomic.AddInt32(&s.lists, 1)
+	return s.Store.List()
+}
+
+// instant succeeds at once
+func instant(ctx context.Context, req models.JobRequest) (interface{}, error) {
+	return req.Message, nil
+}
+
+// startManager starts a manager with one worker that reports every finished job
+func startManager(t *testing.T, store Store, execute Executor, maxFinished int, retention time.Duration) (*Manager, <-chan Job) {
+	t.Helper()
+	m := NewManager(store, execute, 10, time.Minute, maxFinished, retention)
+	finished := make(chan Job, 10)
+	m.OnFinish(func(job Job) { finished <- job })
+	if err := m.Start(1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return m, finished
+}
+
+// wait returns the next finished job
+func wait(t *testing.T, finished <-chan Job) Job {
+	t.Helper()
+	select {
+	case job := <-finished:
+		return job
+	case <-time.After(5 * time.Second):
+		t.Fatal("no job finished")
+		return Job{}
+	}
+}
+
+func TestJobsAreScopedToTheirOwner(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	m, _ := startManager(t, NewMemoryStore(), func(ctx context.Context, req models.JobRequest) (interface{}, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil, ctx.Err()
+	}, 0, 0)
+
+	job, err := m.Submit("auditoria", models.JobRequest{Type: "smart_chat"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	for _, other := range []string{"", "painel"} {
+		if _, err := m.Get(other, job.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get by %q: error = %v, want %v", other, err, ErrNotFound)
+		}
+		if _, err := m.Cancel(other, job.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Cancel by %q: error = %v, want %v", other, err, ErrNotFound)
+		}
+	}
+	if got, err := m.Get("auditoria", job.ID); err != nil || got.Finished() {
+		t.Fatalf("Get by the owner = %+v, %v; want the unfinished job", got, err)
+	}
+	if _, err := m.Cancel("auditoria", job.ID); err != nil {
+		t.Errorf("Cancel by the owner: %v", err)
+	}
+}
+
+func TestSubmitRejectsWhenTheQueueIsFull(t *testing.T) {
+	store := NewMemoryStore()
+	// Not started, so nothing drains the queue
+	m := NewManager(store, instant, 1, time.Minute, 0, 0)
+
+	if _, err := m.Submit("", models.JobRequest{}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if _, err := m.Submit("", models.JobRequest{}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit on a full queue: error = %v, want %v", err, ErrQueueFull)
+	}
+	if list, _ := store.List(); len(list) != 1 {
+		t.Errorf("stored %d jobs, want only the queued one", len(list))
+	}
+}
+
+func TestFinishedJobsBeyondTheLimitAreDeletedWithoutListingTheStore(t *testing.T) {
+	store := &listCountingStore{Store: NewMemoryStore()}
+	m, finished := startManager(t, store, instant, 2, 0)
+	lists := atomic.LoadInt32(&store.lists)
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		job, err := m.Submit("", models.JobRequest{})
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		ids = append(ids, wait(t, finished).ID)
+		if ids[i] != job.ID {
+			t.Fatalf("finished %s, want %s", ids[i], job.ID)
+		}
+	}
+
+	for i, id := range ids {
+		_, err := m.Get("", id)
+		if kept := i >= 2; kept != (err == nil) {
+			t.Errorf("job %d: kept = %v, want %v", i, err == nil, kept)
+		}
+	}
+	if got := atomic.LoadInt32(&store.lists); got != lists {
+		t.Errorf("the store was listed %d times after Start", got-lists)
+	}
+}
+
+func TestFinishedJobsPastTheRetentionAreDeleted(t *testing.T) {
+	m, finished := startManager(t, NewMemoryStore(), instant, 0, 50*time.Millisecond)
+
+	old, _ := m.Submit("", models.JobRequest{})
+	wait(t, finished)
+	time.Sleep(60 * time.Millisecond)
+	recent, _ := m.Submit("", models.JobRequest{})
+	wait(t, finished)
+
+	if _, err := m.Get("", old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired job: error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := m.Get("", recent.ID); err != nil {
+		t.Errorf("recent job: %v", err)
+	}
+}
+
+func TestStartIndexesFinishedJobsAndRequeuesTheOthers(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, id := range []string{"oldest", "older", "newest"} {
+		at := now.Add(time.Duration(i-3) * time.Minute)
+		store.Save(Job{ID: id, Owner: "auditoria", Status: StatusSucceeded, CreatedAt: at, FinishedAt: &at})
+	}
+	store.Save(Job{ID: "interrupted", Owner: "auditoria", Status: StatusRunning, CreatedAt: now})
+
+	m, finished := startManager(t, store, instant, 2, 0)
+
+	if _, err := m.Get("auditoria", "oldest"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("oldest finished job: error = %v, want %v", err, ErrNotFound)
+	}
+	if job := wait(t, finished); job.ID != "interrupted" || job.Status != StatusSucceeded || job.Owner != "auditoria" {
+		t.Errorf("requeued job finished as %+v", job)
+	}
+	// The requeued job finishing pushes out the next oldest
+	if _, err := m.Get("auditoria", "older"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("older finished job: error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := m.Get("auditoria", "newest"); err != nil {
+		t.Errorf("newest finished job: %v", err)
+	}
+}
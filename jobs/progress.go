@@ -0,0 +1,17 @@
+package jobs
+
+import "context"
+
+type progressKey struct{}
+
+// WithProgress attaches a progress callback to the context
+func WithProgress(ctx context.Context, fn func(stage string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportStage reports the current stage to the progress callback, if any
+func ReportStage(ctx context.Context, stage string) {
+	if fn, ok := ctx.Value(progressKey{}).(func(string)); ok && fn != nil {
+		fn(stage)
+	}
+}
@@ -0,0 +1,180 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store persists jobs
+type Store interface {
+	Save(job Job) error
+	Get(id string) (Job, bool, error)
+	List() ([]Job, error)
+	Delete(id string) error
+}
+
+// MemoryStore keeps jobs in memory; they are lost on restart
+type MemoryStore struct {
+	mu   sync.RWMutex
+	jobs map[string]Job
+}
+
+// NewMemoryStore creates an empty in-memory job store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job)}
+}
+
+// Save stores a job
+func (s *MemoryStore) Save(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = job
+	return nil
+}
+
+// Get returns a job by id
+func (s *MemoryStore) Get(id string) (Job, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[id]
+	return job, ok, nil
+}
+
+// List returns every job, oldest first
+func (s *MemoryStore) List() ([]Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		list = append(list, job)
+	}
+	sortByCreation(list)
+	return list, nil
+}
+
+// Delete removes a job
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+// FileStore keeps one JSON file per job in a directory, so jobs survive restarts
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore creates a file store, creating the directory when needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create job store directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save writes a job atomically
+func (s *FileStore) Save(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path(job.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(job.ID))
+}
+
+// Get reads a job by id
+func (s *FileStore) Get(id string) (Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !validID(id) {
+		return Job{}, false, nil
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return Job{}, false, nil
+	}
+	if err != nil {
+		return Job{}, false, err
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return Job{}, false, err
+	}
+	return job, true, nil
+}
+
+// List reads every job, oldest first
+func (s *FileStore) List() ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Job, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, fmt.Errorf("failed to read job %s: %w", filepath.Base(path), err)
+		}
+		list = append(list, job)
+	}
+	sortByCreation(list)
+	return list, nil
+}
+
+// Delete removes the file holding a job
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !validID(id) {
+		return nil
+	}
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// validID reports whether an id can name a job file. Ids are generated by the server;
+// anything that could escape the directory is rejected.
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\.`)
+}
+
+// path returns the file holding a job
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// sortByCreation orders jobs from oldest to newest
+func sortByCreation(list []Job) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+}
@@ -0,0 +1,184 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// FakeRule answers requests whose messages contain every Contains fragment.
+// A rule with Model only matches that model; a rule with Status fails with that HTTP status.
+type FakeRule struct {
+	Contains []string `json:"contains"`
+	Model    string   `json:"model,omitempty"`
+	Response string   `json:"response"`
+	Status   int      `json:"status,omitempty"`
+}
+
+// defaultFakeRules keep the smart chat pipeline working without a script: the guard lets
+// every question through, questions never need the database and answers echo the question.
+var defaultFakeRules = []FakeRule{
+	{Contains: []string{"in_domain", "off_topic", "injection"}, Response: `{"category":"in_domain"}`},
+	{
+		Contains: []string{"needs_database", "tables_used"},
+		Response: `{"intent":"general","needs_database":false,"sql":null,"tables_used":[],"confidence":1,"clarification":null}`,
+	},
+	{Contains: []string{"NO_DATABASE_NEEDED"}, Response: "NO_DATABASE_NEEDED"},
+}
+
+// FakeProvider is a deterministic provider for tests and local development.
+// It answers with the first matching scripted rule, or echoes the last user message.
+type FakeProvider struct {
+	rules []FakeRule
+}
+
+// NewFakeProvider creates a fake provider, loading rules from a JSON script when a path is given
+func NewFakeProvider(scriptPath string) (*FakeProvider, error) {
+	if scriptPath == "" {
+		return &FakeProvider{rules: defaultFakeRules}, nil
+	}
+
+	data, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fake LLM script: %w", err)
+	}
+
+	var rules []FakeRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse fake LLM script: %w", err)
+	}
+	return &FakeProvider{rules: rules}, nil
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *FakeProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	rule := p.match(req)
+	if rule.Status != 0 {
+		return openai.ChatCompletionResponse{}, &openai.APIError{
+			HTTPStatusCode: rule.Status,
+			Message:        fmt.Sprintf("simulated status %d", rule.Status),
+		}
+	}
+
+	content := rule.Response
+	promptTokens := 0
+	for _, message := range req.Messages {
+		promptTokens += len(strings.Fields(message.Content))
+	}
+	completionTokens := len(strings.Fields(content))
+
+	return openai.ChatCompletionResponse{
+		ID:     "fake-completion",
+		Object: "chat.completion",
+		Model:  req.Model,
+		Choices: []openai.ChatCompletionChoice{{
+			Message: openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: content,
+			},
+			FinishReason: openai.FinishReasonStop,
+		}},
+		Usage: openai.Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}, nil
+}
+
+func (p *FakeProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	resp, err := p.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stream the answer word by word, then the usage chunk
+	var chunks []openai.ChatCompletionStreamResponse
+	for i, word := range strings.SplitAfter(resp.Choices[0].Message.Content, " ") {
+		delta := openai.ChatCompletionStreamChoiceDelta{Content: word}
+		if i == 0 {
+			delta.Role = openai.ChatMessageRoleAssistant
+		}
+		chunks = append(chunks, openai.ChatCompletionStreamResponse{
+			ID:      resp.ID,
+			Object:  "chat.completion.chunk",
+			Model:   resp.Model,
+			Choices: []openai.ChatCompletionStreamChoice{{Delta: delta}},
+		})
+	}
+	chunks = append(chunks, openai.ChatCompletionStreamResponse{
+		ID:     resp.ID,
+		Object: "chat.completion.chunk",
+		Model:  resp.Model,
+		Usage:  &resp.Usage,
+	})
+
+	return &fakeStream{ctx: ctx, chunks: chunks}, nil
+}
+
+// match returns the first rule matching the request, or an echo of the last user message
+func (p *FakeProvider) match(req openai.ChatCompletionRequest) FakeRule {
+	var all strings.Builder
+	lastUser := ""
+	for _, message := range req.Messages {
+		all.WriteString(message.Content)
+		all.WriteString("\n")
+		if message.Role == openai.ChatMessageRoleUser {
+			lastUser = message.Content
+		}
+	}
+	text := all.String()
+
+	for _, rule := range p.rules {
+		if rule.Model != "" && rule.Model != req.Model {
+			continue
+		}
+		matched := true
+		for _, fragment := range rule.Contains {
+			if !strings.Contains(text, fragment) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule
+		}
+	}
+
+	return FakeRule{Response: "Resposta simulada: " + lastUser}
+}
+
+// fakeStream replays precomputed chunks
+type fakeStream struct {
+	ctx    context.Context
+	chunks []openai.ChatCompletionStreamResponse
+}
+
+func (s *fakeStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if err := s.ctx.Err(); err != nil {
+		return openai.ChatCompletionStreamResponse{}, err
+	}
+	if len(s.chunks) == 0 {
+		return openai.ChatCompletionStreamResponse{}, io.EOF
+	}
+
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *fakeStream) Close() error {
+	return nil
+}
@@ -0,0 +1,225 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// fallbackEntry is a provider in the fallback chain and the model it is asked for
+type fallbackEntry struct {
+	provider Provider
+	// model replaces the requested model; empty keeps it
+	model string
+}
+
+// label identifies an entry in logs and errors
+func (e fallbackEntry) label(requested string) string {
+	model := e.model
+	if model == "" {
+		model = requested
+	}
+	return e.provider.Name() + ":" + model
+}
+
+// fallbackProvider tries each entry in order until one answers. Only upstream
+// failures (5xx, timeouts, rate limits, unreachable servers) move on to the next entry.
+type fallbackProvider struct {
+	entries []fallbackEntry
+}
+
+func (p *fallbackProvider) Name() string {
+	return p.entries[0].provider.Name()
+}
+
+func (p *fallbackProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	var failures []string
+	for i, entry := range p.entries {
+		attempt := req
+		if entry.model != "" {
+			attempt.Model = entry.model
+		}
+
+		bound := startAttempt(ctx, i, len(p.entries))
+		resp, err := entry.provider.CreateChatCompletion(bound.ctx, attempt)
+		fallback := err != nil && shouldFallback(ctx, bound.ctx, err)
+		bound.cancel()
+		if err == nil {
+			if resp.Model == "" {
+				resp.Model = attempt.Model
+			}
+			return resp, nil
+		}
+		if !fallback || i == len(p.entries)-1 {
+			return resp, chainError(failures, entry.label(req.Model), err)
+		}
+
+		log.Printf("LLM %s failed, falling back: %v", entry.label(req.Model), err)
+		failures = append(failures, fmt.Sprintf("%s: %v", entry.label(req.Model), err))
+	}
+	return openai.ChatCompletionResponse{}, errors.New("no LLM provider configured")
+}
+
+func (p *fallbackProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	stream := &fallbackStream{ctx: ctx, req: req, entries: p.entries}
+	if err := stream.open(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// fallbackStream moves to the next entry when a stream fails before yielding any chunk
+type fallbackStream struct {
+	ctx      context.Context
+	req      openai.ChatCompletionRequest
+	entries  []fallbackEntry
+	next     int
+	current  Stream
+	bound    attemptBound
+	model    string
+	started  bool
+	failures []string
+}
+
+// open starts a stream on the next entry that accepts the request
+func (s *fallbackStream) open() error {
+	for s.next < len(s.entries) {
+		entry := s.entries[s.next]
+		s.next++
+
+		attempt := s.req
+		if entry.model != "" {
+			attempt.Model = entry.model
+		}
+
+		s.bound = startAttempt(s.ctx, s.next-1, len(s.entries))
+		stream, err := entry.provider.CreateChatCompletionStream(s.bound.ctx, attempt)
+		if err == nil {
+			s.current = stream
+			s.model = attempt.Model
+			return nil
+		}
+		err = s.fail(entry.label(s.req.Model), err)
+		s.bound.cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return errors.New("no LLM provider configured")
+}
+
+// fail records a failed entry, returning the error when the chain must stop
+func (s *fallbackStream) fail(label string, err error) error {
+	if !shouldFallback(s.ctx, s.bound.ctx, err) || s.next == len(s.entries) {
+		return chainError(s.failures, label, err)
+	}
+	log.Printf("LLM %s failed, falling back: %v", label, err)
+	s.failures = append(s.failures, fmt.Sprintf("%s: %v", label, err))
+	return nil
+}
+
+func (s *fallbackStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	for {
+		chunk, err := s.current.Recv()
+		if err == nil || errors.Is(err, io.EOF) || s.started {
+			if err == nil {
+				// The entry answered, so it may use the rest of the request budget
+				if !s.started {
+					s.bound.settle()
+				}
+				s.started = true
+				if chunk.Model == "" {
+					chunk.Model = s.model
+				}
+			}
+			return chunk, err
+		}
+
+		// Nothing was streamed yet, so the next entry can still answer
+		s.current.Close()
+		failErr := s.fail(s.entries[s.next-1].label(s.req.Model), err)
+		s.bound.cancel()
+		if failErr != nil {
+			return chunk, failErr
+		}
+		if openErr := s.open(); openErr != nil {
+			return chunk, openErr
+		}
+	}
+}
+
+func (s *fallbackStream) Close() error {
+	defer s.bound.cancel()
+	return s.current.Close()
+}
+
+// attemptBound is the context of an attempt on one entry of the chain
+type attemptBound struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	timer  *time.Timer
+}
+
+// startAttempt bounds the attempt on entry i of n to an even share of the time left before the
+// request deadline, so an entry that hangs leaves time for the ones after it. The last entry, and
+// every entry of a request without a deadline, may use all the time left.
+func startAttempt(ctx context.Context, i, n int) attemptBound {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	bound := attemptBound{ctx: attemptCtx, cancel: cancel}
+	if deadline, ok := ctx.Deadline(); ok && i < n-1 {
+		bound.timer = time.AfterFunc(time.Until(deadline)/time.Duration(n-i), cancel)
+	}
+	return bound
+}
+
+// settle lifts the attempt deadline once the entry has started answering
+func (b attemptBound) settle() {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// chainError reports the final error together with the entries that failed before it
+func chainError(failures []string, label string, err error) error {
+	if len(failures) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s: %w (after %s)", label, err, strings.Join(failures, "; "))
+}
+
+// shouldFallback reports whether an error is an upstream failure another model may not have.
+// An attempt that ran out of its share of the budget falls back while the request has time left.
+func shouldFallback(ctx, attempt context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if attempt.Err() != nil {
+		return true
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return retryableStatus(reqErr.HTTPStatusCode)
+	}
+
+	// Timeouts and unreachable servers surface as network errors
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryableStatus reports whether an HTTP status is a server error, timeout or rate limit
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
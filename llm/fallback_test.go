@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// hangingProvider answers only when its context ends
+type hangingProvider struct{ FakeProvider }
+
+func (hangingProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	<-ctx.Done()
+	return openai.ChatCompletionResponse{}, ctx.Err()
+}
+
+func (hangingProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// lateFailureProvider fails with a 503 after a pause, whatever its context
+type lateFailureProvider struct {
+	FakeProvider
+	pause time.Duration
+}
+
+func (p *lateFailureProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	time.Sleep(p.pause)
+	return openai.ChatCompletionResponse{}, &openai.APIError{HTTPStatusCode: http.StatusServiceUnavailable}
+}
+
+// slowStreamProvider streams its first chunk at once and the next one after a pause
+type slowStreamProvider struct {
+	FakeProvider
+	pause time.Duration
+}
+
+func (p *slowStreamProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	stream, err := p.FakeProvider.CreateChatCompletionStream(ctx, req)
+	return &pausingStream{Stream: stream, pause: p.pause}, err
+}
+
+type pausingStream struct {
+	Stream
+	pause    time.Duration
+	received int
+}
+
+func (s *pausingStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if s.received++; s.received == 2 {
+		time.Sleep(s.pause)
+	}
+	return s.Stream.Recv()
+}
+
+// recordingFake counts the requests it answers
+type recordingFake struct {
+	FakeProvider
+	calls int
+}
+
+func (p *recordingFake) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	p.calls++
+	return p.FakeProvider.CreateChatCompletion(ctx, req)
+}
+
+func request(model, question string) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model:    model,
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: question}},
+	}
+}
+
+func TestFallbackOnlyOnUpstreamFailures(t *testing.T) {
+	fake := &FakeProvider{rules: []FakeRule{
+		{Model: "primary", Contains: []string{"instável"}, Status: http.StatusServiceUnavailable},
+		{Model: "primary", Contains: []string{"inválida"}, Status: http.StatusBadRequest},
+	}}
+	chain := &fallbackProvider{entries: []fallbackEntry{{provider: fake}, {provider: fake, model: "backup"}}}
+
+	resp, err := chain.CreateChatCompletion(context.Background(), request("primary", "instável"))
+	if err != nil || resp.Model != "backup" {
+		t.Fatalf("after a 503: model %q, error %v; want the backup", resp.Model, err)
+	}
+
+	_, err = chain.CreateChatCompletion(context.Background(), request("primary", "inválida"))
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusBadRequest {
+		t.Fatalf("after a 400: error %v, want the 400 without falling back", err)
+	}
+}
+
+func TestHangingEntryLeavesTimeForTheNext(t *testing.T) {
+	chain := &fallbackProvider{entries: []fallbackEntry{{provider: &hangingProvider{}}, {provider: &FakeProvider{}, model: "backup"}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := chain.CreateChatCompletion(ctx, request("primary", "Quantos clientes?"))
+	if err != nil || resp.Model != "backup" {
+		t.Fatalf("model %q, error %v; want the backup", resp.Model, err)
+	}
+	// The hanging entry gets half of the budget
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > 300*time.Millisecond {
+		t.Errorf("answered after %v, want about 200ms", elapsed)
+	}
+
+	stream, err := chain.CreateChatCompletionStream(ctx, request("primary", "Quantos clientes?"))
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	defer stream.Close()
+	if chunk, err := stream.Recv(); err != nil || chunk.Model != "backup" {
+		t.Errorf("first chunk from %q, error %v; want the backup", chunk.Model, err)
+	}
+}
+
+func TestEachEntryGetsAShareOfWhatIsLeft(t *testing.T) {
+	chain := &fallbackProvider{entries: []fallbackEntry{
+		{provider: &hangingProvider{}}, {provider: &hangingProvider{}}, {provider: &FakeProvider{}, model: "backup"},
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+
+	// A third of 600ms, then half of the 400ms left
+	start := time.Now()
+	resp, err := chain.CreateChatCompletion(ctx, request("primary", "Quantos clientes?"))
+	if err != nil || resp.Model != "backup" {
+		t.Fatalf("model %q, error %v; want the backup", resp.Model, err)
+	}
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("answered after %v, want about 400ms", elapsed)
+	}
+}
+
+func TestNoFallbackOnceTheRequestExpired(t *testing.T) {
+	backup := &recordingFake{}
+	chain := &fallbackProvider{entries: []fallbackEntry{{provider: &lateFailureProvider{pause: 150 * time.Millisecond}}, {provider: backup}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := chain.CreateChatCompletion(ctx, request("primary", "Quantos clientes?"))
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || backup.calls != 0 {
+		t.Fatalf("error %v after %d backup calls; want the 503 without falling back", err, backup.calls)
+	}
+}
+
+func TestStartedStreamOutlivesTheAttemptDeadline(t *testing.T) {
+	slow := &slowStreamProvider{pause: 300 * time.Millisecond}
+	chain := &fallbackProvider{entries: []fallbackEntry{{provider: slow}, {provider: &FakeProvider{}, model: "backup"}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+
+	stream, err := chain.CreateChatCompletionStream(ctx, request("primary", "Quantos clientes PJ?"))
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	defer stream.Close()
+
+	var answer string
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if chunk.Model != "primary" {
+			t.Fatalf("chunk from %q, want the primary", chunk.Model)
+		}
+		if len(chunk.Choices) > 0 {
+			answer += chunk.Choices[0].Delta.Content
+		}
+	}
+	if want := "Resposta simulada: Quantos clientes PJ?"; answer != want {
+		t.Errorf("answer = %q, want %q", answer, want)
+	}
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"credibot-api/models"
+	"fmt"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// openAIProvider talks to OpenAI, Azure OpenAI or any OpenAI-compatible server
+type openAIProvider struct {
+	name   string
+	client *openai.Client
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	return p.client.CreateChatCompletion(ctx, req)
+}
+
+func (p *openAIProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	return p.client.CreateChatCompletionStream(ctx, req)
+}
+
+// newOpenAIProvider creates a provider for the public OpenAI API
+func newOpenAIProvider(cfg models.LLMConfig, httpClient *http.Client) (Provider, error) {
+	if cfg.APIKey == "" {
+		return &unconfiguredProvider{name: ProviderOpenAI, err: fmt.Errorf("OpenAI API key not configured")}, nil
+	}
+
+	clientConfig := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		clientConfig.BaseURL = cfg.BaseURL
+	}
+	clientConfig.HTTPClient = httpClient
+	return &openAIProvider{name: ProviderOpenAI, client: openai.NewClientWithConfig(clientConfig)}, nil
+}
+
+// newAzureProvider creates a provider for an Azure OpenAI resource
+func newAzureProvider(cfg models.LLMConfig, httpClient *http.Client) (Provider, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("LLM_BASE_URL is required for the azure provider")
+	}
+	if cfg.APIKey == "" {
+		return &unconfiguredProvider{name: ProviderAzure, err: fmt.Errorf("Azure OpenAI API key not configured")}, nil
+	}
+
+	clientConfig := openai.DefaultAzureConfig(cfg.APIKey, cfg.BaseURL)
+	if cfg.APIVersion != "" {
+		clientConfig.APIVersion = cfg.APIVersion
+	}
+	if cfg.AzureDeployment != "" {
+		deployment := cfg.AzureDeployment
+		clientConfig.AzureModelMapperFunc = func(model string) string {
+			return deployment
+		}
+	}
+	clientConfig.HTTPClient = httpClient
+	return &openAIProvider{name: ProviderAzure, client: openai.NewClientWithConfig(clientConfig)}, nil
+}
+
+// newLocalProvider creates a provider for an OpenAI-compatible server such as Ollama or llama.cpp
+func newLocalProvider(cfg models.LLMConfig, httpClient *http.Client) (Provider, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("LLM_BASE_URL is required for the local provider")
+	}
+
+	// Local servers usually ignore the key, but the client always sends one
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = "local"
+	}
+
+	clientConfig := openai.DefaultConfig(apiKey)
+	clientConfig.BaseURL = cfg.BaseURL
+	clientConfig.HTTPClient = httpClient
+	return &openAIProvider{name: ProviderLocal, client: openai.NewClientWithConfig(clientConfig)}, nil
+}
+
+// unconfiguredProvider fails every call, so the API can still start without credentials
+type unconfiguredProvider struct {
+	name string
+	err  error
+}
+
+func (p *unconfiguredProvider) Name() string {
+	return p.name
+}
+
+func (p *unconfiguredProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	return openai.ChatCompletionResponse{}, p.err
+}
+
+func (p *unconfiguredProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	return nil, p.err
+}
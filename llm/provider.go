@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"credibot-api/models"
+	"fmt"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Provider names accepted in LLM_PROVIDER
+const (
+	ProviderOpenAI = "openai"
+	ProviderAzure  = "azure"
+	ProviderLocal  = "local"
+	ProviderFake   = "fake"
+)
+
+// Provider is a chat completion backend. Requests and responses use the OpenAI
+// wire format, which every supported backend speaks.
+type Provider interface {
+	Name() string
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+	CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error)
+}
+
+// Stream yields chat completion chunks until io.EOF
+type Stream interface {
+	Recv() (openai.ChatCompletionStreamResponse, error)
+	Close() error
+}
+
+// New creates the provider selected by the configuration, sending requests through httpClient.
+// Configured fallbacks wrap it in a chain tried in order.
+func New(cfg models.LLMConfig, httpClient *http.Client) (Provider, error) {
+	primary, err := newProvider(cfg, httpClient)
+	if err != nil || len(cfg.Fallbacks) == 0 {
+		return primary, err
+	}
+
+	entries := []fallbackEntry{{provider: primary}}
+	for _, fallback := range cfg.Fallbacks {
+		provider, err := newProvider(fallback.LLMConfig, httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("fallback %s:%s: %w", fallback.Provider, fallback.Model, err)
+		}
+		entries = append(entries, fallbackEntry{provider: provider, model: fallback.Model})
+	}
+	return &fallbackProvider{entries: entries}, nil
+}
+
+// newProvider creates a single provider
+func newProvider(cfg models.LLMConfig, httpClient *http.Client) (Provider, error) {
+	switch cfg.Provider {
+	case ProviderOpenAI, "":
+		return newOpenAIProvider(cfg, httpClient)
+	case ProviderAzure:
+		return newAzureProvider(cfg, httpClient)
+	case ProviderLocal:
+		return newLocalProvider(cfg, httpClient)
+	case ProviderFake:
+		return NewFakeProvider(cfg.FakeScript)
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", cfg.Provider)
+	}
+}
@@ -1,8 +1,21 @@
 package main
 
 import (
+	"credibot-api/auth"
+	"credibot-api/cache"
 	"credibot-api/config"
+	"credibot-api/conversations"
+	"credibot-api/documents"
+	"credibot-api/experiments"
+	"credibot-api/grpcserver"
+	"credibot-api/guard"
 	"credibot-api/handlers"
+	"credibot-api/httpclient"
+	"credibot-api/llm"
+	"credibot-api/mcp"
+	"credibot-api/prompts"
+	"credibot-api/usage"
+	"credibot-api/webhooks"
 	"log"
 	"os"
 
@@ -14,6 +27,95 @@ import (
 func main() {
 	config.LoadConfig()
 
+	// Shared HTTP clients, one per upstream
+	llmHTTP, err := httpclient.New("llm", config.AppConfig.LLMHTTP)
+	if err != nil {
+		log.Fatalf("Failed to create LLM HTTP client: %v", err)
+	}
+	supabaseHTTP, err := httpclient.New("supabase", config.AppConfig.SupabaseHTTP)
+	if err != nil {
+		log.Fatalf("Failed to create Supabase HTTP client: %v", err)
+	}
+	// Callbacks go to caller-supplied URLs, so internal addresses are refused even when dialing
+	webhookAddresses := webhooks.NewAddressPolicy(config.AppConfig.Webhooks.AllowedHosts)
+	webhookHTTP, err := httpclient.New("webhooks", config.AppConfig.WebhookHTTP, httpclient.WithResolver(webhookAddresses.Resolve))
+	if err != nil {
+		log.Fatalf("Failed to create webhook HTTP client: %v", err)
+	}
+
+	provider, err := llm.New(config.AppConfig.LLM, llmHTTP)
+	if err != nil {
+		log.Fatalf("Failed to configure LLM provider: %v", err)
+	}
+	log.Printf("Using LLM provider: %s", provider.Name())
+
+	// Every model call is metered for usage accounting
+	usageStore, err := usage.NewStore(config.AppConfig.Usage.StoreDir)
+	if err != nil {
+		log.Fatalf("Failed to create usage store: %v", err)
+	}
+	provider = usage.NewMeter(usageStore, usage.NewPricing(config.AppConfig.Usage.Prices)).Wrap(provider)
+
+	responseCache, err := cache.New(config.AppConfig.Cache)
+	if err != nil {
+		log.Fatalf("Failed to create response cache: %v", err)
+	}
+
+	promptRegistry, err := prompts.Load(config.AppConfig.Prompts.Dir, config.AppConfig.Prompts.Versions)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+
+	experimentSet, err := experiments.Load(config.AppConfig.Experiments.File)
+	if err == nil {
+		err = experimentSet.CheckPrompts(promptRegistry.Has)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load experiments: %v", err)
+	}
+	experimentStore, err := experiments.NewStore(config.AppConfig.Experiments.StoreDir)
+	if err != nil {
+		log.Fatalf("Failed to create experiments store: %v", err)
+	}
+
+	documentLibrary, err := documents.Open(config.AppConfig.Documents.Dir, config.AppConfig.Documents.IndexDir, documents.Options{
+		ChunkWords:   config.AppConfig.Documents.ChunkWords,
+		ChunkOverlap: config.AppConfig.Documents.ChunkOverlap,
+	})
+	if err != nil {
+		log.Fatalf("Failed to index documents: %v", err)
+	}
+
+	guardRules, err := guard.LoadRules(config.AppConfig.Guard.RulesFile)
+	if err != nil {
+		log.Fatalf("Failed to load guard rules: %v", err)
+	}
+
+	conversations.Default = conversations.NewStore(config.AppConfig.Conversations.MaxConversations, config.AppConfig.Conversations.TTL)
+
+	handlers.Configure(handlers.Dependencies{
+		LLM:              provider,
+		SupabaseHTTP:     supabaseHTTP,
+		WebhookHTTP:      webhookHTTP,
+		WebhookAddresses: webhookAddresses,
+		Usage:            usageStore,
+		Cache:            responseCache,
+		Prompts:          promptRegistry,
+		Experiments:      experimentSet,
+		ExperimentStore:  experimentStore,
+		Documents:        documentLibrary,
+		Guard:            guardRules,
+		APIKeys:          auth.NewKeys(config.AppConfig.APIKeys),
+	})
+
+	// MCP over stdio: `credibot-api mcp`
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		if err := mcp.ServeStdio(os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	app := fiber.New(fiber.Config{
 		ErrorHandler: func(ctx *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
@@ -31,8 +133,9 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowMethods: "GET,POST,HEAD,PUT,DELETE,PATCH",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders: "Origin,Content-Type,Accept,Authorization,X-API-Key,X-Admin-Key,X-Caller-ID,X-User-ID",
 	}))
+	app.Use(handlers.Authenticate)
 
 	// HEALTH
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -45,10 +148,66 @@ func main() {
 	// CHAT
 	api.Post("/chat", handlers.Chat)
 	api.Post("/smart-chat", handlers.SmartChat)
+	api.Post("/smart-chat/batch", handlers.SmartChatBatch)
+
+	// WEBSOCKET
+	api.Use("/ws", handlers.WebSocketUpgrade)
+	api.Get("/ws", handlers.ChatSocket)
+
+	// CONVERSATIONS
+	conversationRoutes := api.Group("/conversations", handlers.RequireIdentity)
+	conversationRoutes.Get("/", handlers.ListConversations)
+	conversationRoutes.Get("/:id", handlers.GetConversation)
+	conversationRoutes.Delete("/:id", handlers.DeleteConversation)
+
+	// MCP (HTTP)
+	app.Post("/mcp", handlers.RequireIdentity, mcp.Handler)
+
+	// JOBS
+	api.Post("/jobs", handlers.SubmitJob)
+	api.Get("/jobs/:id", handlers.GetJob)
+	api.Delete("/jobs/:id", handlers.CancelJob)
+
+	// ADMIN
+	admin := api.Group("/admin", handlers.RequireAdmin)
+	admin.Get("/upstreams", handlers.UpstreamStats)
+	admin.Get("/cache", handlers.CacheStats)
+	admin.Delete("/cache", handlers.PurgeCache)
+	admin.Get("/prompts", handlers.ListPrompts)
+	admin.Post("/prompts/reload", handlers.ReloadPrompts)
+	admin.Put("/prompts/:name", handlers.ActivatePrompt)
+	admin.Get("/experiments", handlers.ExperimentReport)
+	admin.Get("/documents", handlers.ListDocuments)
+	admin.Post("/documents/reindex", handlers.ReindexDocuments)
+	admin.Get("/webhooks/deliveries", handlers.ListWebhookDeliveries)
+	admin.Get("/webhooks/deliveries/:id", handlers.GetWebhookDelivery)
+	admin.Post("/webhooks/deliveries/:id/replay", handlers.ReplayWebhookDelivery)
 
 	// SUPABASE (READ-ONLY)
 	api.Get("/data/:table", handlers.GetData)
 
+	// DOCUMENTS
+	api.Get("/documents/search", handlers.SearchDocuments)
+
+	// USAGE
+	api.Get("/usage", handlers.GetUsage)
+
+	// FEEDBACK
+	api.Post("/feedback", handlers.SubmitFeedback)
+
+	// OPENAI-COMPATIBLE FACADE
+	compat := app.Group("/v1")
+	compat.Get("/models", handlers.CompatListModels)
+	compat.Post("/chat/completions", handlers.CompatChatCompletions)
+
+	// BACKGROUND JOBS
+	if err := handlers.StartJobs(); err != nil {
+		log.Fatalf("Failed to start jobs: %v", err)
+	}
+
+	// GRPC
+	grpcserver.Start(config.AppConfig.GRPCPort, config.AppConfig.GRPCReflection)
+
 	// START
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,151 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"credibot-api/config"
+	"credibot-api/experiments"
+	"credibot-api/handlers"
+	"credibot-api/usage"
+	"encoding/json"
+	"io"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// protocolVersion is the Model Context Protocol revision implemented by the server
+const protocolVersion = "2025-03-26"
+
+// stdioCaller is the usage accounting caller, and experiment user, of the local stdio client
+const stdioCaller = "mcp-stdio"
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// request represents a JSON-RPC request or notification
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response represents a JSON-RPC response
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError represents a JSON-RPC error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// toolCallParams are the parameters of a tools/call request
+type toolCallParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// handleMessage processes a single JSON-RPC message. Notifications produce no response.
+func handleMessage(ctx context.Context, raw []byte) *response {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "Parse error"}}
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return &response{JSONRPC: "2.0", ID: idOrNull(req.ID), Error: &rpcError{codeInvalidRequest, "Invalid request"}}
+	}
+
+	// Notifications (no id) never get a response
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	switch req.Method {
+	case "initialize":
+		resp.Result = map[string]interface{}{
+			"protocolVersion": protocolVersion,
+			"capabilities": map[string]interface{}{
+				"tools": map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{
+				"name":    "credibot",
+				"version": "1.0.0",
+			},
+		}
+	case "ping":
+		resp.Result = map[string]interface{}{}
+	case "tools/list":
+		resp.Result = map[string]interface{}{"tools": toolDefinitions()}
+	case "tools/call":
+		var params toolCallParams
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+			resp.Error = &rpcError{codeInvalidParams, "Invalid tool call parameters"}
+			break
+		}
+		result, ok := callTool(ctx, params.Name, params.Arguments)
+		if !ok {
+			resp.Error = &rpcError{codeInvalidParams, "Unknown tool: " + params.Name}
+			break
+		}
+		resp.Result = result
+	default:
+		resp.Error = &rpcError{codeMethodNotFound, "Method not found: " + req.Method}
+	}
+
+	return resp
+}
+
+// idOrNull returns the request id, or null when it is missing
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// ServeStdio serves MCP over newline-delimited JSON-RPC on the given streams
+func ServeStdio(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	encoder := json.NewEncoder(out)
+
+	log.Println("MCP server listening on stdio")
+	ctx := usage.WithCaller(context.Background(), stdioCaller, "mcp:stdio")
+	ctx = experiments.WithUser(ctx, stdioCaller)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if resp := handleMessage(ctx, line); resp != nil {
+			if err := encoder.Encode(resp); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// Handler serves MCP over HTTP: each POST carries one JSON-RPC message
+func Handler(c *fiber.Ctx) error {
+	ctx, cancel := handlers.RequestContext(c, config.AppConfig.RequestTimeout)
+	defer cancel()
+
+	resp := handleMessage(ctx, c.Body())
+	if resp == nil {
+		return c.SendStatus(fiber.StatusAccepted)
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,114 @@
+package mcp
+
+import (
+	"context"
+	"credibot-api/handlers"
+	"credibot-api/models"
+	"encoding/json"
+	"fmt"
+)
+
+// tool describes an MCP tool and how to run it
+type tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	run         func(ctx context.Context, args json.RawMessage) (interface{}, error)
+}
+
+// tools lists every tool exposed by the server
+var tools = []tool{
+	queryTableTool(),
+	{
+		Name:        "get_client_profile",
+		Description: "Retorna o perfil de um cliente com suas análises de crédito, operações e histórico de score mais recentes.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"cliente_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Identificador do cliente",
+				},
+				"nome": map[string]interface{}{
+					"type":        "string",
+					"description": "Nome (ou parte do nome) do cliente, usado quando cliente_id não é informado",
+				},
+			},
+		},
+		run: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+			var params struct {
+				ClienteID string `json:"cliente_id"`
+				Nome      string `json:"nome"`
+			}
+			if len(args) > 0 {
+				if err := json.Unmarshal(args, &params); err != nil {
+					return nil, fmt.Errorf("invalid arguments: %w", err)
+				}
+			}
+			return handlers.GetClientProfile(ctx, params.ClienteID, params.Nome)
+		},
+	},
+	{
+		Name:        "list_tables",
+		Description: "Lista as tabelas do banco de crédito disponíveis para consulta e suas colunas.",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		run: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+			return handlers.CreditSchema, nil
+		},
+	},
+}
+
+// queryTableTool runs structured, validated and row-limited queries, the same query_table
+// function the smart chat tools mode offers to the model
+func queryTableTool() tool {
+	description, parameters := handlers.QueryTableSchema()
+	return tool{
+		Name:        "query_table",
+		Description: description,
+		InputSchema: parameters,
+		run: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+			var query models.TableQuery
+			if err := json.Unmarshal(args, &query); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %w", err)
+			}
+			return handlers.QueryTable(ctx, query)
+		},
+	}
+}
+
+// toolDefinitions returns the tool list advertised through tools/list
+func toolDefinitions() []tool {
+	return tools
+}
+
+// callTool runs a tool, reporting failures as tool errors so the calling agent can see them
+func callTool(ctx context.Context, name string, args json.RawMessage) (map[string]interface{}, bool) {
+	for _, t := range tools {
+		if t.Name != name {
+			continue
+		}
+
+		result, err := t.run(ctx, args)
+		if err != nil {
+			return toolResult(err.Error(), true), true
+		}
+
+		text, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return toolResult("Failed to encode result: "+err.Error(), true), true
+		}
+		return toolResult(string(text), false), true
+	}
+	return nil, false
+}
+
+// toolResult builds a tools/call result with a single text content block
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
@@ -4,26 +4,217 @@ import "time"
 
 // ChatRequest represents a chat request to OpenAI
 type ChatRequest struct {
-	Message   string `json:"message" validate:"required,min=1"`
-	Model     string `json:"model,omitempty"`
-	MaxTokens int    `json:"max_tokens,omitempty"`
+	Message        string `json:"message" validate:"required,min=1"`
+	Model          string `json:"model,omitempty"`
+	MaxTokens      int    `json:"max_tokens,omitempty"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	// Messages carries a whole conversation instead of Message, for clients that keep their own history
+	Messages []ChatMessage `json:"messages,omitempty"`
+	// Generation parameters of /chat, checked against the GenerationConfig limits;
+	// unset ones use the deployment defaults
+	SystemPrompt string   `json:"system_prompt,omitempty"`
+	Temperature  *float32 `json:"temperature,omitempty"`
+	TopP         *float32 `json:"top_p,omitempty"`
+	Stop         []string `json:"stop,omitempty"`
+	Seed         *int     `json:"seed,omitempty"`
+	// ResponseFormat is "text" (default) or "json_object"
+	ResponseFormat string `json:"response_format,omitempty"`
+}
+
+// ChatMessage is a message of a conversation sent by a client: system, user or assistant
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
 }
 
 // ChatResponse represents the chat response
 type ChatResponse struct {
-	Message   string    `json:"message"`
-	Model     string    `json:"model"`
-	Usage     Usage     `json:"usage"`
-	CreatedAt time.Time `json:"created_at"`
+	Message   string        `json:"message"`
+	Model     string        `json:"model"`
+	Usage     Usage         `json:"usage"`
+	Budget    *PromptBudget `json:"budget,omitempty"`
+	Cached    bool          `json:"cached,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
 }
 
 // SmartChatResponse represents the smart chat response with database integration
 type SmartChatResponse struct {
-	Message      string      `json:"message"`
-	UsedDatabase bool        `json:"used_database"`
-	SQLQuery     string      `json:"sql_query,omitempty"`
-	DatabaseData interface{} `json:"database_data,omitempty"`
-	CreatedAt    time.Time   `json:"created_at"`
+	Message        string            `json:"message"`
+	Model          string            `json:"model,omitempty"`
+	UsedDatabase   bool              `json:"used_database"`
+	SQLQuery       string            `json:"sql_query,omitempty"`
+	TableQuery     *TableQuery       `json:"table_query,omitempty"`
+	Analysis       *QuestionAnalysis `json:"analysis,omitempty"`
+	Budget         *PromptBudget     `json:"budget,omitempty"`
+	Cached         bool              `json:"cached,omitempty"`
+	PromptVersions map[string]string `json:"prompt_versions,omitempty"`
+	RequestID      string            `json:"request_id,omitempty"`
+	Variants       []string          `json:"variants,omitempty"`
+	Sources        []Citation        `json:"sources,omitempty"`
+	Refusal        *Refusal          `json:"refusal,omitempty"`
+	Redacted       bool              `json:"redacted,omitempty"`
+	Usage          *Usage            `json:"usage,omitempty"`
+	DatabaseData   interface{}       `json:"database_data,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// BatchRequest represents a list of questions for the smart chat pipeline
+type BatchRequest struct {
+	Questions []string `json:"questions"`
+}
+
+// BatchItemResult represents the outcome of a single batch question
+type BatchItemResult struct {
+	Index    int                `json:"index"`
+	Question string             `json:"question"`
+	Response *SmartChatResponse `json:"response,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// BatchResponse represents the per-item results of a batch
+type BatchResponse struct {
+	Results   []BatchItemResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// JobRequest represents a request executed asynchronously as a job.
+// Type selects the pipeline: chat, smart_chat or batch.
+type JobRequest struct {
+	Type string `json:"type"`
+	ChatRequest
+	Questions   []string `json:"questions,omitempty"`
+	CallbackURL string   `json:"callback_url,omitempty"`
+	// Caller is taken from the submitting request, so queued work is billed to it
+	Caller string `json:"caller,omitempty"`
+	// Role is the role the submitter's API key authenticates; empty when it was anonymous
+	Role string `json:"role,omitempty"`
+	// User keeps the experiment variants of the submitting user
+	User string `json:"user,omitempty"`
+}
+
+// PromptsConfig represents the prompt template configuration
+type PromptsConfig struct {
+	// Dir holds the templates as <name>/<version>.tmpl; empty uses the built-in templates
+	Dir string
+	// Versions pins prompts to a version; other prompts use their latest version
+	Versions map[string]string
+}
+
+// ExperimentsConfig represents the prompt and model experiment configuration
+type ExperimentsConfig struct {
+	// File lists the experiments as JSON; empty runs none
+	File string
+	// StoreDir keeps outcomes and feedback on disk; empty keeps them in memory
+	StoreDir string
+}
+
+// GuardConfig represents the configuration of the smart chat question guard
+type GuardConfig struct {
+	Enabled bool
+	// RulesFile adds JSON rules to the built-in ones; empty uses only the built-in rules
+	RulesFile string
+	// ModelCheck asks the model to classify the questions the rules let through
+	ModelCheck bool
+}
+
+// Refusal tells why the smart chat declined a question instead of answering it
+type Refusal struct {
+	// Category is off_topic or injection
+	Category string `json:"category"`
+	// Reason is the code of the rule or model verdict, e.g. sql_command
+	Reason string `json:"reason"`
+}
+
+// RedactionConfig represents the masking of personal data in smart chat answers
+type RedactionConfig struct {
+	Enabled bool
+	// FullAccessRoles see personal data unmasked, e.g. auditors; the role must come from an API key
+	FullAccessRoles []string
+}
+
+// APIKey authenticates a caller and the role it acts in
+type APIKey struct {
+	Key    string
+	Caller string
+	Role   string
+}
+
+// DocumentsConfig represents the policy document retrieval configuration
+type DocumentsConfig struct {
+	// Dir holds the Markdown, text and PDF documents; empty disables retrieval
+	Dir string
+	// IndexDir keeps the index on disk between restarts; empty rebuilds it on startup
+	IndexDir     string
+	ChunkWords   int
+	ChunkOverlap int
+	// TopK is how many passages are retrieved per question
+	TopK int
+	// MinScore is the BM25 score a passage needs to be used
+	MinScore float64
+}
+
+// Citation is a document passage an answer was grounded on; Ref is its [n] marker in the answer
+type Citation struct {
+	Ref      int     `json:"ref"`
+	Document string  `json:"document"`
+	Title    string  `json:"title"`
+	Section  string  `json:"section,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+// FeedbackRequest rates a smart chat answer given while experiments run
+type FeedbackRequest struct {
+	RequestID string `json:"request_id"`
+	Helpful   *bool  `json:"helpful"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// ActivatePromptRequest represents a request to switch the active version of a prompt
+type ActivatePromptRequest struct {
+	Version string `json:"version"`
+}
+
+// CacheConfig represents the response cache configuration
+type CacheConfig struct {
+	// Backend selects the cache implementation: memory or none
+	Backend    string
+	MaxEntries int
+	// TTLs per endpoint; 0 disables caching for the endpoint
+	ChatTTL      time.Duration
+	SmartChatTTL time.Duration
+}
+
+// UsageConfig represents the token usage accounting configuration
+type UsageConfig struct {
+	// StoreDir keeps usage records on disk; empty keeps them in memory
+	StoreDir string
+	// Prices override the built-in price table
+	Prices []ModelPrice
+}
+
+// ModelPrice is the price of a model in USD per million tokens. Model matches by prefix.
+type ModelPrice struct {
+	Model  string
+	Input  float64
+	Output float64
+}
+
+// PromptBudget is the token breakdown of a prompt against the model's context window
+type PromptBudget struct {
+	Model              string `json:"model"`
+	ContextWindow      int    `json:"context_window"`
+	ReservedCompletion int    `json:"reserved_completion"`
+	SystemPrompt       int    `json:"system_prompt"`
+	History            int    `json:"history"`
+	Data               int    `json:"data"`
+	UserMessage        int    `json:"user_message"`
+	Overhead           int    `json:"overhead"`
+	Total              int    `json:"total"`
+	Remaining          int    `json:"remaining"`
+	DroppedMessages    int    `json:"dropped_messages,omitempty"`
+	DroppedRecords     int    `json:"dropped_records,omitempty"`
 }
 
 // Usage represents OpenAI API usage information
@@ -46,6 +237,8 @@ type ErrorResponse struct {
 	Error   bool   `json:"error"`
 	Message string `json:"message"`
 	Code    int    `json:"code,omitempty"`
+	// Stage is the smart chat stage that failed, when known
+	Stage string `json:"stage,omitempty"`
 }
 
 // SuccessResponse represents a success response
@@ -61,10 +254,203 @@ type SupabaseConfig struct {
 	APIKey string
 }
 
+// BatchConfig contains batch processing configurations
+type BatchConfig struct {
+	Workers     int
+	ItemTimeout time.Duration
+	MaxItems    int
+}
+
+// WebSocketConfig contains WebSocket connection configurations
+type WebSocketConfig struct {
+	// MaxInFlight bounds the requests running at once on a connection; 0 disables the bound
+	MaxInFlight int
+}
+
+// ConversationsConfig bounds the in-memory conversation transcripts
+type ConversationsConfig struct {
+	// MaxConversations evicts the least recently updated conversations beyond it; 0 means no bound
+	MaxConversations int
+	// TTL expires conversations idle for longer; 0 means never
+	TTL time.Duration
+}
+
+// JobsConfig contains asynchronous job configurations
+type JobsConfig struct {
+	Workers   int
+	QueueSize int
+	Timeout   time.Duration
+	StoreDir  string
+	// MaxFinished and Retention bound the finished jobs kept in the store; 0 disables each bound
+	MaxFinished int
+	Retention   time.Duration
+}
+
+// WebhookConfig contains job completion callback configurations
+type WebhookConfig struct {
+	Secret         string
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	// AllowedHosts may receive callbacks even when they resolve to internal addresses
+	AllowedHosts []string
+}
+
+// LLMConfig selects and configures the chat completion provider
+type LLMConfig struct {
+	Provider        string
+	APIKey          string
+	BaseURL         string
+	APIVersion      string
+	AzureDeployment string
+	FakeScript      string
+	// ContextWindow overrides the context window of the configured models; 0 uses the known size
+	ContextWindow int
+	// Fallbacks are tried in order when the primary model fails with a 5xx, timeout or rate limit
+	Fallbacks []LLMFallback
+}
+
+// LLMFallback is a model, optionally on another provider, used when the previous one fails
+type LLMFallback struct {
+	LLMConfig
+	// Model replaces the requested model; empty keeps it
+	Model string
+}
+
+// HTTPClientConfig contains the connection settings of an upstream HTTP client
+type HTTPClientConfig struct {
+	Timeout             time.Duration
+	DialTimeout         time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	ProxyURL            string
+	CAFile              string
+	Retry               RetryConfig
+}
+
+// RetryConfig contains the retry policy of an upstream HTTP client
+type RetryConfig struct {
+	// MaxAttempts includes the first attempt; 1 disables retries
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Deadline bounds all attempts of a request, including waits
+	Deadline time.Duration
+	// NonIdempotent allows retrying POST and PATCH requests without an Idempotency-Key
+	NonIdempotent bool
+}
+
+// SmartChatConfig contains smart chat pipeline configurations
+type SmartChatConfig struct {
+	// Mode selects how the model accesses data: "sql" (generated SQL text) or "tools" (function calling)
+	Mode string
+	// QueryTimeout bounds the database query stage
+	QueryTimeout time.Duration
+	// Model settings of each stage
+	Classification StageConfig
+	SQLGeneration  StageConfig
+	DataNarration  StageConfig
+	GeneralAnswer  StageConfig
+	// Guard classifies questions when GuardConfig.ModelCheck is set
+	Guard StageConfig
+}
+
+// StageConfig contains the model settings of a smart chat stage
+type StageConfig struct {
+	Model       string
+	MaxTokens   int
+	Temperature float32
+	Timeout     time.Duration
+}
+
 // OpenAIConfig contains OpenAI configurations
 type OpenAIConfig struct {
 	APIKey      string
 	Model       string
 	MaxTokens   int
 	Temperature float32
-}
\ No newline at end of file
+}
+
+// ChatConfig contains the server system prompt of /chat and the limits of client conversations
+type ChatConfig struct {
+	// SystemPrompt is the server system prompt; empty means none
+	SystemPrompt string
+	// SystemPromptPolicy decides what happens to client system messages:
+	// prepend (server prompt first, client ones kept), replace (client ones dropped) or forbid (rejected)
+	SystemPromptPolicy string
+	MaxMessages        int
+	MaxMessageChars    int
+}
+
+// GenerationConfig limits the generation parameters clients may set per request
+type GenerationConfig struct {
+	// AllowedModels are the models clients may request besides the default model
+	AllowedModels []string
+	// MaxTokens caps max_tokens; 0 means no cap
+	MaxTokens      int
+	TemperatureMin float32
+	TemperatureMax float32
+	TopPMin        float32
+	TopPMax        float32
+}
+
+// WSRequest represents a message sent by a WebSocket client
+type WSRequest struct {
+	Type string `json:"type"`
+	ChatRequest
+}
+
+// WSEvent represents a message sent by the server over a WebSocket connection
+type WSEvent struct {
+	Type           string      `json:"type"`
+	ConversationID string      `json:"conversation_id,omitempty"`
+	Delta          string      `json:"delta,omitempty"`
+	Data           interface{} `json:"data,omitempty"`
+	Message        string      `json:"message,omitempty"`
+}
+
+// QuestionAnalysis is the structured output of the smart chat analysis stage
+type QuestionAnalysis struct {
+	Intent        string   `json:"intent"`
+	NeedsDatabase bool     `json:"needs_database"`
+	SQL           string   `json:"sql"`
+	TablesUsed    []string `json:"tables_used"`
+	Confidence    float64  `json:"confidence"`
+	Clarification string   `json:"clarification"`
+}
+
+// TableQuery is a typed, read-only table query requested by the model through function calling
+type TableQuery struct {
+	Table      string           `json:"table"`
+	Columns    []string         `json:"columns,omitempty"`
+	Filters    []QueryFilter    `json:"filters,omitempty"`
+	Order      []QueryOrder     `json:"order,omitempty"`
+	Limit      int              `json:"limit,omitempty"`
+	Aggregates []QueryAggregate `json:"aggregates,omitempty"`
+}
+
+// QueryFilter restricts the rows of a TableQuery
+type QueryFilter struct {
+	Column   string      `json:"column"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// QueryOrder sorts the rows of a TableQuery
+type QueryOrder struct {
+	Column    string `json:"column"`
+	Direction string `json:"direction,omitempty"`
+}
+
+// QueryAggregate computes an aggregate over a column of a TableQuery
+type QueryAggregate struct {
+	Function string `json:"function"`
+	Column   string `json:"column,omitempty"`
+}
+
+// TableSchema describes a database table exposed to the assistant
+type TableSchema struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Columns     []string `json:"columns"`
+}
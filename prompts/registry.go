@@ -0,0 +1,327 @@
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"credibot-api/models"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Prompt names, one per template directory
+const (
+	Analysis       = "analysis"
+	Classification = "classification"
+	SQLGeneration  = "sql_generation"
+	ToolsRouting   = "tools_routing"
+	Narration      = "narration"
+	General        = "general"
+	Policy         = "policy"
+	Guard          = "guard"
+)
+
+// examplesFile holds the few-shot examples of each prompt
+const examplesFile = "examples.json"
+
+//go:embed templates
+var embedded embed.FS
+
+// Data is what templates can use. Date and Examples are filled in by the registry.
+type Data struct {
+	// Schema lists the credit tables
+	Schema []models.TableSchema
+	// Tables are the tables a question likely needs
+	Tables []string
+	// Date is today's date, DD/MM/YYYY
+	Date string
+	// Examples are the few-shot examples of the prompt
+	Examples []Example
+}
+
+// Example is a question and the expected answer
+type Example struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// Info describes the versions of a prompt
+type Info struct {
+	Name     string   `json:"name"`
+	Active   string   `json:"active"`
+	Versions []string `json:"versions"`
+}
+
+// ErrUnknownVersion is returned when activating a version that was not loaded
+var ErrUnknownVersion = errors.New("unknown prompt version")
+
+// prompt is a prompt template and its versions
+type prompt struct {
+	versions map[string]*template.Template
+	active   string
+	// activated is set when the active version was chosen at runtime
+	activated bool
+}
+
+// Registry holds the versioned prompt templates. Templates are read from <dir>/<name>/<version>.tmpl;
+// without a directory the templates built into the binary are used.
+type Registry struct {
+	source fs.FS
+	pinned map[string]string
+
+	mu       sync.RWMutex
+	prompts  map[string]*prompt
+	examples map[string][]Example
+}
+
+// Load reads the templates from dir, or the built-in ones when dir is empty. Pinned maps
+// prompt names to the version to activate; other prompts activate their latest version.
+func Load(dir string, pinned map[string]string) (*Registry, error) {
+	var source fs.FS
+	if dir == "" {
+		sub, err := fs.Sub(embedded, "templates")
+		if err != nil {
+			return nil, err
+		}
+		source = sub
+	} else {
+		source = os.DirFS(dir)
+	}
+
+	r := &Registry{source: source, pinned: pinned}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads every template again. On error the templates in use are kept.
+// Versions activated at runtime stay active when they still exist.
+func (r *Registry) Reload() error {
+	entries, err := fs.ReadDir(r.source, ".")
+	if err != nil {
+		return fmt.Errorf("failed to read prompt templates: %w", err)
+	}
+
+	r.mu.RLock()
+	previous := r.prompts
+	r.mu.RUnlock()
+
+	loaded := make(map[string]*prompt)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		p, err := r.loadPrompt(entry.Name())
+		if err != nil {
+			return err
+		}
+		if old, ok := previous[entry.Name()]; ok && old.activated && p.versions[old.active] != nil {
+			p.active, p.activated = old.active, true
+		}
+		loaded[entry.Name()] = p
+	}
+	for name, version := range r.pinned {
+		if p, ok := loaded[name]; !ok || p.versions[version] == nil {
+			return fmt.Errorf("pinned prompt %s@%s not found", name, version)
+		}
+	}
+
+	examples := make(map[string][]Example)
+	if data, err := fs.ReadFile(r.source, examplesFile); err == nil {
+		if err := json.Unmarshal(data, &examples); err != nil {
+			return fmt.Errorf("failed to read %s: %w", examplesFile, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	r.mu.Lock()
+	r.prompts = loaded
+	r.examples = examples
+	r.mu.Unlock()
+	return nil
+}
+
+// loadPrompt parses every version of a prompt and picks the one to activate
+func (r *Registry) loadPrompt(name string) (*prompt, error) {
+	files, err := fs.Glob(r.source, path.Join(name, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("prompt %s has no versions", name)
+	}
+
+	p := &prompt{versions: make(map[string]*template.Template)}
+	for _, file := range files {
+		text, err := fs.ReadFile(r.source, file)
+		if err != nil {
+			return nil, err
+		}
+		version := strings.TrimSuffix(path.Base(file), ".tmpl")
+		tmpl, err := template.New(name + "@" + version).
+			Funcs(template.FuncMap{"join": strings.Join}).
+			Option("missingkey=error").
+			Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt %s@%s: %w", name, version, err)
+		}
+		p.versions[version] = tmpl
+	}
+
+	versions := sortedVersions(p.versions)
+	p.active = versions[len(versions)-1]
+	if version, ok := r.pinned[name]; ok {
+		p.active = version
+	}
+	return p, nil
+}
+
+// Render executes the active version of a prompt, or the version chosen with WithVersions,
+// recording the version in the context trace
+func (r *Registry) Render(ctx context.Context, name string, data Data) (string, error) {
+	r.mu.RLock()
+	p, ok := r.prompts[name]
+	var tmpl *template.Template
+	var version string
+	if ok {
+		version = p.active
+		if override, found := versionsFrom(ctx)[name]; found {
+			version = override
+		}
+		tmpl = p.versions[version]
+	}
+	data.Examples = r.examples[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("unknown prompt: %s", name)
+	}
+	if tmpl == nil {
+		return "", fmt.Errorf("%w: %s@%s", ErrUnknownVersion, name, version)
+	}
+
+	data.Date = time.Now().Format("02/01/2006")
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s@%s: %w", name, version, err)
+	}
+
+	if trace, ok := ctx.Value(traceKey{}).(*Trace); ok {
+		trace.record(name, version)
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// Activate switches a prompt to one of its loaded versions
+func (r *Registry) Activate(name, version string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.prompts[name]
+	if !ok {
+		return fmt.Errorf("unknown prompt: %s", name)
+	}
+	if p.versions[version] == nil {
+		return fmt.Errorf("%w: %s@%s", ErrUnknownVersion, name, version)
+	}
+	p.active, p.activated = version, true
+	return nil
+}
+
+// Has reports whether a version of a prompt is loaded
+func (r *Registry) Has(name, version string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.prompts[name]
+	return ok && p.versions[version] != nil
+}
+
+// List describes every prompt, sorted by name
+func (r *Registry) List() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Info, 0, len(r.prompts))
+	for name, p := range r.prompts {
+		list = append(list, Info{Name: name, Active: p.active, Versions: sortedVersions(p.versions)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// sortedVersions orders versions oldest first, comparing "v<N>" numerically
+func sortedVersions(versions map[string]*template.Template) []string {
+	list := make([]string, 0, len(versions))
+	for version := range versions {
+		list = append(list, version)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, aErr := strconv.Atoi(strings.TrimPrefix(list[i], "v"))
+		b, bErr := strconv.Atoi(strings.TrimPrefix(list[j], "v"))
+		if aErr == nil && bErr == nil {
+			return a < b
+		}
+		return list[i] < list[j]
+	})
+	return list
+}
+
+type traceKey struct{}
+type versionsKey struct{}
+
+// WithVersions makes the renders made with the context use the given version of some
+// prompts instead of the active one, e.g. for the variant of an experiment
+func WithVersions(ctx context.Context, versions map[string]string) context.Context {
+	return context.WithValue(ctx, versionsKey{}, versions)
+}
+
+func versionsFrom(ctx context.Context) map[string]string {
+	versions, _ := ctx.Value(versionsKey{}).(map[string]string)
+	return versions
+}
+
+// Trace collects the prompt versions used by a request
+type Trace struct {
+	mu       sync.Mutex
+	versions map[string]string
+}
+
+// WithTrace attaches a trace to the context that every render records to
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	trace := &Trace{versions: make(map[string]string)}
+	return context.WithValue(ctx, traceKey{}, trace), trace
+}
+
+// Versions returns the version of each prompt rendered so far, or nil when none was
+func (t *Trace) Versions() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.versions) == 0 {
+		return nil
+	}
+	versions := make(map[string]string, len(t.versions))
+	for name, version := range t.versions {
+		versions[name] = version
+	}
+	return versions
+}
+
+func (t *Trace) record(name, version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.versions[name] = version
+}
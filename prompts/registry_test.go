@@ -0,0 +1,225 @@
+package prompts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// loadTemplates loads a new directory holding the given files
+func loadTemplates(t *testing.T, files map[string]string, pinned map[string]string) (*Registry, string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTemplates(t, dir, files)
+	r, err := Load(dir, pinned)
+	return r, dir, err
+}
+
+// writeTemplates writes files given by their path relative to dir
+func writeTemplates(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// general renders the general prompt, failing the test on error
+func general(t *testing.T, r *Registry, ctx context.Context) string {
+	t.Helper()
+	text, err := r.Render(ctx, General, Data{})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	return text
+}
+
+func TestTheLatestVersionIsActiveUnlessPinned(t *testing.T) {
+	files := map[string]string{"general/v2.tmpl": "two", "general/v10.tmpl": "ten", "general/v9.tmpl": "nine"}
+
+	r, _, err := loadTemplates(t, files, nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := general(t, r, context.Background()); got != "ten" {
+		t.Errorf("latest version rendered %q, want v10", got)
+	}
+
+	r, _, err = loadTemplates(t, files, map[string]string{General: "v2"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := general(t, r, context.Background()); got != "two" {
+		t.Errorf("pinned version rendered %q, want v2", got)
+	}
+
+	if _, _, err := loadTemplates(t, files, map[string]string{General: "v3"}); err == nil {
+		t.Error("Load succeeded with a pinned version that does not exist")
+	}
+}
+
+func TestTemplatesSeeTheExamplesAndDate(t *testing.T) {
+	r, _, err := loadTemplates(t, map[string]string{
+		"general/v1.tmpl": "{{.Date}}{{range .Examples}} {{.Question}}={{.Answer}}{{end}}",
+		examplesFile:      `{"general": [{"question": "q1", "answer": "a1"}, {"question": "q2", "answer": "a2"}]}`,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := general(t, r, context.Background()), time.Now().Format("02/01/2006")+" q1=a1 q2=a2"; got != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+}
+
+func TestLoadRejectsBrokenTemplates(t *testing.T) {
+	for _, files := range []map[string]string{
+		{"general/README": "a prompt without versions"},
+		{"general/v1.tmpl": "{{.Missing"},
+		{"general/v1.tmpl": "one", examplesFile: "["},
+	} {
+		if _, _, err := loadTemplates(t, files, nil); err == nil {
+			t.Errorf("Load(%v) succeeded", files)
+		}
+	}
+}
+
+func TestBuiltInPromptsRender(t *testing.T) {
+	r, err := Load("", nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, name := range []string{Analysis, Classification, SQLGeneration, ToolsRouting, Narration, General, Policy, Guard} {
+		if _, err := r.Render(context.Background(), name, Data{}); err != nil {
+			t.Errorf("Render(%s): %v", name, err)
+		}
+	}
+}
+
+func TestActivateSwitchesTheVersionInUse(t *testing.T) {
+	r, _, err := loadTemplates(t, map[string]string{"general/v1.tmpl": "one", "general/v2.tmpl": "two"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Activate(General, "v1"); err != nil {
+		t.Fatalf("Activate: %v", err)
+	}
+	if got := general(t, r, context.Background()); got != "one" {
+		t.Errorf("rendered %q after activating v1", got)
+	}
+
+	if err := r.Activate(General, "v3"); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("activating an unknown version: error %v, want %v", err, ErrUnknownVersion)
+	}
+	if err := r.Activate("missing", "v1"); err == nil {
+		t.Error("activating an unknown prompt succeeded")
+	}
+	if got := general(t, r, context.Background()); got != "one" {
+		t.Errorf("a failed activation changed the version in use: rendered %q", got)
+	}
+}
+
+func TestReloadPicksUpChangedTemplates(t *testing.T) {
+	r, dir, err := loadTemplates(t, map[string]string{"general/v1.tmpl": "one", "general/v2.tmpl": "two"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTemplates(t, dir, map[string]string{"general/v2.tmpl": "two, edited"})
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := general(t, r, context.Background()); got != "two, edited" {
+		t.Errorf("rendered %q after editing v2", got)
+	}
+
+	writeTemplates(t, dir, map[string]string{"general/v3.tmpl": "three"})
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := general(t, r, context.Background()); got != "three" {
+		t.Errorf("rendered %q after adding v3", got)
+	}
+}
+
+func TestReloadKeepsTheVersionActivatedAtRuntime(t *testing.T) {
+	r, dir, err := loadTemplates(t, map[string]string{"general/v1.tmpl": "one", "general/v2.tmpl": "two"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Activate(General, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTemplates(t, dir, map[string]string{"general/v3.tmpl": "three"})
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := general(t, r, context.Background()); got != "one" {
+		t.Errorf("rendered %q, want the activated v1", got)
+	}
+
+	// Once removed, the latest version takes over
+	if err := os.Remove(filepath.Join(dir, "general", "v1.tmpl")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := general(t, r, context.Background()); got != "three" {
+		t.Errorf("rendered %q after removing v1, want v3", got)
+	}
+}
+
+func TestFailedReloadKeepsTheTemplatesInUse(t *testing.T) {
+	r, dir, err := loadTemplates(t, map[string]string{"general/v1.tmpl": "one"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTemplates(t, dir, map[string]string{"general/v1.tmpl": "edited", "general/v2.tmpl": "{{if}}"})
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload of a broken template succeeded")
+	}
+	if got := general(t, r, context.Background()); got != "one" {
+		t.Errorf("rendered %q, want the templates loaded before", got)
+	}
+}
+
+func TestRendersRecordTheVersionUsed(t *testing.T) {
+	r, _, err := loadTemplates(t, map[string]string{
+		"general/v1.tmpl": "one", "general/v2.tmpl": "two",
+		"policy/v1.tmpl": "policy",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, trace := WithTrace(context.Background())
+	if versions := trace.Versions(); versions != nil {
+		t.Errorf("versions before any render = %v, want none", versions)
+	}
+
+	// A version chosen for the request, as by an experiment, replaces the active one
+	if got := general(t, r, WithVersions(ctx, map[string]string{General: "v1"})); got != "one" {
+		t.Errorf("rendered %q, want the chosen v1", got)
+	}
+	if _, err := r.Render(ctx, Policy, Data{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := trace.Versions(); len(got) != 2 || got[General] != "v1" || got[Policy] != "v1" {
+		t.Errorf("versions = %v, want general and policy at v1", got)
+	}
+
+	if _, err := r.Render(WithVersions(ctx, map[string]string{General: "v9"}), General, Data{}); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("rendering an unknown version: error %v, want %v", err, ErrUnknownVersion)
+	}
+}
@@ -0,0 +1,230 @@
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Patterns of the personal data found in answers. Formatted CPFs and CNPJs are masked as
+// they are; bare digit runs only when their check digits are valid.
+var (
+	emailPattern         = regexp.MustCompile(`\b([A-Za-z0-9._%+-]+)@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+\b`)
+	cnpjPattern          = regexp.MustCompile(`\b\d{2}\.\d{3}\.\d{3}/\d{4}-\d{2}\b|\b\d{14}\b`)
+	cpfPattern           = regexp.MustCompile(`\b\d{3}\.\d{3}\.\d{3}-\d{2}\b|\b\d{11}\b`)
+	bankAccountPattern   = regexp.MustCompile(`(?i)\b(?:conta(?:\s+corrente|\s+poupan[çc]a)?|c/c)\s*(?:n[º°o]\.?\s*)?:?\s*(\d[\d.]{2,14}(?:-[\dxX])?)`)
+	phoneWithAreaPattern = regexp.MustCompile(`(?:\+55\s?)?(?:\(\d{2}\)\s?|\b\d{2}\s)(\d{4,5}-?\d{4})\b`)
+	mobilePattern        = regexp.MustCompile(`\b(9\d{4}-\d{4})\b`)
+)
+
+// Redactor masks CPFs, CNPJs, emails, bank accounts, phone numbers and the full names of a
+// data set. Masks keep the length of the text, replacing each hidden character with '*'.
+type Redactor struct {
+	names *regexp.Regexp
+}
+
+// New creates a redactor that also masks the given full names; single words are ignored,
+// since first names alone are too ambiguous to mask
+func New(names []string) *Redactor {
+	var full []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		if len(strings.Fields(name)) < 2 || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		full = append(full, name)
+	}
+
+	r := &Redactor{}
+	if len(full) > 0 {
+		// Longer names first, so "Ana Maria Souza" is not masked as "Ana Maria"
+		sort.Slice(full, func(i, j int) bool { return len(full[i]) > len(full[j]) })
+		quoted := make([]string, len(full))
+		for i, name := range full {
+			quoted[i] = strings.ReplaceAll(regexp.QuoteMeta(name), " ", `\s+`)
+		}
+		r.names = regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	}
+	return r
+}
+
+// Redact masks the personal data of a text
+func (r *Redactor) Redact(text string) string {
+	text = replaceGroup(text, emailPattern, 1, keepFirst)
+	text = cnpjPattern.ReplaceAllStringFunc(text, func(s string) string {
+		if len(s) == 14 && !validCNPJ(s) {
+			return s
+		}
+		// **.345.678/****-**
+		return maskDigits(s, func(i int) bool { return i < 2 || i >= 8 })
+	})
+	text = cpfPattern.ReplaceAllStringFunc(text, func(s string) string {
+		if len(s) == 11 && !validCPF(s) {
+			return s
+		}
+		// ***.456.789-**
+		return maskDigits(s, func(i int) bool { return i < 3 || i >= 9 })
+	})
+	text = replaceGroup(text, bankAccountPattern, 1, keepLast(2))
+	text = replaceGroup(text, phoneWithAreaPattern, 1, keepLast(4))
+	text = replaceGroup(text, mobilePattern, 1, keepLast(4))
+	if r.names != nil {
+		text = r.maskNames(text)
+	}
+	return text
+}
+
+// MaskName masks every word of a name but its initial, e.g. a name column of a query result
+func MaskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		words[i] = keepFirst(word)
+	}
+	return rejoin(name, words)
+}
+
+// maskNames masks the data set names found as whole words, keeping the initial of each word
+func (r *Redactor) maskNames(text string) string {
+	var out strings.Builder
+	last := 0
+	for _, match := range r.names.FindAllStringIndex(text, -1) {
+		start, end := match[0], match[1]
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		out.WriteString(text[last:start])
+		out.WriteString(MaskName(text[start:end]))
+		last = end
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// replaceGroup replaces a capture group of every match of a pattern
+func replaceGroup(text string, pattern *regexp.Regexp, group int, mask func(string) string) string {
+	var out strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[2*group], match[2*group+1]
+		if start < 0 {
+			continue
+		}
+		out.WriteString(text[last:start])
+		out.WriteString(mask(text[start:end]))
+		last = end
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// maskDigits masks the digits whose position among the digits of s is hidden
+func maskDigits(s string, hidden func(i int) bool) string {
+	digit := 0
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsDigit(r) {
+			return r
+		}
+		digit++
+		if hidden(digit - 1) {
+			return '*'
+		}
+		return r
+	}, s)
+}
+
+// keepLast returns a mask that hides every digit but the last n
+func keepLast(n int) func(string) string {
+	return func(s string) string {
+		total := 0
+		for _, r := range s {
+			if unicode.IsDigit(r) {
+				total++
+			}
+		}
+		return maskDigits(s, func(i int) bool { return i < total-n })
+	}
+}
+
+// keepFirst hides every character of a word but the first
+func keepFirst(word string) string {
+	first, size := utf8.DecodeRuneInString(word)
+	if size == 0 {
+		return word
+	}
+	return string(first) + strings.Repeat("*", utf8.RuneCountInString(word)-1)
+}
+
+// rejoin puts masked words back with the original whitespace between them
+func rejoin(original string, words []string) string {
+	var out strings.Builder
+	i := 0
+	inWord := false
+	for _, r := range original {
+		if unicode.IsSpace(r) {
+			out.WriteRune(r)
+			inWord = false
+			continue
+		}
+		if !inWord {
+			out.WriteString(words[i])
+			i++
+			inWord = true
+		}
+	}
+	return out.String()
+}
+
+// validCPF checks the two check digits of an 11-digit CPF
+func validCPF(digits string) bool {
+	if allSame(digits) {
+		return false
+	}
+	return checkDigit(digits[:9], 10) == digits[9] && checkDigit(digits[:10], 11) == digits[10]
+}
+
+// checkDigit computes a CPF check digit with weights counting down from weight
+func checkDigit(digits string, weight int) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		sum += int(digits[i]-'0') * (weight - i)
+	}
+	rest := sum * 10 % 11
+	if rest == 10 {
+		rest = 0
+	}
+	return byte('0' + rest)
+}
+
+// validCNPJ checks the two check digits of a 14-digit CNPJ
+func validCNPJ(digits string) bool {
+	if allSame(digits) {
+		return false
+	}
+	weights := []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	check := func(n int) byte {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(digits[i]-'0') * weights[len(weights)-n+i]
+		}
+		rest := sum % 11
+		if rest < 2 {
+			return '0'
+		}
+		return byte('0' + 11 - rest)
+	}
+	return check(12) == digits[12] && check(13) == digits[13]
+}
+
+func allSame(digits string) bool {
+	return strings.Count(digits, digits[:1]) == len(digits)
+}
@@ -0,0 +1,82 @@
+package redact
+
+import "testing"
+
+// assertRedacts checks the redacted form of each text
+func assertRedacts(t *testing.T, r *Redactor, want map[string]string) {
+	t.Helper()
+	for text, redacted := range want {
+		if got := r.Redact(text); got != redacted {
+			t.Errorf("Redact(%q) = %q, want %q", text, got, redacted)
+		}
+	}
+}
+
+func TestRedactDocuments(t *testing.T) {
+	assertRedacts(t, New(nil), map[string]string{
+		"CPF 529.982.247-25.":     "CPF ***.982.247-**.",
+		"CPF 52998224725":         "CPF ***982247**",
+		"CNPJ 11.222.333/0001-81": "CNPJ **.222.333/****-**",
+		"CNPJ 11222333000181":     "CNPJ **222333******",
+		// Bare digit runs whose check digits fail are order numbers, protocols and the like
+		"pedido 52998224724":       "pedido 52998224724",
+		"código 11111111111":       "código 11111111111",
+		"protocolo 11222333000180": "protocolo 11222333000180",
+	})
+}
+
+func TestRedactContacts(t *testing.T) {
+	assertRedacts(t, New(nil), map[string]string{
+		"contato joao.silva@exemplo.com.br": "contato j*********@exemplo.com.br",
+		"tel (11) 98765-4321":               "tel (11) *****-4321",
+		"cel 98765-4321":                    "cel *****-4321",
+		"conta corrente 12345-6":            "conta corrente ****5-6",
+		"Score médio de 720":                "Score médio de 720",
+	})
+}
+
+func TestRedactNames(t *testing.T) {
+	r := New([]string{"João da Silva", "Maria", "Ana Maria", "Ana Maria Souza", "joão  da silva"})
+	assertRedacts(t, r, map[string]string{
+		"Cliente João da Silva aprovado": "Cliente J*** d* S**** aprovado",
+		"JOÃO DA\nSILVA":                 "J*** D*\nS****",
+		// The longest name wins, so no initial of a shorter one is left readable
+		"Ana Maria Souza pagou": "A** M**** S**** pagou",
+		"Ana Maria pagou":       "A** M**** pagou",
+		// First names alone are too ambiguous to mask
+		"Maria pagou":    "Maria pagou",
+		"XJoão da Silva": "XJoão da Silva",
+	})
+}
+
+func TestMaskName(t *testing.T) {
+	if got, want := MaskName("Ana  Maria\tSouza"), "A**  M****\tS****"; got != want {
+		t.Errorf("MaskName = %q, want %q", got, want)
+	}
+	if got := MaskName(""); got != "" {
+		t.Errorf("MaskName of an empty name = %q", got)
+	}
+}
+
+func TestCheckDigits(t *testing.T) {
+	for _, cpf := range []string{"52998224725", "12345678909"} {
+		if !validCPF(cpf) {
+			t.Errorf("CPF %s rejected", cpf)
+		}
+	}
+	for _, cpf := range []string{"52998224715", "52998224724", "00000000000"} {
+		if validCPF(cpf) {
+			t.Errorf("CPF %s accepted", cpf)
+		}
+	}
+	for _, cnpj := range []string{"11222333000181", "45997418000153"} {
+		if !validCNPJ(cnpj) {
+			t.Errorf("CNPJ %s rejected", cnpj)
+		}
+	}
+	for _, cnpj := range []string{"11222333000191", "11222333000182", "22222222222222"} {
+		if validCNPJ(cnpj) {
+			t.Errorf("CNPJ %s accepted", cnpj)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package redact
+
+import "strings"
+
+// holdback is how many characters at the end of a stream are held until more text arrives,
+// since they could still be the start of personal data. It is longer than any masked value.
+const holdback = 64
+
+// Stream redacts text streamed in pieces. Each piece is redacted together with the text
+// before it, and only the part that later pieces can no longer change is emitted.
+type Stream struct {
+	redactor *Redactor
+	emit     func(string)
+	text     strings.Builder
+	sent     int
+}
+
+// Stream returns a stream that emits the redacted text
+func (r *Redactor) Stream(emit func(string)) *Stream {
+	return &Stream{redactor: r, emit: emit}
+}
+
+// Write adds a piece of text, emitting the redacted text that is now settled
+func (s *Stream) Write(piece string) {
+	s.text.WriteString(piece)
+	redacted := []rune(s.redactor.Redact(s.text.String()))
+	if settled := len(redacted) - holdback; settled > s.sent {
+		s.emit(string(redacted[s.sent:settled]))
+		s.sent = settled
+	}
+}
+
+// Close emits the rest of the redacted text once the stream ended with text
+func (s *Stream) Close(text string) {
+	redacted := []rune(s.redactor.Redact(text))
+	if len(redacted) > s.sent {
+		s.emit(string(redacted[s.sent:]))
+		s.sent = len(redacted)
+	}
+}
@@ -0,0 +1,41 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestStream feeds each text in pieces of every size, so personal data is split at every
+// position, and checks that nothing emitted ever has to be taken back
+func TestStream(t *testing.T) {
+	r := New([]string{"João da Silva"})
+	texts := []string{
+		"O cliente João da Silva tem CPF 529.982.247-25 e score 720.",
+		"Contato: joao.silva@exemplo.com.br, tel (11) 98765-4321",
+		"CNPJ 11222333000181 aprovado",
+		// Shorter than what the stream holds back
+		"CPF 52998224725",
+		strings.Repeat("Score médio de 720. ", 10),
+	}
+
+	for _, text := range texts {
+		want := r.Redact(text)
+		for size := 1; size <= len(text); size++ {
+			var emitted strings.Builder
+			stream := r.Stream(func(piece string) {
+				emitted.WriteString(piece)
+				if !strings.HasPrefix(want, emitted.String()) {
+					t.Fatalf("pieces of %d: emitted %q, not a prefix of %q", size, emitted.String(), want)
+				}
+			})
+			for start := 0; start < len(text); start += size {
+				stream.Write(text[start:min(start+size, len(text))])
+			}
+			stream.Close(text)
+
+			if emitted.String() != want {
+				t.Errorf("pieces of %d: emitted %q, want %q", size, emitted.String(), want)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package tokens
+
+import (
+	"credibot-api/models"
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrContextExceeded is returned when the fixed parts of a prompt do not fit the context window
+var ErrContextExceeded = errors.New("prompt exceeds the model context window")
+
+// Budget tracks how a prompt uses a model's context window. Fixed sections (system
+// prompt, user message) are added first; history and data are then trimmed to fit.
+type Budget struct {
+	model  string
+	report models.PromptBudget
+}
+
+// NewBudget creates a budget for a model, keeping reserved tokens for the completion
+func NewBudget(model string, window, reserved int) *Budget {
+	return &Budget{
+		model: model,
+		report: models.PromptBudget{
+			Model:              model,
+			ContextWindow:      window,
+			ReservedCompletion: reserved,
+			Overhead:           tokensPerReply,
+		},
+	}
+}
+
+// Remaining returns the tokens still available for the prompt
+func (b *Budget) Remaining() int {
+	return b.report.ContextWindow - b.report.ReservedCompletion - b.used()
+}
+
+func (b *Budget) used() int {
+	r := b.report
+	return r.SystemPrompt + r.History + r.Data + r.UserMessage + r.Overhead
+}
+
+// AddSystem accounts for the system prompt, without any data it embeds
+func (b *Budget) AddSystem(text string) {
+	message := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: text}
+	b.report.SystemPrompt += CountMessage(b.model, message)
+}
+
+// AddUser accounts for the user message
+func (b *Budget) AddUser(text string) {
+	message := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: text}
+	b.report.UserMessage += CountMessage(b.model, message)
+}
+
+// FitHistory keeps the most recent messages that fit in the remaining budget
+func (b *Budget) FitHistory(history []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	start := len(history)
+	spent := 0
+	for start > 0 {
+		cost := CountMessage(b.model, history[start-1])
+		if spent+cost > b.Remaining() {
+			break
+		}
+		spent += cost
+		start--
+	}
+
+	b.report.History += spent
+	b.report.DroppedMessages += start
+	return history[start:]
+}
+
+// FitData renders the largest number of records (out of total) whose text fits in the
+// remaining budget. render(n) must return the data text with the first n records.
+func (b *Budget) FitData(total int, render func(n int) string) string {
+	// More records never take fewer tokens, so binary search the largest n that fits
+	low, high := 0, total
+	for low < high {
+		mid := (low + high + 1) / 2
+		if Count(b.model, render(mid)) <= b.Remaining() {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	text := render(low)
+	b.report.Data += Count(b.model, text)
+	b.report.DroppedRecords += total - low
+	return text
+}
+
+// Report returns the budget breakdown, or an error when the prompt cannot fit the window
+func (b *Budget) Report() (models.PromptBudget, error) {
+	report := b.report
+	report.Total = b.used()
+	report.Remaining = b.Remaining()
+	if report.Remaining < 0 {
+		return report, fmt.Errorf("%w: %d tokens needed, %d available in %s after reserving %d for the completion",
+			ErrContextExceeded, report.Total, report.ContextWindow-report.ReservedCompletion, b.model, report.ReservedCompletion)
+	}
+	return report, nil
+}
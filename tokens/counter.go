@@ -0,0 +1,105 @@
+package tokens
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Per-message overhead of the chat format, following OpenAI's token counting guide
+const (
+	tokensPerMessage = 3
+	tokensPerReply   = 3
+)
+
+// defaultEncoding approximates models unknown to the tokenizer, such as local models
+const defaultEncoding = tiktoken.MODEL_CL100K_BASE
+
+func init() {
+	// Vocabularies are embedded, so counting works without network access
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+var (
+	encodersMu sync.Mutex
+	encoders   = make(map[string]*tiktoken.Tiktoken)
+)
+
+// encoderFor returns the tokenizer of a model, or nil when none can be loaded
+func encoderFor(model string) *tiktoken.Tiktoken {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	if encoder, ok := encoders[model]; ok {
+		return encoder
+	}
+
+	encoder, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		encoder, err = tiktoken.GetEncoding(defaultEncoding)
+	}
+	if err != nil {
+		encoder = nil
+	}
+	encoders[model] = encoder
+	return encoder
+}
+
+// Count returns the number of tokens of text for a model
+func Count(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	if encoder := encoderFor(model); encoder != nil {
+		return len(encoder.EncodeOrdinary(text))
+	}
+	// Roughly four characters per token when no tokenizer is available
+	return (len(text) + 3) / 4
+}
+
+// CountMessage returns the tokens of a chat message, including its formatting overhead
+func CountMessage(model string, message openai.ChatCompletionMessage) int {
+	return tokensPerMessage + Count(model, message.Role) + Count(model, message.Content)
+}
+
+// CountPrompt returns the tokens of a list of messages, including the reply priming
+func CountPrompt(model string, messages []openai.ChatCompletionMessage) int {
+	total := tokensPerReply
+	for _, message := range messages {
+		total += CountMessage(model, message)
+	}
+	return total
+}
+
+// ContextWindow returns the context window of a model, in tokens
+func ContextWindow(model string) int {
+	for _, known := range contextWindows {
+		if strings.HasPrefix(model, known.prefix) {
+			return known.tokens
+		}
+	}
+	return defaultContextWindow
+}
+
+// defaultContextWindow is assumed for models not listed in contextWindows
+const defaultContextWindow = 8192
+
+// contextWindows lists known models by prefix; longer prefixes come first
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"gpt-4.1", 1047576},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo-instruct", 4096},
+	{"gpt-3.5-turbo", 16385},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+}
@@ -0,0 +1,138 @@
+package usage
+
+import (
+	"context"
+	"credibot-api/llm"
+	"credibot-api/tokens"
+	"errors"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Meter records the usage of every model call to a store
+type Meter struct {
+	store   Store
+	pricing Pricing
+}
+
+// NewMeter creates a meter writing to store
+func NewMeter(store Store, pricing Pricing) *Meter {
+	return &Meter{store: store, pricing: pricing}
+}
+
+// Wrap returns a provider that records the usage of the calls made through it
+func (m *Meter) Wrap(provider llm.Provider) llm.Provider {
+	return &meteredProvider{Provider: provider, meter: m}
+}
+
+// record prices a call, adds it to the request tally and stores it. Failing to store
+// usage never fails the call.
+func (m *Meter) record(ctx context.Context, model string, u openai.Usage, estimated bool) {
+	record := newRecord(ctx, model, u)
+	record.Estimated = estimated
+	record.Cost = m.pricing.Cost(model, record.PromptTokens, record.CompletionTokens)
+
+	if tally, ok := ctx.Value(tallyKey{}).(*Tally); ok {
+		tally.add(record)
+	}
+	if err := m.store.Add(record); err != nil {
+		log.Printf("Failed to record usage of %s: %v", model, err)
+	}
+}
+
+// estimate counts the tokens of a call locally, for providers that do not report usage
+func estimate(req openai.ChatCompletionRequest, completion string) openai.Usage {
+	prompt := tokens.CountPrompt(req.Model, req.Messages)
+	reply := tokens.Count(req.Model, completion)
+	return openai.Usage{PromptTokens: prompt, CompletionTokens: reply, TotalTokens: prompt + reply}
+}
+
+type meteredProvider struct {
+	llm.Provider
+	meter *Meter
+}
+
+func (p *meteredProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := p.Provider.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+	if resp.Usage.TotalTokens > 0 {
+		p.meter.record(ctx, model, resp.Usage, false)
+	} else {
+		var completion strings.Builder
+		for _, choice := range resp.Choices {
+			completion.WriteString(choice.Message.Content)
+		}
+		p.meter.record(ctx, model, estimate(req, completion.String()), true)
+	}
+	return resp, nil
+}
+
+func (p *meteredProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (llm.Stream, error) {
+	stream, err := p.Provider.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &meteredStream{Stream: stream, ctx: ctx, req: req, meter: p.meter, model: req.Model}, nil
+}
+
+// meteredStream records usage once the stream ends, or when it is closed early
+type meteredStream struct {
+	llm.Stream
+	ctx        context.Context
+	req        openai.ChatCompletionRequest
+	meter      *Meter
+	model      string
+	usage      *openai.Usage
+	completion strings.Builder
+	recorded   bool
+}
+
+func (s *meteredStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	chunk, err := s.Stream.Recv()
+	if errors.Is(err, io.EOF) {
+		s.finish()
+	}
+	if err != nil {
+		return chunk, err
+	}
+
+	if chunk.Model != "" {
+		s.model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+	for _, choice := range chunk.Choices {
+		s.completion.WriteString(choice.Delta.Content)
+	}
+	return chunk, nil
+}
+
+func (s *meteredStream) Close() error {
+	s.finish()
+	return s.Stream.Close()
+}
+
+// finish records the usage reported by the stream, or an estimate when it reported none
+func (s *meteredStream) finish() {
+	if s.recorded {
+		return
+	}
+	s.recorded = true
+
+	if s.usage != nil && s.usage.TotalTokens > 0 {
+		s.meter.record(s.ctx, s.model, *s.usage, false)
+		return
+	}
+	s.meter.record(s.ctx, s.model, estimate(s.req, s.completion.String()), true)
+}
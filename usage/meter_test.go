@@ -0,0 +1,170 @@
+package usage
+
+import (
+	"context"
+	"credibot-api/llm"
+	"credibot-api/models"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// unreportedUsage answers like the provider it wraps without reporting usage, as some local
+// models do
+type unreportedUsage struct{ llm.Provider }
+
+func (p unreportedUsage) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := p.Provider.CreateChatCompletion(ctx, req)
+	resp.Usage = openai.Usage{}
+	return resp, err
+}
+
+// meter wraps the fake provider, wrapped by wrap when given, with a meter on a memory store.
+// The model "modelo" costs $1 per million prompt tokens and $2 per million completion tokens.
+func meter(t *testing.T, wrap func(llm.Provider) llm.Provider) (llm.Provider, *MemoryStore) {
+	t.Helper()
+	var provider llm.Provider
+	provider, err := llm.NewFakeProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wrap != nil {
+		provider = wrap(provider)
+	}
+	store := NewMemoryStore()
+	pricing := NewPricing([]models.ModelPrice{{Model: "modelo", Input: 1, Output: 2}})
+	return NewMeter(store, pricing).Wrap(provider), store
+}
+
+func question(text string) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{Model: "modelo", Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: text}}}
+}
+
+// stored returns every record of a store
+func stored(t *testing.T, store Store) []Record {
+	t.Helper()
+	records, err := store.List(time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestMeterAttributesEveryCall(t *testing.T) {
+	provider, store := meter(t, nil)
+
+	ctx, tally := WithTally(WithCaller(context.Background(), "painel", "/api/v1/smart-chat"))
+	first, err := provider.CreateChatCompletion(WithStage(WithVariants(ctx, []string{"tom/formal"}), "analysis"), question("Quantos clientes?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := provider.CreateChatCompletion(ctx, question("E PJ?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := stored(t, store)
+	if len(records) != 2 {
+		t.Fatalf("%d records, want 2", len(records))
+	}
+	r := records[0]
+	if r.Caller != "painel" || r.Endpoint != "/api/v1/smart-chat" || r.Stage != "analysis" || r.Model != "modelo" || len(r.Variants) != 1 {
+		t.Errorf("record attributed as %+v", r)
+	}
+	if r.PromptTokens != first.Usage.PromptTokens || r.CompletionTokens != first.Usage.CompletionTokens || r.Estimated {
+		t.Errorf("record has %d+%d tokens, want the reported %d+%d", r.PromptTokens, r.CompletionTokens, first.Usage.PromptTokens, first.Usage.CompletionTokens)
+	}
+	if want := float64(r.PromptTokens*1+r.CompletionTokens*2) / 1e6; math.Abs(r.Cost-want) > 1e-12 {
+		t.Errorf("cost %v, want %v", r.Cost, want)
+	}
+	if records[1].Stage != DefaultStage {
+		t.Errorf("a call without a stage was recorded for %q", records[1].Stage)
+	}
+
+	if got, want := tally.Usage().TotalTokens, first.Usage.TotalTokens+second.Usage.TotalTokens; got != want {
+		t.Errorf("tally of %d tokens, want %d", got, want)
+	}
+	if got, want := tally.Cost(), records[0].Cost+records[1].Cost; math.Abs(got-want) > 1e-12 {
+		t.Errorf("tally cost %v, want %v", got, want)
+	}
+}
+
+func TestCallsWithoutACallerAreAnonymous(t *testing.T) {
+	provider, store := meter(t, nil)
+
+	if _, err := provider.CreateChatCompletion(WithCaller(context.Background(), "", "/api/v1/chat"), question("Oi")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.CreateChatCompletion(context.Background(), question("Oi")); err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range stored(t, store) {
+		if record.Caller != AnonymousCaller {
+			t.Errorf("recorded for caller %q, want %q", record.Caller, AnonymousCaller)
+		}
+	}
+}
+
+func TestUnreportedUsageIsEstimated(t *testing.T) {
+	provider, store := meter(t, func(p llm.Provider) llm.Provider { return unreportedUsage{p} })
+
+	if _, err := provider.CreateChatCompletion(context.Background(), question("Quantos clientes têm score acima de 800?")); err != nil {
+		t.Fatal(err)
+	}
+	if records := stored(t, store); len(records) != 1 || !records[0].Estimated || records[0].PromptTokens == 0 || records[0].CompletionTokens == 0 {
+		t.Errorf("records = %+v, want one with estimated tokens", records)
+	}
+}
+
+func TestStreamsAreRecordedOnce(t *testing.T) {
+	provider, store := meter(t, nil)
+
+	// Read to the end, then closed
+	stream, err := provider.CreateChatCompletionStream(context.Background(), question("Quantos clientes?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := stream.Recv(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.Close()
+	if records := stored(t, store); len(records) != 1 || records[0].Estimated {
+		t.Fatalf("records = %+v, want the usage reported by the stream", records)
+	}
+
+	// Closed before the usage chunk
+	stream, err = provider.CreateChatCompletionStream(context.Background(), question("Quantos clientes?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+	if records := stored(t, store); len(records) != 2 || !records[1].Estimated {
+		t.Errorf("records = %+v, want an estimate for the stream closed early", records)
+	}
+}
+
+func TestPricing(t *testing.T) {
+	pricing := NewPricing([]models.ModelPrice{{Model: "gpt-4o", Input: 1, Output: 1}})
+
+	// Overrides replace the built-in price, the longest prefix wins and unknown models are free
+	if got := pricing.Cost("gpt-4o-2024-08-06", 1e6, 1e6); got != 2 {
+		t.Errorf("overridden gpt-4o costs %v, want 2", got)
+	}
+	if got := pricing.Cost("gpt-4o-mini", 1e6, 1e6); got != 0.75 {
+		t.Errorf("gpt-4o-mini costs %v, want its built-in 0.75", got)
+	}
+	if got := pricing.Cost("llama3", 1e6, 1e6); got != 0 {
+		t.Errorf("a local model costs %v, want 0", got)
+	}
+}
@@ -0,0 +1,47 @@
+package usage
+
+import (
+	"credibot-api/models"
+	"sort"
+	"strings"
+)
+
+// defaultPrices are list prices in USD per million tokens. Models missing from the table,
+// such as local models, cost nothing.
+var defaultPrices = []models.ModelPrice{
+	{Model: "gpt-4.1-nano", Input: 0.10, Output: 0.40},
+	{Model: "gpt-4.1-mini", Input: 0.40, Output: 1.60},
+	{Model: "gpt-4.1", Input: 2.00, Output: 8.00},
+	{Model: "gpt-4o-mini", Input: 0.15, Output: 0.60},
+	{Model: "gpt-4o", Input: 2.50, Output: 10.00},
+	{Model: "gpt-4-turbo", Input: 10.00, Output: 30.00},
+	{Model: "gpt-4", Input: 30.00, Output: 60.00},
+	{Model: "gpt-3.5-turbo", Input: 0.50, Output: 1.50},
+	{Model: "o3-mini", Input: 1.10, Output: 4.40},
+	{Model: "o4-mini", Input: 1.10, Output: 4.40},
+}
+
+// Pricing estimates the cost of model calls
+type Pricing struct {
+	prices []models.ModelPrice
+}
+
+// NewPricing creates a pricing where overrides take precedence over the built-in prices
+func NewPricing(overrides []models.ModelPrice) Pricing {
+	prices := append(append([]models.ModelPrice{}, overrides...), defaultPrices...)
+	// The longest matching prefix wins; the stable sort keeps overrides ahead of defaults
+	sort.SliceStable(prices, func(i, j int) bool {
+		return len(prices[i].Model) > len(prices[j].Model)
+	})
+	return Pricing{prices: prices}
+}
+
+// Cost returns the estimated cost in USD of a call
+func (p Pricing) Cost(model string, promptTokens, completionTokens int) float64 {
+	for _, price := range p.prices {
+		if strings.HasPrefix(model, price.Model) {
+			return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6
+		}
+	}
+	return 0
+}
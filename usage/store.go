@@ -0,0 +1,152 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store persists usage records
+type Store interface {
+	Add(record Record) error
+	// List returns the records in [from, to), oldest first
+	List(from, to time.Time) ([]Record, error)
+}
+
+// NewStore creates a file store in dir, or a memory store when dir is empty
+func NewStore(dir string) (Store, error) {
+	if dir == "" {
+		return NewMemoryStore(), nil
+	}
+	return NewFileStore(dir)
+}
+
+// MemoryStore keeps usage records in memory; they are lost on restart
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records []Record
+}
+
+// NewMemoryStore creates an empty in-memory usage store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Add stores a record
+func (s *MemoryStore) Add(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+// List returns the records in [from, to)
+func (s *MemoryStore) List(from, to time.Time) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []Record
+	for _, record := range s.records {
+		if inRange(record, from, to) {
+			list = append(list, record)
+		}
+	}
+	return list, nil
+}
+
+// FileStore appends records as JSON lines to one file per month, so usage survives restarts
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore creates a file store, creating the directory when needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create usage store directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Add appends a record to the file of its month
+func (s *FileStore) Add(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path(record.Time), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// List reads the records in [from, to) from the files of the months in range
+func (s *FileStore) List(from, to time.Time) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []Record
+	for month := monthStart(from); month.Before(to); month = month.AddDate(0, 1, 0) {
+		records, err := s.readMonth(month)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if inRange(record, from, to) {
+				list = append(list, record)
+			}
+		}
+	}
+	return list, nil
+}
+
+// readMonth reads every record of a month file; a missing file has none
+func (s *FileStore) readMonth(month time.Time) ([]Record, error) {
+	file, err := os.Open(s.path(month))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(file.Name()), err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// path returns the file holding the records of the month of t
+func (s *FileStore) path(t time.Time) string {
+	return filepath.Join(s.dir, t.UTC().Format("2006-01")+".jsonl")
+}
+
+// monthStart returns the first instant of the month of t, in UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func inRange(record Record, from, to time.Time) bool {
+	return !record.Time.Before(from) && record.Time.Before(to)
+}
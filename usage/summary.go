@@ -0,0 +1,123 @@
+package usage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Aggregation periods
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// Dimensions records can be grouped by
+var Dimensions = []string{"caller", "endpoint", "stage", "model"}
+
+// Summary aggregates the usage of the records sharing a period and the grouped dimensions
+type Summary struct {
+	Period           string  `json:"period,omitempty"`
+	Caller           string  `json:"caller,omitempty"`
+	Endpoint         string  `json:"endpoint,omitempty"`
+	Stage            string  `json:"stage,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (s *Summary) add(record Record) {
+	s.Calls++
+	s.PromptTokens += record.PromptTokens
+	s.CompletionTokens += record.CompletionTokens
+	s.TotalTokens += record.TotalTokens
+	s.Cost += record.Cost
+}
+
+// Summarize aggregates records per period (day or month, in UTC) and the groupBy dimensions,
+// ordered by period then dimensions. It also returns the grand total.
+func Summarize(records []Record, period string, groupBy []string) ([]Summary, Summary, error) {
+	layout := "2006-01-02"
+	switch period {
+	case PeriodDay:
+	case PeriodMonth:
+		layout = "2006-01"
+	default:
+		return nil, Summary{}, fmt.Errorf("period must be %s or %s", PeriodDay, PeriodMonth)
+	}
+	group := make(map[string]bool, len(groupBy))
+	for _, dimension := range groupBy {
+		if !isDimension(dimension) {
+			return nil, Summary{}, fmt.Errorf("cannot group by %q: must be one of %s", dimension, strings.Join(Dimensions, ", "))
+		}
+		group[dimension] = true
+	}
+
+	var total Summary
+	buckets := make(map[Summary]*Summary)
+	for _, record := range records {
+		key := Summary{Period: record.Time.UTC().Format(layout)}
+		if group["caller"] {
+			key.Caller = record.Caller
+		}
+		if group["endpoint"] {
+			key.Endpoint = record.Endpoint
+		}
+		if group["stage"] {
+			key.Stage = record.Stage
+		}
+		if group["model"] {
+			key.Model = record.Model
+		}
+
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &Summary{Period: key.Period, Caller: key.Caller, Endpoint: key.Endpoint, Stage: key.Stage, Model: key.Model}
+			buckets[key] = bucket
+		}
+		bucket.add(record)
+		total.add(record)
+	}
+
+	list := make([]Summary, 0, len(buckets))
+	for _, bucket := range buckets {
+		bucket.Cost = roundCost(bucket.Cost)
+		list = append(list, *bucket)
+	}
+	total.Cost = roundCost(total.Cost)
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.Caller != b.Caller {
+			return a.Caller < b.Caller
+		}
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		if a.Stage != b.Stage {
+			return a.Stage < b.Stage
+		}
+		return a.Model < b.Model
+	})
+	return list, total, nil
+}
+
+// roundCost drops the floating point noise of summed costs, keeping micro-dollar precision
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1e6) / 1e6
+}
+
+func isDimension(name string) bool {
+	for _, dimension := range Dimensions {
+		if dimension == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package usage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func at(day string) time.Time {
+	t, _ := time.Parse("2006-01-02 15:04", day)
+	return t
+}
+
+// records made by two callers over two days in May and one in June
+var records = []Record{
+	{Time: at("2026-05-01 09:00"), Caller: "painel", Endpoint: "/chat", Stage: "completion", Model: "gpt-4o", PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Cost: 0.1},
+	{Time: at("2026-05-01 18:00"), Caller: "painel", Endpoint: "/smart-chat", Stage: "analysis", Model: "gpt-4o", PromptTokens: 20, CompletionTokens: 10, TotalTokens: 30, Cost: 0.2},
+	{Time: at("2026-05-02 10:00"), Caller: "auditoria", Endpoint: "/chat", Stage: "completion", Model: "gpt-4o-mini", PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2, Cost: 0.001},
+	{Time: at("2026-06-01 10:00"), Caller: "painel", Endpoint: "/chat", Stage: "completion", Model: "gpt-4o", PromptTokens: 4, CompletionTokens: 4, TotalTokens: 8, Cost: 0.05},
+}
+
+func TestSummarizePerDayAndCaller(t *testing.T) {
+	rows, total, err := Summarize(records, PeriodDay, []string{"caller"})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+
+	want := []Summary{
+		{Period: "2026-05-01", Caller: "painel", Calls: 2, PromptTokens: 30, CompletionTokens: 15, TotalTokens: 45, Cost: 0.3},
+		{Period: "2026-05-02", Caller: "auditoria", Calls: 1, PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2, Cost: 0.001},
+		{Period: "2026-06-01", Caller: "painel", Calls: 1, PromptTokens: 4, CompletionTokens: 4, TotalTokens: 8, Cost: 0.05},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %+v, want %+v", rows, want)
+	}
+	if total.Calls != 4 || total.TotalTokens != 55 || total.Cost != 0.351 {
+		t.Errorf("total = %+v, want 4 calls, 55 tokens and $0.351", total)
+	}
+}
+
+func TestSummarizePerMonthAndModel(t *testing.T) {
+	rows, _, err := Summarize(records, PeriodMonth, []string{"model"})
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+
+	var got []string
+	for _, row := range rows {
+		got = append(got, row.Period+" "+row.Model)
+	}
+	if want := []string{"2026-05 gpt-4o", "2026-05 gpt-4o-mini", "2026-06 gpt-4o"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+	if rows[0].Calls != 2 || rows[0].Caller != "" {
+		t.Errorf("first row = %+v, want 2 calls and no caller", rows[0])
+	}
+}
+
+func TestSummarizeRejectsUnknownPeriodsAndDimensions(t *testing.T) {
+	if _, _, err := Summarize(records, "week", nil); err == nil {
+		t.Error("Summarize per week succeeded")
+	}
+	if _, _, err := Summarize(records, PeriodDay, []string{"caller", "team"}); err == nil {
+		t.Error("Summarize by team succeeded")
+	}
+}
+
+func TestFileStoreKeepsRecordsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := store.Add(record); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// From the second of May to the end of the first of June, across the month files
+	list, err := reopened.List(at("2026-05-02 00:00"), at("2026-06-01 10:00").Add(time.Second))
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !reflect.DeepEqual(list, records[2:]) {
+		t.Errorf("List = %+v, want %+v", list, records[2:])
+	}
+}
@@ -0,0 +1,120 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Record is the token usage and estimated cost of a single model call
+type Record struct {
+	Time             time.Time `json:"time"`
+	Caller           string    `json:"caller"`
+	Endpoint         string    `json:"endpoint"`
+	Stage            string    `json:"stage"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Cost             float64   `json:"cost"`
+	// Estimated is set when the provider did not report usage and tokens were counted locally
+	Estimated bool `json:"estimated,omitempty"`
+	// Variants are the experiment variants of the request, as <experiment>/<variant>
+	Variants []string `json:"variants,omitempty"`
+}
+
+// Defaults for calls made outside an attributed request
+const (
+	AnonymousCaller = "anonymous"
+	DefaultStage    = "completion"
+)
+
+type attributionKey struct{}
+type stageKey struct{}
+type tallyKey struct{}
+type variantsKey struct{}
+
+type attribution struct {
+	caller   string
+	endpoint string
+}
+
+// WithCaller attributes the model calls made with the context to a caller and an endpoint
+func WithCaller(ctx context.Context, caller, endpoint string) context.Context {
+	if caller == "" {
+		caller = AnonymousCaller
+	}
+	return context.WithValue(ctx, attributionKey{}, attribution{caller: caller, endpoint: endpoint})
+}
+
+// WithStage attributes the model calls made with the context to a pipeline stage
+func WithStage(ctx context.Context, stage string) context.Context {
+	return context.WithValue(ctx, stageKey{}, stage)
+}
+
+// WithVariants tags the model calls made with the context with experiment variants
+func WithVariants(ctx context.Context, variants []string) context.Context {
+	return context.WithValue(ctx, variantsKey{}, variants)
+}
+
+// Tally sums the usage of the model calls made for one request
+type Tally struct {
+	mu    sync.Mutex
+	usage openai.Usage
+	cost  float64
+}
+
+// WithTally attaches a tally to the context that every metered call adds to
+func WithTally(ctx context.Context) (context.Context, *Tally) {
+	tally := &Tally{}
+	return context.WithValue(ctx, tallyKey{}, tally), tally
+}
+
+// Usage returns the summed usage
+func (t *Tally) Usage() openai.Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+// Cost returns the summed estimated cost
+func (t *Tally) Cost() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cost
+}
+
+func (t *Tally) add(record Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage.PromptTokens += record.PromptTokens
+	t.usage.CompletionTokens += record.CompletionTokens
+	t.usage.TotalTokens += record.TotalTokens
+	t.cost += record.Cost
+}
+
+// newRecord creates a record attributed from the context
+func newRecord(ctx context.Context, model string, u openai.Usage) Record {
+	record := Record{
+		Time:             time.Now().UTC(),
+		Caller:           AnonymousCaller,
+		Stage:            DefaultStage,
+		Model:            model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	if a, ok := ctx.Value(attributionKey{}).(attribution); ok {
+		record.Caller = a.caller
+		record.Endpoint = a.endpoint
+	}
+	if stage, ok := ctx.Value(stageKey{}).(string); ok && stage != "" {
+		record.Stage = stage
+	}
+	if variants, ok := ctx.Value(variantsKey{}).([]string); ok {
+		record.Variants = variants
+	}
+	return record
+}
@@ -0,0 +1,76 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrForbiddenAddress is returned for callbacks to loopback, link-local, private and other
+// internal addresses, which would let callers reach the server's own network
+var ErrForbiddenAddress = errors.New("callback address not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), internal like the private ranges
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// AddressPolicy decides where callbacks may be sent: hosts resolving to public addresses,
+// and the allowed hosts whatever they resolve to
+type AddressPolicy struct {
+	allowedHosts map[string]bool
+}
+
+// NewAddressPolicy creates a policy that also lets callbacks reach the given hosts
+func NewAddressPolicy(allowedHosts []string) *AddressPolicy {
+	p := &AddressPolicy{allowedHosts: make(map[string]bool, len(allowedHosts))}
+	for _, host := range allowedHosts {
+		p.allowedHosts[strings.ToLower(host)] = true
+	}
+	return p
+}
+
+// Resolve returns the addresses of a callback host, refusing it when any of them is internal.
+// It is used both to check callback URLs and, at dial time, to pick the addresses connected to,
+// so a host cannot resolve to a public address when checked and to an internal one when dialed.
+func (p *AddressPolicy) Resolve(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	if p.allowedHosts[strings.ToLower(host)] {
+		return ips, nil
+	}
+	for _, ip := range ips {
+		if isInternal(ip) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, host, ip)
+		}
+	}
+	return ips, nil
+}
+
+// CheckURL checks that the host of a callback URL may be reached
+func (p *AddressPolicy) CheckURL(ctx context.Context, callbackURL string) error {
+	parsed, err := url.Parse(callbackURL)
+	if err != nil {
+		return err
+	}
+	_, err = p.Resolve(ctx, parsed.Hostname())
+	return err
+}
+
+// isInternal reports whether an address belongs to the local host or a non-public network
+func isInternal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
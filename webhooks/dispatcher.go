@@ -0,0 +1,331 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Headers sent with every callback
+const (
+	HeaderSignature = "X-Credibot-Signature"
+	HeaderTimestamp = "X-Credibot-Timestamp"
+	HeaderDelivery  = "X-Credibot-Delivery"
+	HeaderEvent     = "X-Credibot-Event"
+)
+
+// Attempt records a single delivery attempt
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// Delivery represents a callback and its delivery attempts
+type Delivery struct {
+	ID        string          `json:"id"`
+	JobID     string          `json:"job_id"`
+	Event     string          `json:"event"`
+	URL       string          `json:"url"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    string          `json:"status"`
+	Attempts  []Attempt       `json:"attempts"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ErrNotFound is returned when a delivery does not exist
+var ErrNotFound = errors.New("delivery not found")
+
+// pruneInterval is how often finished deliveries past the retention period are removed when no
+// delivery finishes
+const pruneInterval = time.Minute
+
+// Dispatcher signs and delivers callbacks, retrying with exponential backoff
+type Dispatcher struct {
+	secret         string
+	maxAttempts    int
+	initialBackoff time.Duration
+	client         *http.Client
+	addresses      *AddressPolicy
+	maxFinished    int
+	retention      time.Duration
+
+	mu         sync.RWMutex
+	deliveries map[string]*Delivery
+	// finished indexes the delivered and failed deliveries from oldest to newest
+	finished []finishedDelivery
+}
+
+// finishedDelivery is an entry of the index of finished deliveries
+type finishedDelivery struct {
+	id string
+	at time.Time
+}
+
+// NewDispatcher creates a dispatcher that signs payloads with the given secret and only
+// delivers them to the addresses the policy allows. Finished deliveries are removed once
+// older than retention or beyond the newest maxFinished; 0 disables each bound.
+func NewDispatcher(client *http.Client, addresses *AddressPolicy, secret string, maxAttempts int, initialBackoff time.Duration, maxFinished int, retention time.Duration) *Dispatcher {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	d := &Dispatcher{
+		secret:         secret,
+		maxAttempts:    maxAttempts,
+		initialBackoff: initialBackoff,
+		client:         client,
+		addresses:      addresses,
+		maxFinished:    maxFinished,
+		retention:      retention,
+		deliveries:     make(map[string]*Delivery),
+	}
+	if retention > 0 {
+		go d.pruneEvery(pruneInterval)
+	}
+	return d
+}
+
+// Send records a new delivery and delivers it in the background
+func (d *Dispatcher) Send(jobID, event, url string, payload interface{}) (Delivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	now := time.Now()
+	delivery := &Delivery{
+		ID:        uuid.NewString(),
+		JobID:     jobID,
+		Event:     event,
+		URL:       url,
+		Payload:   body,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	d.mu.Lock()
+	d.deliveries[delivery.ID] = delivery
+	snapshot := copyDelivery(delivery)
+	d.mu.Unlock()
+
+	go d.deliver(delivery.ID)
+	return snapshot, nil
+}
+
+// Replay delivers a recorded callback again with a fresh series of attempts
+func (d *Dispatcher) Replay(id string) (Delivery, error) {
+	d.mu.Lock()
+	delivery, ok := d.deliveries[id]
+	if !ok {
+		d.mu.Unlock()
+		return Delivery{}, ErrNotFound
+	}
+	if delivery.Status == StatusPending {
+		snapshot := copyDelivery(delivery)
+		d.mu.Unlock()
+		return snapshot, nil
+	}
+	delivery.Status = StatusPending
+	delivery.UpdatedAt = time.Now()
+	// Pending again, so it is indexed anew when it finishes
+	for i, entry := range d.finished {
+		if entry.id == id {
+			d.finished = append(d.finished[:i], d.finished[i+1:]...)
+			break
+		}
+	}
+	snapshot := copyDelivery(delivery)
+	d.mu.Unlock()
+
+	go d.deliver(id)
+	return snapshot, nil
+}
+
+// Get returns a delivery by id
+func (d *Dispatcher) Get(id string) (Delivery, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	delivery, ok := d.deliveries[id]
+	if !ok {
+		return Delivery{}, ErrNotFound
+	}
+	return copyDelivery(delivery), nil
+}
+
+// List returns the deliveries, most recent first, optionally filtered by job
+func (d *Dispatcher) List(jobID string) []Delivery {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]Delivery, 0, len(d.deliveries))
+	for _, delivery := range d.deliveries {
+		if jobID != "" && delivery.JobID != jobID {
+			continue
+		}
+		list = append(list, copyDelivery(delivery))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// deliver attempts a delivery until it succeeds, fails permanently or runs out of attempts
+func (d *Dispatcher) deliver(id string) {
+	d.mu.RLock()
+	delivery := copyDelivery(d.deliveries[id])
+	d.mu.RUnlock()
+
+	backoff := d.initialBackoff
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		result, retryable := d.attempt(delivery)
+
+		status := StatusPending
+		switch {
+		case result.Error == "":
+			status = StatusDelivered
+		case !retryable || attempt == d.maxAttempts:
+			status = StatusFailed
+		}
+		d.record(id, result, status)
+
+		if status != StatusPending {
+			if status == StatusFailed {
+				log.Printf("Webhook delivery %s to %s failed: %s", id, delivery.URL, result.Error)
+			}
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// attempt posts the signed payload once, reporting whether a failure may be retried
+func (d *Dispatcher) attempt(delivery Delivery) (Attempt, bool) {
+	start := time.Now()
+	result := Attempt{At: start}
+
+	// Checked on every attempt, replays included; the client checks the addresses it dials again
+	if err := d.addresses.CheckURL(context.Background(), delivery.URL); err != nil {
+		result.Error = err.Error()
+		return result, !errors.Is(err, ErrForbiddenAddress)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		result.Error = err.Error()
+		return result, false
+	}
+
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(d.secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	result.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result, !errors.Is(err, ErrForbiddenAddress)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return result, true
+	}
+
+	result.Error = fmt.Sprintf("callback returned status %d", resp.StatusCode)
+	// Client errors are permanent, except timeouts and rate limiting
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return result, retryable
+}
+
+// record appends an attempt to a delivery
+func (d *Dispatcher) record(id string, result Attempt, status string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delivery := d.deliveries[id]
+	delivery.Attempts = append(delivery.Attempts, result)
+	delivery.Status = status
+	delivery.UpdatedAt = time.Now()
+
+	if status != StatusPending {
+		d.finished = append(d.finished, finishedDelivery{id, delivery.UpdatedAt})
+		d.prune()
+	}
+}
+
+// prune removes the finished deliveries past the retention period and the oldest finished
+// deliveries beyond the limit. The caller must hold d.mu.
+func (d *Dispatcher) prune() {
+	now := time.Now()
+	n := 0
+	for n < len(d.finished) {
+		excess := d.maxFinished > 0 && len(d.finished)-n > d.maxFinished
+		expired := d.retention > 0 && now.Sub(d.finished[n].at) > d.retention
+		if !excess && !expired {
+			break
+		}
+		delete(d.deliveries, d.finished[n].id)
+		n++
+	}
+	d.finished = d.finished[n:]
+}
+
+// pruneEvery removes expired deliveries at every interval until the process exits
+func (d *Dispatcher) pruneEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		d.mu.Lock()
+		d.prune()
+		d.mu.Unlock()
+	}
+}
+
+// Sign computes the hex HMAC-SHA256 of "timestamp.payload"; receivers recompute it to verify callbacks
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// copyDelivery copies a delivery so callers cannot mutate the log
+func copyDelivery(d *Delivery) Delivery {
+	copied := *d
+	copied.Attempts = append([]Attempt(nil), d.Attempts...)
+	return copied
+}
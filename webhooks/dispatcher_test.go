@@ -0,0 +1,143 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// receiver accepts callbacks, reporting each one, and rejects those sent with ?job=falha
+func receiver(t *testing.T) (string, <-chan *http.Request) {
+	t.Helper()
+	received := make(chan *http.Request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("job") == "falha" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		received <- r
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, received
+}
+
+// newTestDispatcher allows callbacks to the local receiver
+func newTestDispatcher(maxFinished int, retention time.Duration) *Dispatcher {
+	return NewDispatcher(http.DefaultClient, NewAddressPolicy([]string{"127.0.0.1"}), "segredo", 3, time.Millisecond, maxFinished, retention)
+}
+
+// waitFinished waits for a delivery to be delivered or failed
+func waitFinished(t *testing.T, d *Dispatcher, id string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		delivery, err := d.Get(id)
+		if err != nil || delivery.Status != StatusPending {
+			return delivery
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("delivery %s never finished", id)
+	return Delivery{}
+}
+
+func TestSendSignsTheCallback(t *testing.T) {
+	url, received := receiver(t)
+	d := newTestDispatcher(0, 0)
+
+	delivery, err := d.Send("job-1", "job.finished", url, map[string]string{"status": "succeeded"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	r := <-received
+	timestamp := r.Header.Get(HeaderTimestamp)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Fatalf("timestamp %q: %v", timestamp, err)
+	}
+	if want := "sha256=" + Sign("segredo", timestamp, delivery.Payload); r.Header.Get(HeaderSignature) != want {
+		t.Errorf("signature %q, want %q", r.Header.Get(HeaderSignature), want)
+	}
+	if r.Header.Get(HeaderDelivery) != delivery.ID || r.Header.Get(HeaderEvent) != "job.finished" {
+		t.Errorf("delivery %q, event %q", r.Header.Get(HeaderDelivery), r.Header.Get(HeaderEvent))
+	}
+	if got := waitFinished(t, d, delivery.ID); got.Status != StatusDelivered || len(got.Attempts) != 1 {
+		t.Errorf("delivery finished as %s after %d attempts", got.Status, len(got.Attempts))
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	url, _ := receiver(t)
+	d := newTestDispatcher(0, 0)
+
+	delivery, _ := d.Send("falha", "job.finished", url+"?job=falha", nil)
+	if got := waitFinished(t, d, delivery.ID); got.Status != StatusFailed || len(got.Attempts) != 1 {
+		t.Errorf("delivery finished as %s after %d attempts, want failed after 1", got.Status, len(got.Attempts))
+	}
+}
+
+func TestFinishedDeliveriesBeyondTheLimitAreRemoved(t *testing.T) {
+	url, _ := receiver(t)
+	d := newTestDispatcher(2, 0)
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		delivery, _ := d.Send("job-"+strconv.Itoa(i), "job.finished", url, nil)
+		waitFinished(t, d, delivery.ID)
+		ids = append(ids, delivery.ID)
+	}
+
+	for i, id := range ids {
+		_, err := d.Get(id)
+		if kept := i >= 2; kept != (err == nil) {
+			t.Errorf("delivery %d: kept = %v, want %v", i, err == nil, kept)
+		}
+	}
+	if got := len(d.List("")); got != 2 {
+		t.Errorf("listed %d deliveries, want 2", got)
+	}
+}
+
+func TestFinishedDeliveriesPastTheRetentionAreRemoved(t *testing.T) {
+	url, _ := receiver(t)
+	d := newTestDispatcher(0, 50*time.Millisecond)
+
+	old, _ := d.Send("job-1", "job.finished", url, nil)
+	waitFinished(t, d, old.ID)
+	time.Sleep(60 * time.Millisecond)
+	recent, _ := d.Send("job-2", "job.finished", url, nil)
+	waitFinished(t, d, recent.ID)
+
+	if _, err := d.Get(old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired delivery: error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := d.Get(recent.ID); err != nil {
+		t.Errorf("recent delivery: %v", err)
+	}
+}
+
+func TestReplayedDeliveryIsIndexedAgain(t *testing.T) {
+	url, _ := receiver(t)
+	d := newTestDispatcher(2, 0)
+
+	first, _ := d.Send("job-1", "job.finished", url, nil)
+	waitFinished(t, d, first.ID)
+	second, _ := d.Send("job-2", "job.finished", url, nil)
+	waitFinished(t, d, second.ID)
+
+	// The replay makes the first delivery the newest, so the next one pushes out the second
+	if _, err := d.Replay(first.ID); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	waitFinished(t, d, first.ID)
+	third, _ := d.Send("job-3", "job.finished", url, nil)
+	waitFinished(t, d, third.ID)
+
+	if _, err := d.Get(second.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delivery: error = %v, want %v", err, ErrNotFound)
+	}
+	if got, err := d.Get(first.ID); err != nil || len(got.Attempts) != 2 {
+		t.Errorf("replayed delivery = %+v, %v; want it kept with 2 attempts", got, err)
+	}
+}